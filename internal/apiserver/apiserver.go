@@ -5,9 +5,9 @@ import (
 	"net/http"
 
 	"github.com/alisavch/image-service/internal/broker"
-	"github.com/alisavch/image-service/internal/bucket"
 	"github.com/alisavch/image-service/internal/repository"
 	"github.com/alisavch/image-service/internal/service"
+	"github.com/alisavch/image-service/internal/storage"
 	"github.com/alisavch/image-service/internal/utils"
 
 	"github.com/joho/godotenv"
@@ -32,10 +32,14 @@ func Start() error {
 		}
 	}(db)
 
+	store, err := storage.New(conf)
+	if err != nil {
+		logger.Fatalf("%s: %s", "Failed to initialize storage", err)
+	}
+
 	repos := repository.NewRepository(db)
-	aws := bucket.NewAWS()
-	services := service.NewService(repos, aws)
-	currentService := NewAPI(services, aws)
+	services := service.NewService(repos, store)
+	currentService := NewAPI(services, store, conf.Limits)
 	rabbit := broker.NewAMQPBrokerAPI()
 
 	err = rabbit.Connect()
@@ -10,6 +10,7 @@ import (
 	"github.com/alisavch/image-service/internal/apiserver/mocks"
 	"github.com/alisavch/image-service/internal/broker"
 	"github.com/alisavch/image-service/internal/models"
+	"github.com/alisavch/image-service/internal/storage"
 	"github.com/alisavch/image-service/internal/utils"
 
 	"github.com/google/uuid"
@@ -100,10 +101,10 @@ func TestHandler_signUp(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockBucket := new(mocks.S3Bucket)
+			store := storage.NewFileSystem(t.TempDir())
 			mockSO := new(mocks.ServiceOperations)
 
-			currentService := NewAPI(mockSO, mockBucket)
+			currentService := NewAPI(mockSO, store, utils.NewConfig().Limits)
 			mq := broker.NewAMQPBrokerAPI()
 
 			s := NewServer(mq, currentService)
@@ -190,10 +191,10 @@ func TestHandler_singIn(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockBucket := new(mocks.S3Bucket)
+			store := storage.NewFileSystem(t.TempDir())
 			mockSO := new(mocks.ServiceOperations)
 
-			currentService := NewAPI(mockSO, mockBucket)
+			currentService := NewAPI(mockSO, store, utils.NewConfig().Limits)
 			mq := broker.NewAMQPBrokerAPI()
 
 			s := NewServer(mq, currentService)
@@ -1,11 +1,21 @@
 package apiserver
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/alisavch/image-service/internal/models"
+	"github.com/alisavch/image-service/internal/service"
+	"github.com/alisavch/image-service/internal/storage"
 	"github.com/alisavch/image-service/internal/utils"
 
 	"github.com/google/uuid"
@@ -15,6 +25,46 @@ import (
 const (
 	// DefaultWidth is default value for compress JPEG and PNG.
 	DefaultWidth = "150"
+	// DefaultMode is default resize mode for compress JPEG and PNG.
+	DefaultMode = models.Fit
+	// DefaultQuality is default JPEG quality.
+	DefaultQuality = "95"
+	// DefaultPNGLevel is default PNG compression level.
+	DefaultPNGLevel = "9"
+	// DefaultDither is default value for dithering the quantized PNG images.
+	DefaultDither = "true"
+	// DefaultGravity is default gravity for cropping to an aspect ratio.
+	DefaultGravity = models.Center
+	// MaxPipelineSteps is the largest number of steps in a pipeline.
+	MaxPipelineSteps = 20
+	// MaxFilters is the largest number of adjustments in a filter request.
+	MaxFilters = 20
+	// DefaultWatermarkPosition is default position of the watermark.
+	DefaultWatermarkPosition = models.BottomRight
+	// DefaultWatermarkOpacity is default opacity of the watermark.
+	DefaultWatermarkOpacity = 0.5
+	// DefaultWatermarkMargin is default distance in pixels between the watermark and the edges of the image.
+	DefaultWatermarkMargin = 16
+	// DefaultWatermarkScale is default width of the watermark relative to the width of the image.
+	DefaultWatermarkScale = 0.25
+	// MaxThumbnailWidths is the largest number of widths in a thumbnail request.
+	MaxThumbnailWidths = 10
+	// MaxThumbnailWidth is the largest width of a rendition.
+	MaxThumbnailWidth = 10000
+	// MaxThumbnailFormats is the largest number of formats in a thumbnail request.
+	MaxThumbnailFormats = 5
+	// MaxLogoSize is the largest size of the watermark logo in bytes.
+	MaxLogoSize = 1 << 20
+	// DefaultHashDistance is default Hamming distance of similar images.
+	DefaultHashDistance = 10
+	// DefaultPaletteColors is default number of the dominant colors.
+	DefaultPaletteColors = 5
+	// MaxPaletteColors is the largest number of the dominant colors.
+	MaxPaletteColors = 16
+	// DefaultMetadata is default metadata policy.
+	DefaultMetadata = models.Strip
+	// DefaultAutoOrient is default value for rotating images according to their EXIF orientation.
+	DefaultAutoOrient = "true"
 	// DefaultOriginal is default value for downloads original image.
 	DefaultOriginal = "false"
 )
@@ -62,9 +112,11 @@ func (s *Server) findUserHistory() http.HandlerFunc {
 
 type compressImageRequest struct {
 	models.Image
+	models.Resize
+	models.Decoding
+	models.Encoding
 	User         models.User
 	ImageRequest models.Request
-	Width        int
 }
 
 // Build builds a request to compress image.
@@ -76,151 +128,1289 @@ func (req *compressImageRequest) Build(r *http.Request) error {
 
 	req.User.ID = id
 
-	width := r.FormValue("width")
-	if width == "" {
+	width, height := r.FormValue("width"), r.FormValue("height")
+	if width == "" && height == "" {
 		width = DefaultWidth
 	}
 
-	convertedWidth, err := strconv.Atoi(width)
+	var err error
+	req.Width, err = atoiOrZero(width)
 	if err != nil {
-		return utils.ErrAtoi
+		return err
+	}
+	req.Height, err = atoiOrZero(height)
+	if err != nil {
+		return err
+	}
+
+	req.Mode = models.ResizeMode(r.FormValue("mode"))
+	if req.Mode == "" {
+		req.Mode = DefaultMode
+	}
+	req.Background = r.FormValue("background")
+	if req.Background == "" {
+		req.Background = service.DefaultBackground
+	}
+	req.Resize.Gravity = models.Gravity(r.FormValue("gravity"))
+	if req.Resize.Gravity == "" {
+		req.Resize.Gravity = DefaultGravity
+	}
+
+	req.Decoding, err = buildDecoding(r)
+	if err != nil {
+		return err
+	}
+	req.Encoding, err = buildEncoding(r)
+	if err != nil {
+		return err
+	}
+	req.Encoding.Colors, err = atoiOrZero(r.FormValue("colors"))
+	if err != nil {
+		return err
+	}
+	dither := r.FormValue("dither")
+	if dither == "" {
+		dither = DefaultDither
+	}
+	req.Encoding.Dither, err = strconv.ParseBool(dither)
+	if err != nil {
+		return utils.ErrParseBool
+	}
+
+	req.ImageRequest.Status = models.Queued
+	req.ImageRequest.ServiceName = models.Compression
+
+	return nil
+}
+
+// Validate validates request to compress image.
+func (req compressImageRequest) Validate() error {
+	if err := validateResize(req.Resize); err != nil {
+		return err
+	}
+	if req.Encoding.Colors != 0 && (req.Encoding.Colors < service.MinPNGColors || req.Encoding.Colors > service.MaxPNGColors) {
+		return utils.ErrPNGColors
+	}
+	return validateEncoding(req.Encoding)
+}
+
+// queued returns the user, the request to create and the options of the message sent to compress image.
+func (req compressImageRequest) queued() (models.User, models.Request, models.MessageOptions) {
+	return req.User, req.ImageRequest, models.MessageOptions{Resize: req.Resize, Decoding: req.Decoding, Encoding: req.Encoding}
+}
+
+func (s *Server) compressImage() http.HandlerFunc {
+	return s.handleQueued(func() queuedRequest { return &compressImageRequest{} })
+}
+
+type convertImageRequest struct {
+	models.Image
+	models.Decoding
+	models.Encoding
+	User         models.User
+	ImageRequest models.Request
+}
+
+// Build builds a request to convert image.
+func (req *convertImageRequest) Build(r *http.Request) error {
+	id, ok := r.Context().Value(userCtx).(uuid.UUID)
+	if !ok {
+		return utils.ErrGetUserID
+	}
+
+	req.User.ID = id
+
+	var err error
+	req.Decoding, err = buildDecoding(r)
+	if err != nil {
+		return err
+	}
+	req.Encoding, err = buildEncoding(r)
+	if err != nil {
+		return err
+	}
+	req.Format = r.FormValue("format")
+
+	req.ImageRequest.Status = models.Queued
+	req.ImageRequest.ServiceName = models.Conversion
+
+	return nil
+}
+
+// Validate validates request to convert image.
+func (req *convertImageRequest) Validate() error {
+	if req.Format == "" {
+		return utils.ErrMissingFormat
+	}
+
+	target, err := service.LookupTargetFormat(req.Format)
+	if err != nil {
+		return fmt.Errorf("%w. Please use one of: %s", err, strings.Join(service.TargetFormats(), ", "))
+	}
+	req.Format = target.Name
+
+	return validateEncoding(req.Encoding)
+}
+
+// queued returns the user, the request to create and the options of the message sent to convert image.
+func (req convertImageRequest) queued() (models.User, models.Request, models.MessageOptions) {
+	return req.User, req.ImageRequest, models.MessageOptions{Decoding: req.Decoding, Encoding: req.Encoding}
+}
+
+func (s *Server) convertImage() http.HandlerFunc {
+	return s.handleQueued(func() queuedRequest { return &convertImageRequest{} })
+}
+
+type cropImageRequest struct {
+	models.Image
+	models.Crop
+	models.Decoding
+	models.Encoding
+	User         models.User
+	ImageRequest models.Request
+}
+
+// Build builds a request to crop image.
+func (req *cropImageRequest) Build(r *http.Request) error {
+	id, ok := r.Context().Value(userCtx).(uuid.UUID)
+	if !ok {
+		return utils.ErrGetUserID
+	}
+
+	req.User.ID = id
+
+	var err error
+	for name, value := range map[string]*int{"x": &req.X, "y": &req.Y, "width": &req.Width, "height": &req.Height} {
+		*value, err = atoiOrZero(r.FormValue(name))
+		if err != nil {
+			return err
+		}
+	}
+
+	if aspect := r.FormValue("aspect"); aspect != "" {
+		req.AspectWidth, req.AspectHeight, err = parseAspect(aspect)
+		if err != nil {
+			return err
+		}
+	}
+	req.Gravity = models.Gravity(r.FormValue("gravity"))
+	if req.Gravity == "" {
+		req.Gravity = DefaultGravity
+	}
+
+	req.Decoding, err = buildDecoding(r)
+	if err != nil {
+		return err
+	}
+	req.Encoding, err = buildEncoding(r)
+	if err != nil {
+		return err
+	}
+
+	req.ImageRequest.Status = models.Queued
+	req.ImageRequest.ServiceName = models.Cropping
+
+	return nil
+}
+
+// Validate validates request to crop image.
+func (req cropImageRequest) Validate() error {
+	if err := validateCrop(req.Crop); err != nil {
+		return err
+	}
+	return validateEncoding(req.Encoding)
+}
+
+// queued returns the user, the request to create and the options of the message sent to crop image.
+func (req cropImageRequest) queued() (models.User, models.Request, models.MessageOptions) {
+	return req.User, req.ImageRequest, models.MessageOptions{Crop: req.Crop, Decoding: req.Decoding, Encoding: req.Encoding}
+}
+
+func (s *Server) cropImage() http.HandlerFunc {
+	return s.handleQueued(func() queuedRequest { return &cropImageRequest{} })
+}
+
+type transformImageRequest struct {
+	models.Image
+	models.Transform
+	models.Decoding
+	models.Encoding
+	User         models.User
+	ImageRequest models.Request
+}
+
+// Build builds a request to transform image.
+func (req *transformImageRequest) Build(r *http.Request) error {
+	id, ok := r.Context().Value(userCtx).(uuid.UUID)
+	if !ok {
+		return utils.ErrGetUserID
+	}
+
+	req.User.ID = id
+
+	if rotate := r.FormValue("rotate"); rotate != "" {
+		angle, err := strconv.ParseFloat(rotate, 64)
+		if err != nil {
+			return utils.ErrParseFloat
+		}
+		req.Angle = angle
+	}
+	req.Flip = models.Flip(r.FormValue("flip"))
+	req.Transform.Background = r.FormValue("background")
+	if req.Transform.Background == "" {
+		req.Transform.Background = service.DefaultBackground
+	}
+
+	var err error
+	req.Decoding, err = buildDecoding(r)
+	if err != nil {
+		return err
+	}
+	req.Encoding, err = buildEncoding(r)
+	if err != nil {
+		return err
+	}
+
+	req.ImageRequest.Status = models.Queued
+	req.ImageRequest.ServiceName = models.Transformation
+
+	return nil
+}
+
+// Validate validates request to transform image.
+func (req transformImageRequest) Validate() error {
+	if err := validateTransform(req.Transform); err != nil {
+		return err
+	}
+	return validateEncoding(req.Encoding)
+}
+
+// queued returns the user, the request to create and the options of the message sent to transform image.
+func (req transformImageRequest) queued() (models.User, models.Request, models.MessageOptions) {
+	return req.User, req.ImageRequest, models.MessageOptions{Transform: req.Transform, Decoding: req.Decoding, Encoding: req.Encoding}
+}
+
+func (s *Server) transformImage() http.HandlerFunc {
+	return s.handleQueued(func() queuedRequest { return &transformImageRequest{} })
+}
+
+// pipelineStep is a single step of the pipeline as it is sent by the user.
+type pipelineStep struct {
+	Op         models.Operation    `json:"op"`
+	Width      int                 `json:"width"`
+	Height     int                 `json:"height"`
+	Mode       models.ResizeMode   `json:"mode"`
+	Background string              `json:"background"`
+	X          int                 `json:"x"`
+	Y          int                 `json:"y"`
+	Aspect     string              `json:"aspect"`
+	Gravity    models.Gravity      `json:"gravity"`
+	Rotate     float64             `json:"rotate"`
+	Flip       models.Flip         `json:"flip"`
+	Format     string              `json:"format"`
+	Quality    *int                `json:"quality"`
+	PNGLevel   *int                `json:"png_level"`
+	Filters    []models.Adjustment `json:"filters"`
+	Text       string              `json:"text"`
+	Color      string              `json:"color"`
+	Position   models.Position     `json:"position"`
+	Opacity    *float64            `json:"opacity"`
+	Margin     *int                `json:"margin"`
+	Scale      *float64            `json:"scale"`
+}
+
+type pipelineImageRequest struct {
+	models.Image
+	models.Decoding
+	models.Encoding
+	Steps         []models.Step
+	User          models.User
+	ImageRequest  models.Request
+	findWatermark func(ctx context.Context, userID uuid.UUID) (models.Watermark, error)
+	limits        utils.LimitsConfig
+}
+
+// Build builds a request to run the pipeline.
+func (req *pipelineImageRequest) Build(r *http.Request) error {
+	id, ok := r.Context().Value(userCtx).(uuid.UUID)
+	if !ok {
+		return utils.ErrGetUserID
+	}
+
+	req.User.ID = id
+
+	var err error
+	req.Decoding, err = buildDecoding(r)
+	if err != nil {
+		return err
+	}
+	req.Encoding, err = buildEncoding(r)
+	if err != nil {
+		return err
+	}
+
+	raw := r.FormValue("steps")
+
+	var steps []pipelineStep
+	if err := decodeStrict(raw, &steps); err != nil || len(steps) == 0 || len(steps) > MaxPipelineSteps {
+		return utils.ErrPipelineSteps
+	}
+
+	req.Steps = make([]models.Step, len(steps))
+	for i, step := range steps {
+		req.Steps[i], err = req.buildStep(r.Context(), step)
+		if err != nil {
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+	}
+
+	var pipeline bytes.Buffer
+	if err := json.Compact(&pipeline, []byte(raw)); err != nil {
+		return utils.ErrPipelineSteps
+	}
+
+	req.ImageRequest.Status = models.Queued
+	req.ImageRequest.ServiceName = models.Pipeline
+	req.ImageRequest.Pipeline = pipeline.String()
+
+	return nil
+}
+
+// buildStep fills in the defaults of the step, the convert step also sets the encoding of the result.
+// The watermark step uses the saved default watermark when no text is sent, like the watermark endpoint.
+func (req *pipelineImageRequest) buildStep(ctx context.Context, step pipelineStep) (models.Step, error) {
+	result := models.Step{Operation: step.Op}
+
+	switch step.Op {
+	case models.ResizeStep:
+		result.Resize = models.Resize{Width: step.Width, Height: step.Height, Mode: step.Mode, Background: step.Background, Gravity: step.Gravity}
+		if result.Resize.Mode == "" {
+			result.Resize.Mode = DefaultMode
+		}
+		if result.Resize.Background == "" {
+			result.Resize.Background = service.DefaultBackground
+		}
+		if result.Resize.Gravity == "" {
+			result.Resize.Gravity = DefaultGravity
+		}
+
+	case models.CropStep:
+		result.Crop = models.Crop{X: step.X, Y: step.Y, Width: step.Width, Height: step.Height, Gravity: step.Gravity}
+		if step.Aspect != "" {
+			var err error
+			result.Crop.AspectWidth, result.Crop.AspectHeight, err = parseAspect(step.Aspect)
+			if err != nil {
+				return models.Step{}, err
+			}
+		}
+		if result.Crop.Gravity == "" {
+			result.Crop.Gravity = DefaultGravity
+		}
+
+	case models.TransformStep:
+		result.Transform = models.Transform{Angle: step.Rotate, Flip: step.Flip, Background: step.Background}
+		if result.Transform.Background == "" {
+			result.Transform.Background = service.DefaultBackground
+		}
+
+	case models.FilterStep:
+		result.Filters = step.Filters
+
+	case models.WatermarkStep:
+		wm := defaultWatermark()
+		if step.Text != "" {
+			wm.Text = step.Text
+		} else {
+			saved, err := req.findWatermark(ctx, req.User.ID)
+			if err != nil && !errors.Is(err, utils.ErrWatermarkNotFound) {
+				return models.Step{}, err
+			}
+			if err == nil {
+				wm = saved
+			}
+		}
+		if step.Color != "" {
+			wm.Color = step.Color
+		}
+		if step.Position != "" {
+			wm.Position = step.Position
+		}
+		if step.Opacity != nil {
+			wm.Opacity = *step.Opacity
+		}
+		if step.Margin != nil {
+			wm.Margin = *step.Margin
+		}
+		if step.Scale != nil {
+			wm.Scale = *step.Scale
+		}
+		result.Watermark = wm
+
+	case models.ConvertStep:
+		if step.Format == "" {
+			return models.Step{}, utils.ErrMissingFormat
+		}
+		target, err := service.LookupTargetFormat(step.Format)
+		if err != nil {
+			return models.Step{}, fmt.Errorf("%w. Please use one of: %s", err, strings.Join(service.TargetFormats(), ", "))
+		}
+		req.Encoding.Format = target.Name
+		if step.Quality != nil {
+			req.Encoding.Quality = *step.Quality
+		}
+		if step.PNGLevel != nil {
+			req.Encoding.PNGLevel = *step.PNGLevel
+		}
+		result.Encoding = req.Encoding
+	}
+
+	return result, nil
+}
+
+// Validate validates request to run the pipeline.
+func (req pipelineImageRequest) Validate() error {
+	for i, step := range req.Steps {
+		var err error
+		switch step.Operation {
+		case models.ResizeStep:
+			err = validateResize(step.Resize)
+			if err == nil && step.Resize.Width == 0 && step.Resize.Height == 0 {
+				err = utils.ErrResizeSize
+			}
+		case models.CropStep:
+			err = validateCrop(step.Crop)
+		case models.TransformStep:
+			err = validateTransform(step.Transform)
+		case models.GrayscaleStep:
+		case models.FilterStep:
+			err = validateFilters(step.Filters)
+		case models.WatermarkStep:
+			err = service.ValidateWatermark(step.Watermark, req.limits)
+		case models.ConvertStep:
+			if i != len(req.Steps)-1 {
+				err = utils.ErrPipelineConvert
+			}
+		default:
+			err = utils.ErrPipelineStep
+		}
+		if err != nil {
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+	}
+
+	return validateEncoding(req.Encoding)
+}
+
+// queued returns the user, the request to create and the options of the message sent to run the pipeline.
+func (req pipelineImageRequest) queued() (models.User, models.Request, models.MessageOptions) {
+	return req.User, req.ImageRequest, models.MessageOptions{Steps: req.Steps, Decoding: req.Decoding, Encoding: req.Encoding}
+}
+
+func (s *Server) pipelineImage() http.HandlerFunc {
+	return s.handleQueued(func() queuedRequest {
+		return &pipelineImageRequest{findWatermark: s.service.ServiceOperations.FindWatermark, limits: s.service.limits}
+	})
+}
+
+type filterImageRequest struct {
+	models.Image
+	models.Decoding
+	models.Encoding
+	Filters      []models.Adjustment
+	User         models.User
+	ImageRequest models.Request
+}
+
+// Build builds a request to filter image.
+func (req *filterImageRequest) Build(r *http.Request) error {
+	id, ok := r.Context().Value(userCtx).(uuid.UUID)
+	if !ok {
+		return utils.ErrGetUserID
+	}
+
+	req.User.ID = id
+
+	var err error
+	req.Decoding, err = buildDecoding(r)
+	if err != nil {
+		return err
+	}
+	req.Encoding, err = buildEncoding(r)
+	if err != nil {
+		return err
+	}
+	req.Format = r.FormValue("format")
+
+	if err := decodeStrict(r.FormValue("filters"), &req.Filters); err != nil {
+		return utils.ErrFilters
+	}
+
+	req.ImageRequest.Status = models.Queued
+	req.ImageRequest.ServiceName = models.Filtering
+
+	return nil
+}
+
+// Validate validates request to filter image.
+func (req *filterImageRequest) Validate() error {
+	if err := validateFilters(req.Filters); err != nil {
+		return err
+	}
+
+	if req.Format != "" {
+		target, err := service.LookupTargetFormat(req.Format)
+		if err != nil {
+			return fmt.Errorf("%w. Please use one of: %s", err, strings.Join(service.TargetFormats(), ", "))
+		}
+		req.Format = target.Name
+	}
+
+	return validateEncoding(req.Encoding)
+}
+
+// steps sends the adjustments as a filter step, the target format is set by a convert step after it.
+func (req filterImageRequest) steps() []models.Step {
+	steps := []models.Step{{Operation: models.FilterStep, Filters: req.Filters}}
+	if req.Format != "" {
+		steps = append(steps, models.Step{Operation: models.ConvertStep, Encoding: req.Encoding})
+	}
+	return steps
+}
+
+// queued returns the user, the request to create and the options of the message sent to filter image.
+func (req filterImageRequest) queued() (models.User, models.Request, models.MessageOptions) {
+	return req.User, req.ImageRequest, models.MessageOptions{Steps: req.steps(), Decoding: req.Decoding, Encoding: req.Encoding}
+}
+
+func (s *Server) filterImage() http.HandlerFunc {
+	return s.handleQueued(func() queuedRequest { return &filterImageRequest{} })
+}
+
+type watermarkImageRequest struct {
+	models.Image
+	models.Decoding
+	models.Encoding
+	Watermark     models.Watermark
+	User          models.User
+	ImageRequest  models.Request
+	findWatermark func(ctx context.Context, userID uuid.UUID) (models.Watermark, error)
+	limits        utils.LimitsConfig
+}
+
+// Build builds a request to watermark image, the saved default watermark is used when neither logo nor text is sent.
+func (req *watermarkImageRequest) Build(r *http.Request) error {
+	id, ok := r.Context().Value(userCtx).(uuid.UUID)
+	if !ok {
+		return utils.ErrGetUserID
+	}
+
+	req.User.ID = id
+
+	var err error
+	req.Decoding, err = buildDecoding(r)
+	if err != nil {
+		return err
+	}
+	req.Encoding, err = buildEncoding(r)
+	if err != nil {
+		return err
+	}
+	req.Format = r.FormValue("format")
+
+	req.Watermark, err = buildWatermark(r, defaultWatermark())
+	if err != nil {
+		return err
+	}
+	if !req.Watermark.HasSource() {
+		saved, err := req.findWatermark(r.Context(), id)
+		if err != nil && !errors.Is(err, utils.ErrWatermarkNotFound) {
+			return err
+		}
+		if err == nil {
+			req.Watermark, err = buildWatermark(r, saved)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	req.ImageRequest.Status = models.Queued
+	req.ImageRequest.ServiceName = models.Watermarking
+
+	return nil
+}
+
+// Validate validates request to watermark image.
+func (req *watermarkImageRequest) Validate() error {
+	if err := service.ValidateWatermark(req.Watermark, req.limits); err != nil {
+		return err
+	}
+
+	if req.Format != "" {
+		target, err := service.LookupTargetFormat(req.Format)
+		if err != nil {
+			return fmt.Errorf("%w. Please use one of: %s", err, strings.Join(service.TargetFormats(), ", "))
+		}
+		req.Format = target.Name
+	}
+
+	return validateEncoding(req.Encoding)
+}
+
+// steps sends the watermark as a watermark step, the target format is set by a convert step after it.
+func (req watermarkImageRequest) steps() []models.Step {
+	steps := []models.Step{{Operation: models.WatermarkStep, Watermark: req.Watermark}}
+	if req.Format != "" {
+		steps = append(steps, models.Step{Operation: models.ConvertStep, Encoding: req.Encoding})
+	}
+	return steps
+}
+
+// queued returns the user, the request to create and the options of the message sent to watermark image.
+func (req watermarkImageRequest) queued() (models.User, models.Request, models.MessageOptions) {
+	return req.User, req.ImageRequest, models.MessageOptions{Steps: req.steps(), Decoding: req.Decoding, Encoding: req.Encoding}
+}
+
+func (s *Server) watermarkImage() http.HandlerFunc {
+	return s.handleQueued(func() queuedRequest {
+		return &watermarkImageRequest{findWatermark: s.service.ServiceOperations.FindWatermark, limits: s.service.limits}
+	})
+}
+
+// watermarkSettings is the default watermark as it is shown to the user, the logo itself is not sent back.
+type watermarkSettings struct {
+	models.Watermark
+	HasLogo bool `json:"has_logo"`
+}
+
+func newWatermarkSettings(wm models.Watermark) watermarkSettings {
+	settings := watermarkSettings{Watermark: wm, HasLogo: len(wm.Logo) > 0}
+	settings.Logo = nil
+	return settings
+}
+
+type saveWatermarkRequest struct {
+	Watermark models.Watermark
+	User      models.User
+	limits    utils.LimitsConfig
+}
+
+// Build builds a request to save the default watermark.
+func (req *saveWatermarkRequest) Build(r *http.Request) error {
+	id, ok := r.Context().Value(userCtx).(uuid.UUID)
+	if !ok {
+		return utils.ErrGetUserID
+	}
+
+	req.User.ID = id
+
+	var err error
+	req.Watermark, err = buildWatermark(r, defaultWatermark())
+	return err
+}
+
+// Validate validates request to save the default watermark.
+func (req saveWatermarkRequest) Validate() error {
+	return service.ValidateWatermark(req.Watermark, req.limits)
+}
+
+func (s *Server) saveWatermark() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := saveWatermarkRequest{limits: s.service.limits}
+
+		err := ParseRequest(r, &req)
+		if errors.Is(err, utils.ErrGetUserID) {
+			s.errorJSON(w, http.StatusUnauthorized, err)
+			return
+		}
+		if errors.Is(err, utils.ErrImageTooLarge) {
+			s.errorJSON(w, http.StatusRequestEntityTooLarge, err)
+			return
+		}
+		if err != nil {
+			s.errorJSON(w, http.StatusBadRequest, err)
+			return
+		}
+
+		err = s.service.ServiceOperations.SaveWatermark(r.Context(), req.User.ID, req.Watermark)
+		if err != nil {
+			s.errorJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+		s.logger.Printf("%s:%s", "Default watermark saved", req.User.ID)
+
+		s.respondJSON(w, http.StatusOK, newWatermarkSettings(req.Watermark))
+	}
+}
+
+type defaultWatermarkRequest struct {
+	User models.User
+}
+
+// Build builds a request to find or delete the default watermark.
+func (req *defaultWatermarkRequest) Build(r *http.Request) error {
+	id, ok := r.Context().Value(userCtx).(uuid.UUID)
+	if !ok {
+		return utils.ErrGetUserID
+	}
+
+	req.User.ID = id
+
+	return nil
+}
+
+// Validate validates request to find or delete the default watermark.
+func (req defaultWatermarkRequest) Validate() error {
+	return nil
+}
+
+func (s *Server) findWatermark() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req defaultWatermarkRequest
+
+		err := ParseRequest(r, &req)
+		if err != nil {
+			s.errorJSON(w, http.StatusUnauthorized, err)
+			return
+		}
+
+		wm, err := s.service.ServiceOperations.FindWatermark(r.Context(), req.User.ID)
+		if errors.Is(err, utils.ErrWatermarkNotFound) {
+			s.errorJSON(w, http.StatusNotFound, err)
+			return
+		}
+		if err != nil {
+			s.errorJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		s.respondJSON(w, http.StatusOK, newWatermarkSettings(wm))
+	}
+}
+
+func (s *Server) deleteWatermark() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req defaultWatermarkRequest
+
+		err := ParseRequest(r, &req)
+		if err != nil {
+			s.errorJSON(w, http.StatusUnauthorized, err)
+			return
+		}
+
+		err = s.service.ServiceOperations.DeleteWatermark(r.Context(), req.User.ID)
+		if errors.Is(err, utils.ErrWatermarkNotFound) {
+			s.errorJSON(w, http.StatusNotFound, err)
+			return
+		}
+		if err != nil {
+			s.errorJSON(w, http.StatusInternalServerError, err)
+			return
+		}
+		s.logger.Printf("%s:%s", "Default watermark deleted", req.User.ID)
+
+		s.respondJSON(w, http.StatusNoContent, nil)
+	}
+}
+
+type thumbnailImageRequest struct {
+	models.Image
+	models.Decoding
+	models.Encoding
+	Thumbnails   models.Thumbnails
+	User         models.User
+	ImageRequest models.Request
+}
+
+// Build builds a request to produce the renditions of the image.
+func (req *thumbnailImageRequest) Build(r *http.Request) error {
+	id, ok := r.Context().Value(userCtx).(uuid.UUID)
+	if !ok {
+		return utils.ErrGetUserID
+	}
+
+	req.User.ID = id
+
+	var err error
+	req.Decoding, err = buildDecoding(r)
+	if err != nil {
+		return err
+	}
+	req.Encoding, err = buildEncoding(r)
+	if err != nil {
+		return err
+	}
+
+	for _, width := range splitList(r.FormValue("widths")) {
+		value, err := strconv.Atoi(width)
+		if err != nil {
+			return utils.ErrThumbnailWidths
+		}
+		req.Thumbnails.Widths = append(req.Thumbnails.Widths, value)
+	}
+	req.Thumbnails.Formats = splitList(r.FormValue("formats"))
+
+	req.ImageRequest.Status = models.Queued
+	req.ImageRequest.ServiceName = models.Thumbnailing
+
+	return nil
+}
+
+// Validate validates request to produce the renditions of the image, the formats are replaced by their canonical names.
+func (req *thumbnailImageRequest) Validate() error {
+	if len(req.Thumbnails.Widths) == 0 || len(req.Thumbnails.Widths) > MaxThumbnailWidths {
+		return utils.ErrThumbnailWidths
+	}
+	for _, width := range req.Thumbnails.Widths {
+		if width < 1 || width > MaxThumbnailWidth {
+			return utils.ErrThumbnailWidths
+		}
+	}
+
+	if len(req.Thumbnails.Formats) > MaxThumbnailFormats {
+		return utils.ErrThumbnailFormats
+	}
+	formats := make([]string, 0, len(req.Thumbnails.Formats))
+	for _, format := range req.Thumbnails.Formats {
+		target, err := service.LookupTargetFormat(format)
+		if err != nil {
+			return fmt.Errorf("%w. Please use one of: %s", err, strings.Join(service.TargetFormats(), ", "))
+		}
+		if !containsString(formats, target.Name) {
+			formats = append(formats, target.Name)
+		}
+	}
+	req.Thumbnails.Formats = formats
+
+	return validateEncoding(req.Encoding)
+}
+
+// queued returns the user, the request to create and the options of the message sent to produce the renditions of the image.
+func (req thumbnailImageRequest) queued() (models.User, models.Request, models.MessageOptions) {
+	return req.User, req.ImageRequest, models.MessageOptions{Thumbnails: req.Thumbnails, Decoding: req.Decoding, Encoding: req.Encoding}
+}
+
+func (s *Server) thumbnailImage() http.HandlerFunc {
+	return s.handleQueued(func() queuedRequest { return &thumbnailImageRequest{} })
+}
+
+// renditionResponse is the rendition together with the path it is downloaded from.
+type renditionResponse struct {
+	models.Rendition
+	URL string `json:"url"`
+}
+
+// thumbnailsResponse lists the renditions of the request, srcset contains the ready-made srcset attribute for every format.
+type thumbnailsResponse struct {
+	RequestID  uuid.UUID           `json:"request_id"`
+	Renditions []renditionResponse `json:"renditions"`
+	Srcset     map[string]string   `json:"srcset"`
+}
+
+func newThumbnailsResponse(requestID uuid.UUID, renditions []models.Rendition) thumbnailsResponse {
+	response := thumbnailsResponse{
+		RequestID:  requestID,
+		Renditions: make([]renditionResponse, 0, len(renditions)),
+		Srcset:     map[string]string{},
+	}
+
+	for _, rendition := range renditions {
+		url := fmt.Sprintf("/api/thumbnails/%s/%s", requestID, rendition.ID)
+		response.Renditions = append(response.Renditions, renditionResponse{Rendition: rendition, URL: url})
+
+		candidate := fmt.Sprintf("%s %dw", url, rendition.Width)
+		if srcset, ok := response.Srcset[rendition.Format]; ok {
+			candidate = srcset + ", " + candidate
+		}
+		response.Srcset[rendition.Format] = candidate
+	}
+
+	return response
+}
+
+type findThumbnailsRequest struct {
+	User        models.User
+	requestID   uuid.UUID
+	renditionID uuid.UUID
+}
+
+// Build builds a request to find the renditions, the rendition id is only set to download one of them.
+func (req *findThumbnailsRequest) Build(r *http.Request) error {
+	id, ok := r.Context().Value(userCtx).(uuid.UUID)
+	if !ok {
+		return utils.ErrGetUserID
+	}
+
+	req.User.ID = id
+
+	vars := mux.Vars(r)
+	requestID, err := uuid.Parse(vars["requestID"])
+	if err != nil {
+		return utils.ErrRequest
+	}
+	req.requestID = requestID
+
+	if renditionID, ok := vars["renditionID"]; ok {
+		req.renditionID, err = uuid.Parse(renditionID)
+		if err != nil {
+			return utils.ErrRequest
+		}
+	}
+
+	return nil
+}
+
+// Validate validates request to find the renditions.
+func (req findThumbnailsRequest) Validate() error {
+	return nil
+}
+
+func (s *Server) findThumbnails() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req findThumbnailsRequest
+
+		err := ParseRequest(r, &req)
+		if errors.Is(err, utils.ErrGetUserID) {
+			s.errorJSON(w, http.StatusUnauthorized, err)
+			return
+		}
+		if err != nil {
+			s.errorJSON(w, http.StatusBadRequest, err)
+			return
+		}
+
+		err = s.service.ServiceOperations.IsAuthenticated(r.Context(), req.User.ID, req.requestID)
+		if err != nil {
+			s.errorJSON(w, http.StatusForbidden, err)
+			return
+		}
+
+		status, err := s.service.ServiceOperations.FindRequestStatus(r.Context(), req.User.ID, req.requestID)
+		if status == models.Processing {
+			s.errorJSON(w, http.StatusConflict, fmt.Errorf("%s:%s", "cannot get thumbnails", utils.ErrImageProcessing))
+			return
+		}
+		if err != nil {
+			s.errorJSON(w, http.StatusNotFound, err)
+			return
+		}
+
+		renditions, err := s.service.ServiceOperations.FindRenditions(r.Context(), req.requestID)
+		if err != nil {
+			s.errorJSON(w, http.StatusNotFound, err)
+			return
+		}
+
+		s.respondJSON(w, http.StatusOK, newThumbnailsResponse(req.requestID, renditions))
+	}
+}
+
+func (s *Server) findThumbnail() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req findThumbnailsRequest
+
+		err := ParseRequest(r, &req)
+		if errors.Is(err, utils.ErrGetUserID) {
+			s.errorJSON(w, http.StatusUnauthorized, err)
+			return
+		}
+		if err != nil {
+			s.errorJSON(w, http.StatusBadRequest, err)
+			return
+		}
+
+		err = s.service.ServiceOperations.IsAuthenticated(r.Context(), req.User.ID, req.requestID)
+		if err != nil {
+			s.errorJSON(w, http.StatusForbidden, err)
+			return
+		}
+
+		rendition, err := s.service.ServiceOperations.FindRendition(r.Context(), req.requestID, req.renditionID)
+		if err != nil {
+			s.errorJSON(w, http.StatusNotFound, fmt.Errorf("%s:%s", utils.ErrFindImage, err))
+			return
+		}
+		s.logger.Printf("%s:%s", "Rendition found", rendition.ResultedName)
+
+		file, err := s.service.ServiceOperations.SaveImage(storage.ResultKey(rendition.ResultedName))
+		if err != nil {
+			s.errorJSON(w, http.StatusInternalServerError, fmt.Errorf("%s:%s", utils.ErrSaveImage, err))
+			return
+		}
+		s.logger.Printf("%s:%s", "Rendition received", rendition.ResultedName)
+
+		s.respondImage(w, file)
+	}
+}
+
+type paletteImageRequest struct {
+	models.Image
+	models.Decoding
+	Palette      models.Palette
+	User         models.User
+	ImageRequest models.Request
+}
+
+// Build builds a request to find the dominant colors of the image.
+func (req *paletteImageRequest) Build(r *http.Request) error {
+	id, ok := r.Context().Value(userCtx).(uuid.UUID)
+	if !ok {
+		return utils.ErrGetUserID
+	}
+
+	req.User.ID = id
+
+	var err error
+	req.Decoding, err = buildDecoding(r)
+	if err != nil {
+		return err
+	}
+
+	req.Palette.Colors = DefaultPaletteColors
+	if colors := r.FormValue("colors"); colors != "" {
+		req.Palette.Colors, err = strconv.Atoi(colors)
+		if err != nil {
+			return utils.ErrPaletteColors
+		}
 	}
-	req.Width = convertedWidth
+
 	req.ImageRequest.Status = models.Queued
-	req.ImageRequest.ServiceName = models.Compression
+	req.ImageRequest.ServiceName = models.PaletteAnalysis
 
 	return nil
 }
 
-// Validate validates request to compress image.
-func (req compressImageRequest) Validate() error {
+// Validate validates request to find the dominant colors of the image.
+func (req paletteImageRequest) Validate() error {
+	if req.Palette.Colors < 1 || req.Palette.Colors > MaxPaletteColors {
+		return utils.ErrPaletteColors
+	}
 	return nil
 }
 
-func (s *Server) compressImage() http.HandlerFunc {
+// queued returns the user, the request to create and the options of the message sent to find the dominant colors of the image.
+func (req paletteImageRequest) queued() (models.User, models.Request, models.MessageOptions) {
+	return req.User, req.ImageRequest, models.MessageOptions{Palette: req.Palette, Decoding: req.Decoding}
+}
+
+func (s *Server) paletteImage() http.HandlerFunc {
+	return s.handleQueued(func() queuedRequest { return &paletteImageRequest{} })
+}
+
+type findSimilarImagesRequest struct {
+	User     models.User
+	imageID  uuid.UUID
+	distance int
+}
+
+// Build builds a request to find the images similar to the image.
+func (req *findSimilarImagesRequest) Build(r *http.Request) error {
+	id, ok := r.Context().Value(userCtx).(uuid.UUID)
+	if !ok {
+		return utils.ErrGetUserID
+	}
+
+	req.User.ID = id
+
+	imageID, err := uuid.Parse(mux.Vars(r)["imageID"])
+	if err != nil {
+		return utils.ErrRequest
+	}
+	req.imageID = imageID
+
+	req.distance = DefaultHashDistance
+	if distance := r.FormValue("distance"); distance != "" {
+		req.distance, err = strconv.Atoi(distance)
+		if err != nil {
+			return utils.ErrHashDistance
+		}
+	}
+
+	return nil
+}
+
+// Validate validates request to find similar images.
+func (req findSimilarImagesRequest) Validate() error {
+	if req.distance < 0 || req.distance > service.MaxHashDistance {
+		return utils.ErrHashDistance
+	}
+	return nil
+}
+
+func (s *Server) findSimilarImages() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var req compressImageRequest
+		var req findSimilarImagesRequest
+
 		err := ParseRequest(r, &req)
-		if err != nil {
+		if errors.Is(err, utils.ErrGetUserID) {
 			s.errorJSON(w, http.StatusUnauthorized, err)
 			return
 		}
-
-		originalImage, err := s.uploadImage(r, req.Image)
 		if err != nil {
-			s.errorJSON(w, http.StatusInternalServerError, err)
+			s.errorJSON(w, http.StatusBadRequest, err)
 			return
 		}
-		s.logger.Printf("%s:%s", "Original image uploaded", originalImage.ID)
 
-		req.Image.ID = originalImage.ID
-		req.Image.UploadedName = originalImage.UploadedName
-		req.Image.UploadedLocation = originalImage.UploadedLocation
-		requestID, err := s.service.ServiceOperations.CreateRequest(r.Context(), req.User, req.Image, req.ImageRequest)
-		if err != nil {
-			s.errorJSON(w, http.StatusInternalServerError, err)
+		hash, err := s.service.ServiceOperations.FindImageHash(r.Context(), req.User.ID, req.imageID)
+		if errors.Is(err, utils.ErrImageHashPending) {
+			s.errorJSON(w, http.StatusConflict, err)
 			return
 		}
-		s.logger.Printf("%s:%s", "Request created", requestID)
-
-		q, err := s.mq.DeclareQueue("publisher")
 		if err != nil {
-			s.logger.Fatalf("%s: %s", "Failed to declare a queue", err)
+			s.errorJSON(w, http.StatusNotFound, err)
+			return
 		}
 
-		err = s.service.ServiceOperations.UpdateStatus(r.Context(), requestID, models.Processing)
+		images, err := s.service.ServiceOperations.FindSimilarImages(r.Context(), req.User.ID, req.imageID, hash, req.distance)
 		if err != nil {
 			s.errorJSON(w, http.StatusInternalServerError, err)
 			return
 		}
-		s.logger.Printf("%s:%s", "Status updated", models.Processing)
 
-		message := models.NewQueuedMessage(req.Width, requestID, req.ImageRequest.ServiceName, originalImage)
-
-		err = s.mq.Publish("", q.Name, message)
-		if err != nil {
-			s.logger.Fatalf("%s: %s", "Failed to publish a message", err)
-		}
-		s.logger.Printf("%s:%s", "Message sent", message.Service)
-
-		s.respondFormData(w, http.StatusAccepted, requestID)
+		s.respondJSON(w, http.StatusOK, images)
 	}
 }
 
-type convertImageRequest struct {
-	models.Image
-	User         models.User
-	ImageRequest models.Request
+type inspectImageRequest struct {
+	User    models.User
+	imageID uuid.UUID
 }
 
-// Build builds a request to convert image.
-func (req *convertImageRequest) Build(r *http.Request) error {
+// Build builds a request to inspect the image.
+func (req *inspectImageRequest) Build(r *http.Request) error {
 	id, ok := r.Context().Value(userCtx).(uuid.UUID)
 	if !ok {
 		return utils.ErrGetUserID
 	}
 
 	req.User.ID = id
-	req.ImageRequest.Status = models.Queued
-	req.ImageRequest.ServiceName = models.Conversion
+
+	imageID, err := uuid.Parse(mux.Vars(r)["imageID"])
+	if err != nil {
+		return utils.ErrRequest
+	}
+	req.imageID = imageID
 
 	return nil
 }
 
-// Validate validates request to convert image.
-func (req convertImageRequest) Validate() error {
+// Validate validates request to inspect the image.
+func (req inspectImageRequest) Validate() error {
 	return nil
 }
 
-func (s *Server) convertImage() http.HandlerFunc {
+func (s *Server) inspectImage() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var req convertImageRequest
+		var req inspectImageRequest
 
 		err := ParseRequest(r, &req)
-		if err != nil {
+		if errors.Is(err, utils.ErrGetUserID) {
 			s.errorJSON(w, http.StatusUnauthorized, err)
 			return
 		}
+		if err != nil {
+			s.errorJSON(w, http.StatusBadRequest, err)
+			return
+		}
 
-		originalImage, err := s.uploadImage(r, req.Image)
+		img, err := s.service.ServiceOperations.FindImage(r.Context(), req.User.ID, req.imageID)
 		if err != nil {
-			s.errorJSON(w, http.StatusInternalServerError, err)
+			s.errorJSON(w, http.StatusNotFound, err)
 			return
 		}
-		s.logger.Printf("%s:%s", "Original image uploaded", originalImage.ID)
 
-		req.Image.ID = originalImage.ID
-		req.Image.UploadedName = originalImage.UploadedName
-		req.Image.UploadedLocation = originalImage.UploadedLocation
-		requestID, err := s.service.ServiceOperations.CreateRequest(r.Context(), req.User, req.Image, req.ImageRequest)
+		inspection := models.ImageInspection{ImageID: img.ID}
+		inspection.Original, err = s.service.ServiceOperations.InspectImage(storage.UploadKey(img.UploadedName), img.UploadedSize, img.UploadedSHA256)
 		if err != nil {
 			s.errorJSON(w, http.StatusInternalServerError, err)
 			return
 		}
-		s.logger.Printf("%s:%s", "Request created", requestID)
 
-		q, err := s.mq.DeclareQueue("publisher")
-		if err != nil {
-			s.logger.Fatalf("%s: %s", "Failed to declare a queue", err)
+		if img.ResultedName != "" {
+			result, err := s.service.ServiceOperations.InspectImage(storage.ResultKey(img.ResultedName), 0, "")
+			if err != nil {
+				s.errorJSON(w, http.StatusInternalServerError, err)
+				return
+			}
+			inspection.Result = &result
 		}
 
-		err = s.service.ServiceOperations.UpdateStatus(r.Context(), requestID, models.Processing)
-		if err != nil {
+		s.respondJSON(w, http.StatusOK, inspection)
+	}
+}
+
+// queuedRequest is a request to process the uploaded image in the consumer.
+type queuedRequest interface {
+	Request
+	queued() (models.User, models.Request, models.MessageOptions)
+}
+
+// handleQueued parses the request built by newRequest and queues the uploaded image with the options of the request.
+func (s *Server) handleQueued(newRequest func() queuedRequest) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := newRequest()
+
+		err := ParseRequest(r, req)
+		if errors.Is(err, utils.ErrGetUserID) {
+			s.errorJSON(w, http.StatusUnauthorized, err)
+			return
+		}
+		if errors.Is(err, utils.ErrFindWatermark) {
 			s.errorJSON(w, http.StatusInternalServerError, err)
 			return
 		}
-		s.logger.Printf("%s:%s", "Status updated", models.Processing)
-
-		message := models.NewQueuedMessage(0, requestID, req.ImageRequest.ServiceName, originalImage)
-
-		err = s.mq.Publish("", q.Name, message)
+		if errors.Is(err, utils.ErrImageTooLarge) {
+			s.errorJSON(w, http.StatusRequestEntityTooLarge, err)
+			return
+		}
 		if err != nil {
-			s.logger.Fatalf("%s: %s", "Failed to publish a message", err)
+			s.errorJSON(w, http.StatusBadRequest, err)
+			return
 		}
-		s.logger.Printf("%s:%s", "Message sent", message.Service)
 
-		s.respondFormData(w, http.StatusAccepted, requestID)
+		user, imageRequest, opts := req.queued()
+		s.queueImage(w, r, user, imageRequest, opts)
+	}
+}
+
+// queueImage uploads the original image, creates the request and sends the message with the given options to the queue.
+func (s *Server) queueImage(w http.ResponseWriter, r *http.Request, user models.User, imageRequest models.Request, opts models.MessageOptions) {
+	originalImage, err := s.uploadImage(r)
+	if err != nil {
+		s.errorJSON(w, uploadStatus(err), err)
+		return
+	}
+	s.logger.Printf("%s:%s", "Original image uploaded", originalImage.ID)
+
+	img := models.Image{ID: originalImage.ID, UploadedName: originalImage.UploadedName, UploadedLocation: originalImage.UploadedLocation}
+	requestID, err := s.service.ServiceOperations.CreateRequest(r.Context(), user, img, imageRequest)
+	if err != nil {
+		s.errorJSON(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.logger.Printf("%s:%s", "Request created", requestID)
+
+	q, err := s.mq.DeclareQueue("publisher")
+	if err != nil {
+		s.logger.Fatalf("%s: %s", "Failed to declare a queue", err)
+	}
+
+	err = s.service.ServiceOperations.UpdateStatus(r.Context(), requestID, models.Processing)
+	if err != nil {
+		s.errorJSON(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.logger.Printf("%s:%s", "Status updated", models.Processing)
+
+	message := models.NewQueuedMessage(requestID, imageRequest.ServiceName, originalImage, opts)
+
+	err = s.mq.Publish("", q.Name, message)
+	if err != nil {
+		s.logger.Fatalf("%s: %s", "Failed to publish a message", err)
 	}
+	s.logger.Printf("%s:%s", "Message sent", message.Service)
+
+	s.respondFormData(w, http.StatusAccepted, requestID)
 }
 
 type findImageRequest struct {
@@ -271,7 +1461,6 @@ func (req findImageRequest) Validate() error {
 func (s *Server) findImage() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req findImageRequest
-		conf := utils.NewConfig()
 
 		err := ParseRequest(r, &req)
 		if err != nil {
@@ -293,7 +1482,7 @@ func (s *Server) findImage() http.HandlerFunc {
 			}
 			s.logger.Printf("%s:%s", "Original image found", uploadedImage.UploadedName)
 
-			file, err := s.service.ServiceOperations.SaveImage(uploadedImage.UploadedName, "/uploads/", conf.Storage)
+			file, err := s.service.ServiceOperations.SaveImage(storage.UploadKey(uploadedImage.UploadedName))
 			if err != nil {
 				s.errorJSON(w, http.StatusInternalServerError, fmt.Errorf("%s:%s", utils.ErrSaveImage, err))
 				return
@@ -321,7 +1510,7 @@ func (s *Server) findImage() http.HandlerFunc {
 		}
 		s.logger.Printf("%s:%s", "Resulted image found", resultedImage.ResultedName)
 
-		file, err := s.service.ServiceOperations.SaveImage(resultedImage.ResultedName, "/results/", conf.Storage)
+		file, err := s.service.ServiceOperations.SaveImage(storage.ResultKey(resultedImage.ResultedName))
 		if err != nil {
 			s.errorJSON(w, http.StatusInternalServerError, fmt.Errorf("%s:%s", utils.ErrSaveImage, err))
 			return
@@ -379,14 +1568,304 @@ func (s *Server) findStatus() http.HandlerFunc {
 			return
 		}
 
-		status, err := s.service.ServiceOperations.FindRequestStatus(r.Context(), req.User.ID, req.RequestID)
+		request, err := s.service.ServiceOperations.FindRequest(r.Context(), req.User.ID, req.RequestID)
 		if err != nil {
 			s.errorJSON(w, http.StatusNotFound, err)
 			return
 		}
 
-		req.Status = status
+		req.Status = request.Status
+
+		switch {
+		case request.Status != models.Done:
+		case request.ServiceName == models.PaletteAnalysis:
+			// The palette is the only result of a palette request, there is no resulted image.
+			palette, err := s.service.ServiceOperations.FindPalette(r.Context(), req.RequestID)
+			if err != nil {
+				s.errorJSON(w, http.StatusInternalServerError, err)
+				return
+			}
+			req.Palette = palette
+		default:
+			result, err := s.service.ServiceOperations.FindResultedImage(r.Context(), req.RequestID)
+			if err != nil {
+				s.errorJSON(w, http.StatusNotFound, err)
+				return
+			}
+			req.BlurHash = result.BlurHash
+			req.Placeholder = result.Placeholder
+			req.UploadedSize = result.UploadedSize
+			req.ResultedSize = result.ResultedSize
+			if result.UploadedSize > 0 && result.ResultedSize > 0 {
+				req.SizeSaved = result.UploadedSize - result.ResultedSize
+			}
+		}
 
 		s.respondJSON(w, http.StatusOK, req.RequestStatus)
 	}
 }
+
+func atoiOrZero(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	converted, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, utils.ErrAtoi
+	}
+	return converted, nil
+}
+
+// splitList splits the comma separated list, empty items are dropped.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func containsString(items []string, value string) bool {
+	for _, item := range items {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeStrict decodes the JSON value rejecting unknown fields.
+func decodeStrict(raw string, v interface{}) error {
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// parseAspect parses the aspect ratio in W:H notation.
+func parseAspect(aspect string) (int, int, error) {
+	parts := strings.Split(aspect, ":")
+	if len(parts) != 2 {
+		return 0, 0, utils.ErrAspectRatio
+	}
+
+	width, err := strconv.Atoi(parts[0])
+	if err != nil || width <= 0 {
+		return 0, 0, utils.ErrAspectRatio
+	}
+	height, err := strconv.Atoi(parts[1])
+	if err != nil || height <= 0 {
+		return 0, 0, utils.ErrAspectRatio
+	}
+
+	return width, height, nil
+}
+
+func buildDecoding(r *http.Request) (models.Decoding, error) {
+	autoOrient := r.FormValue("auto_orient")
+	if autoOrient == "" {
+		autoOrient = DefaultAutoOrient
+	}
+
+	enabled, err := strconv.ParseBool(autoOrient)
+	if err != nil {
+		return models.Decoding{}, utils.ErrParseBool
+	}
+
+	return models.Decoding{AutoOrient: enabled}, nil
+}
+
+func defaultWatermark() models.Watermark {
+	return models.Watermark{
+		Color:    service.DefaultWatermarkColor,
+		Position: DefaultWatermarkPosition,
+		Opacity:  DefaultWatermarkOpacity,
+		Margin:   DefaultWatermarkMargin,
+		Scale:    DefaultWatermarkScale,
+	}
+}
+
+// buildWatermark sets the parameters of the request on the watermark, a sent logo or text replaces both of them.
+func buildWatermark(r *http.Request, wm models.Watermark) (models.Watermark, error) {
+	logo, err := readLogo(r)
+	if err != nil {
+		return models.Watermark{}, err
+	}
+	if text := r.FormValue("text"); len(logo) > 0 || text != "" {
+		wm.Logo, wm.Text = logo, text
+	}
+
+	if color := r.FormValue("color"); color != "" {
+		wm.Color = color
+	}
+	if position := r.FormValue("position"); position != "" {
+		wm.Position = models.Position(position)
+	}
+	if opacity := r.FormValue("opacity"); opacity != "" {
+		wm.Opacity, err = strconv.ParseFloat(opacity, 64)
+		if err != nil {
+			return models.Watermark{}, utils.ErrParseFloat
+		}
+	}
+	if margin := r.FormValue("margin"); margin != "" {
+		wm.Margin, err = atoiOrZero(margin)
+		if err != nil {
+			return models.Watermark{}, err
+		}
+	}
+	if scale := r.FormValue("scale"); scale != "" {
+		wm.Scale, err = strconv.ParseFloat(scale, 64)
+		if err != nil {
+			return models.Watermark{}, utils.ErrParseFloat
+		}
+	}
+
+	return wm, nil
+}
+
+// readLogo reads the logo sent in the form, nil is returned when there is none.
+func readLogo(r *http.Request) ([]byte, error) {
+	file, _, err := r.FormFile("logo")
+	if errors.Is(err, http.ErrMissingFile) || errors.Is(err, http.ErrNotMultipart) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	logo, err := ioutil.ReadAll(io.LimitReader(file, MaxLogoSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(logo) > MaxLogoSize {
+		return nil, utils.ErrWatermarkLogo
+	}
+	return logo, nil
+}
+
+func buildEncoding(r *http.Request) (models.Encoding, error) {
+	quality := r.FormValue("quality")
+	if quality == "" {
+		quality = DefaultQuality
+	}
+
+	pngLevel := r.FormValue("png_level")
+	if pngLevel == "" {
+		pngLevel = DefaultPNGLevel
+	}
+
+	var (
+		encoding models.Encoding
+		err      error
+	)
+	encoding.Quality, err = atoiOrZero(quality)
+	if err != nil {
+		return models.Encoding{}, err
+	}
+	encoding.PNGLevel, err = atoiOrZero(pngLevel)
+	if err != nil {
+		return models.Encoding{}, err
+	}
+
+	encoding.Metadata = models.MetadataPolicy(r.FormValue("metadata"))
+	if encoding.Metadata == "" {
+		encoding.Metadata = DefaultMetadata
+	}
+
+	return encoding, nil
+}
+
+func validateResize(resize models.Resize) error {
+	if resize.Width < 0 || resize.Height < 0 {
+		return utils.ErrNegativeSize
+	}
+
+	switch resize.Mode {
+	case models.Fit:
+	case models.Fill, models.Pad, models.Exact:
+		if resize.Width == 0 || resize.Height == 0 {
+			return utils.ErrMissingSize
+		}
+	default:
+		return utils.ErrResizeMode
+	}
+
+	if err := validateGravity(resize.Gravity); err != nil {
+		return err
+	}
+
+	_, err := service.ParseHexColor(resize.Background)
+	return err
+}
+
+func validateCrop(crop models.Crop) error {
+	if crop.X < 0 || crop.Y < 0 || crop.Width < 0 || crop.Height < 0 {
+		return utils.ErrNegativeSize
+	}
+
+	isAspect := crop.AspectWidth > 0 && crop.AspectHeight > 0
+	if crop.IsRect() == isAspect {
+		return utils.ErrCropParams
+	}
+
+	return validateGravity(crop.Gravity)
+}
+
+func validateGravity(gravity models.Gravity) error {
+	switch gravity {
+	case models.Center, models.North, models.South, models.East, models.West,
+		models.NorthEast, models.NorthWest, models.SouthEast, models.SouthWest, models.Smart:
+	default:
+		return utils.ErrGravity
+	}
+	return nil
+}
+
+func validateTransform(transform models.Transform) error {
+	if transform.IsEmpty() {
+		return utils.ErrTransformParams
+	}
+	if math.IsNaN(transform.Angle) || math.IsInf(transform.Angle, 0) {
+		return utils.ErrRotateAngle
+	}
+
+	switch transform.Flip {
+	case "", models.Horizontal, models.Vertical, models.Both:
+	default:
+		return utils.ErrFlip
+	}
+
+	_, err := service.ParseHexColor(transform.Background)
+	return err
+}
+
+func validateFilters(filters []models.Adjustment) error {
+	if len(filters) == 0 || len(filters) > MaxFilters {
+		return utils.ErrFilters
+	}
+	for _, adj := range filters {
+		if err := service.ValidateAdjustment(adj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateEncoding(encoding models.Encoding) error {
+	if encoding.Quality < 1 || encoding.Quality > 100 {
+		return utils.ErrQuality
+	}
+	if encoding.PNGLevel < 0 || encoding.PNGLevel > 9 {
+		return utils.ErrPNGLevel
+	}
+
+	switch encoding.Metadata {
+	case models.Strip, models.Keep, models.KeepSafe:
+	default:
+		return utils.ErrMetadataPolicy
+	}
+	return nil
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/alisavch/image-service/internal/apiserver/mocks"
 	"github.com/alisavch/image-service/internal/broker"
 	"github.com/alisavch/image-service/internal/models"
+	"github.com/alisavch/image-service/internal/storage"
 	"github.com/alisavch/image-service/internal/utils"
 
 	"github.com/google/uuid"
@@ -119,9 +120,9 @@ func TestHandler_findUserHistory(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockSO := new(mocks.ServiceOperations)
-			mockAWS := new(mocks.S3Bucket)
+			store := storage.NewFileSystem(t.TempDir())
 
-			currentService := NewAPI(mockSO, mockAWS)
+			currentService := NewAPI(mockSO, store, utils.NewConfig().Limits)
 			mq := broker.NewAMQPBrokerAPI()
 
 			s := NewServer(mq, currentService)
@@ -183,13 +184,20 @@ func TestHandler_compressImage(t *testing.T) {
 
 	q := amqp.Queue{Name: "", Messages: 1, Consumers: 1}
 
-	type fnBehavior func(mockSO *mocks.ServiceOperations, mockBucket *mocks.S3Bucket, mockAMQP *mocks.AMQP, token string, model model, storage string)
+	type fnBehavior func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP, token string, model model)
 
 	tests := []struct {
 		name                 string
 		headerNames          []string
 		headerValues         []string
 		params               params
+		mode                 string
+		gravity              string
+		colors               string
+		maxWidth             int
+		fileType             string
+		trailer              string
+		fields               map[string]string
 		token                string
 		userID               uuid.UUID
 		fn                   fnBehavior
@@ -202,25 +210,15 @@ func TestHandler_compressImage(t *testing.T) {
 			headerValues: []string{"Bearer token", "image/jpeg", `multipart/form-data; boundary="foo123"`},
 			params:       params{name: "width", quantity: 100},
 			token:        "token",
-			fn: func(mockSO *mocks.ServiceOperations, mockBucket *mocks.S3Bucket, mockAMQP *mocks.AMQP, token string, model model, storage string) {
-				asString := "00000000-0000-0000-0000-000000000000"
-				s := uuid.MustParse(asString)
-				mockSO.On("ParseToken", token).Return(s, nil)
-				switch storage {
-				case aws:
-					mockBucket.On("UploadToS3Bucket", mock.Anything, mock.Anything).Return(mock.Anything, nil)
-					mockSO.On("UploadImage", mock.Anything, mock.Anything).Return(model.image.ID, nil)
-					mockSO.On("CreateRequest", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.req.ID, nil)
-					mockAMQP.On("DeclareQueue", "publisher").Return(q, nil)
-					mockSO.On("UpdateStatus", mock.Anything, model.req.ID, models.Processing).Return(nil)
-					mockAMQP.On("Publish", "", q.Name, mock.Anything).Return(nil)
-				case local:
-					mockSO.On("UploadImage", mock.Anything, mock.Anything).Return(model.image.ID, nil)
-					mockSO.On("CreateRequest", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.req.ID, nil)
-					mockAMQP.On("DeclareQueue", "publisher").Return(q, nil)
-					mockSO.On("UpdateStatus", mock.Anything, model.req.ID, models.Processing).Return(nil)
-					mockAMQP.On("Publish", "", q.Name, mock.Anything).Return(nil)
-				}
+			fn: func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP, token string, model model) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+				mockSO.On("UploadImage", mock.Anything, mock.Anything).Return(model.image.ID, nil)
+				mockSO.On("CreateRequest", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.req.ID, nil)
+				mockAMQP.On("DeclareQueue", "publisher").Return(q, nil)
+				mockSO.On("UpdateStatus", mock.Anything, model.req.ID, models.Processing).Return(nil)
+				mockAMQP.On("Publish", "", q.Name, mock.Anything).Return(nil)
 			},
 			expectedStatusCode:   202,
 			expectedResponseBody: "{\"Request ID\":\"00000000-0000-0000-0000-000000000000\"}\n",
@@ -231,18 +229,11 @@ func TestHandler_compressImage(t *testing.T) {
 			headerValues: []string{"Bearer token", "image/jpeg", `multipart/form-data; boundary="foo123"`},
 			params:       params{name: "width", quantity: 100},
 			token:        "token",
-			fn: func(mockSO *mocks.ServiceOperations, mockBucket *mocks.S3Bucket, mockAMQP *mocks.AMQP, token string, model model, storage string) {
+			fn: func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP, token string, model model) {
 				asString := "00000000-0000-0000-0000-000000000000"
 				s := uuid.MustParse(asString)
 				mockSO.On("ParseToken", token).Return(s, nil)
-				switch storage {
-				case aws:
-					mockBucket.On("UploadToS3Bucket", mock.Anything, mock.Anything).Return(mock.Anything, nil)
-					mockSO.On("UploadImage", mock.Anything, mock.Anything).Return(uplImg.ID, utils.ErrUpload)
-
-				case local:
-					mockSO.On("UploadImage", mock.Anything, mock.Anything).Return(uplImg.ID, utils.ErrUpload)
-				}
+				mockSO.On("UploadImage", mock.Anything, mock.Anything).Return(uplImg.ID, utils.ErrUpload)
 			},
 			expectedStatusCode:   500,
 			expectedResponseBody: "{\"error\":\"cannot upload the file:cannot upload the file\"}\n",
@@ -253,23 +244,14 @@ func TestHandler_compressImage(t *testing.T) {
 			headerValues: []string{"Bearer token", "image/jpeg", `multipart/form-data; boundary="foo123"`},
 			params:       params{name: "width", quantity: 100},
 			token:        "token",
-			fn: func(mockSO *mocks.ServiceOperations, mockBucket *mocks.S3Bucket, mockAMQP *mocks.AMQP, token string, model model, storage string) {
-				asString := "00000000-0000-0000-0000-000000000000"
-				s := uuid.MustParse(asString)
-				mockSO.On("ParseToken", token).Return(s, nil)
-				switch storage {
-				case aws:
-					mockBucket.On("UploadToS3Bucket", mock.Anything, mock.Anything).Return(mock.Anything, nil)
-					mockSO.On("UploadImage", mock.Anything, mock.Anything).Return(model.image.ID, nil)
-					mockSO.On("CreateRequest", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.req.ID, nil)
-					mockAMQP.On("DeclareQueue", "publisher").Return(q, nil)
-					mockSO.On("UpdateStatus", mock.Anything, model.req.ID, models.Processing).Return(utils.ErrUpdateStatusRequest)
-				case local:
-					mockSO.On("UploadImage", mock.Anything, mock.Anything).Return(model.image.ID, nil)
-					mockSO.On("CreateRequest", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.req.ID, nil)
-					mockAMQP.On("DeclareQueue", "publisher").Return(q, nil)
-					mockSO.On("UpdateStatus", mock.Anything, model.req.ID, models.Processing).Return(utils.ErrUpdateStatusRequest)
-				}
+			fn: func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP, token string, model model) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+				mockSO.On("UploadImage", mock.Anything, mock.Anything).Return(model.image.ID, nil)
+				mockSO.On("CreateRequest", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.req.ID, nil)
+				mockAMQP.On("DeclareQueue", "publisher").Return(q, nil)
+				mockSO.On("UpdateStatus", mock.Anything, model.req.ID, models.Processing).Return(utils.ErrUpdateStatusRequest)
 			},
 			expectedStatusCode:   500,
 			expectedResponseBody: "{\"error\":\"cannot update image status\"}\n",
@@ -280,58 +262,226 @@ func TestHandler_compressImage(t *testing.T) {
 			headerValues: []string{"Bearer token", "image/jpeg", `multipart/form-data; boundary="foo123"`},
 			params:       params{name: "width", quantity: 100},
 			token:        "token",
-			fn: func(mockSO *mocks.ServiceOperations, mockBucket *mocks.S3Bucket, mockAMQP *mocks.AMQP, token string, model model, storage string) {
+			fn: func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP, token string, model model) {
 				asString := "00000000-0000-0000-0000-000000000000"
 				s := uuid.MustParse(asString)
 				mockSO.On("ParseToken", token).Return(s, nil)
-				switch storage {
-				case aws:
-					mockBucket.On("UploadToS3Bucket", mock.Anything, mock.Anything).Return(mock.Anything, nil)
-					mockSO.On("UploadImage", mock.Anything, mock.Anything).Return(model.image.ID, nil)
-					mockSO.On("CreateRequest", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(uplImg.ID, fmt.Errorf("unable to insert resulted image into database"))
-
-				case local:
-					mockSO.On("UploadImage", mock.Anything, mock.Anything).Return(uplImg.ID, nil)
-					mockSO.On("CreateRequest", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(uplImg.ID, fmt.Errorf("unable to insert resulted image into database"))
-				}
+				mockSO.On("UploadImage", mock.Anything, mock.Anything).Return(uplImg.ID, nil)
+				mockSO.On("CreateRequest", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(uplImg.ID, fmt.Errorf("unable to insert resulted image into database"))
 			},
 			expectedStatusCode:   500,
 			expectedResponseBody: "{\"error\":\"unable to insert resulted image into database\"}\n",
 		},
+		{
+			name:         "Negative width",
+			headerNames:  []string{"Authorization", "Content-Type"},
+			headerValues: []string{"Bearer token", "image/jpeg", `multipart/form-data; boundary="foo123"`},
+			params:       params{name: "width", quantity: -100},
+			token:        "token",
+			fn: func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP, token string, model model) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"width and height must not be negative\"}\n",
+		},
+		{
+			name:         "Fill without height",
+			headerNames:  []string{"Authorization", "Content-Type"},
+			headerValues: []string{"Bearer token", "image/jpeg", `multipart/form-data; boundary="foo123"`},
+			params:       params{name: "width", quantity: 100},
+			mode:         "fill",
+			token:        "token",
+			fn: func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP, token string, model model) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"fill, pad and exact modes require both width and height\"}\n",
+		},
+		{
+			name:         "Field sent after the file",
+			headerNames:  []string{"Authorization", "Content-Type"},
+			headerValues: []string{"Bearer token", "image/jpeg", `multipart/form-data; boundary="foo123"`},
+			params:       params{name: "width", quantity: 100},
+			fields:       map[string]string{"mode": "fill"},
+			token:        "token",
+			fn: func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP, token string, model model) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"fill, pad and exact modes require both width and height\"}\n",
+		},
+		{
+			name:         "Unsupported gravity",
+			headerNames:  []string{"Authorization", "Content-Type"},
+			headerValues: []string{"Bearer token", "image/jpeg", `multipart/form-data; boundary="foo123"`},
+			params:       params{name: "width", quantity: 100},
+			gravity:      "middle",
+			token:        "token",
+			fn: func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP, token string, model model) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"gravity is not supported. Please use center, north, south, east, west, north-east, north-west, south-east, south-west or smart\"}\n",
+		},
+		{
+			name:         "Compress image to a palette without errors",
+			headerNames:  []string{"Authorization", "Content-Type"},
+			headerValues: []string{"Bearer token", "image/jpeg", `multipart/form-data; boundary="foo123"`},
+			params:       params{name: "width", quantity: 100},
+			colors:       "64",
+			token:        "token",
+			fn: func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP, token string, model model) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+				mockSO.On("UploadImage", mock.Anything, mock.Anything).Return(model.image.ID, nil)
+				mockSO.On("CreateRequest", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.req.ID, nil)
+				mockAMQP.On("DeclareQueue", "publisher").Return(q, nil)
+				mockSO.On("UpdateStatus", mock.Anything, model.req.ID, models.Processing).Return(nil)
+				mockAMQP.On("Publish", "", q.Name, mock.Anything).Return(nil)
+			},
+			expectedStatusCode:   202,
+			expectedResponseBody: "{\"Request ID\":\"00000000-0000-0000-0000-000000000000\"}\n",
+		},
+		{
+			name:         "Too many colors",
+			headerNames:  []string{"Authorization", "Content-Type"},
+			headerValues: []string{"Bearer token", "image/jpeg", `multipart/form-data; boundary="foo123"`},
+			params:       params{name: "width", quantity: 100},
+			colors:       "512",
+			token:        "token",
+			fn: func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP, token string, model model) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"colors must be between 2 and 256\"}\n",
+		},
+		{
+			name:         "Image exceeds the limits",
+			headerNames:  []string{"Authorization", "Content-Type"},
+			headerValues: []string{"Bearer token", "image/jpeg", `multipart/form-data; boundary="foo123"`},
+			params:       params{name: "width", quantity: 100},
+			maxWidth:     50,
+			token:        "token",
+			fn: func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP, token string, model model) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+			},
+			expectedStatusCode:   413,
+			expectedResponseBody: "{\"error\":\"image is too large: width 100 exceeds the limit of 50\"}\n",
+		},
+		{
+			name:         "Compress image without content type",
+			headerNames:  []string{"Authorization", "Content-Type"},
+			headerValues: []string{"Bearer token", `multipart/form-data; boundary="foo123"`},
+			params:       params{name: "width", quantity: 100},
+			fileType:     "-",
+			token:        "token",
+			fn: func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP, token string, model model) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+				mockSO.On("UploadImage", mock.Anything, mock.MatchedBy(func(img models.Image) bool {
+					return img.UploadedFormat == "jpeg"
+				})).Return(model.image.ID, nil)
+				mockSO.On("CreateRequest", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.req.ID, nil)
+				mockAMQP.On("DeclareQueue", "publisher").Return(q, nil)
+				mockSO.On("UpdateStatus", mock.Anything, model.req.ID, models.Processing).Return(nil)
+				mockAMQP.On("Publish", "", q.Name, mock.Anything).Return(nil)
+			},
+			expectedStatusCode:   202,
+			expectedResponseBody: "{\"Request ID\":\"00000000-0000-0000-0000-000000000000\"}\n",
+		},
+		{
+			name:         "Content type does not match the file",
+			headerNames:  []string{"Authorization", "Content-Type"},
+			headerValues: []string{"Bearer token", "image/png", `multipart/form-data; boundary="foo123"`},
+			params:       params{name: "width", quantity: 100},
+			fileType:     "image/png",
+			token:        "token",
+			fn: func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP, token string, model model) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+			},
+			expectedStatusCode:   415,
+			expectedResponseBody: "{\"error\":\"content of the file does not match its type: declared image/png, detected jpeg\"}\n",
+		},
+		{
+			name:         "Data appended to the image",
+			headerNames:  []string{"Authorization", "Content-Type"},
+			headerValues: []string{"Bearer token", "image/jpeg", `multipart/form-data; boundary="foo123"`},
+			params:       params{name: "width", quantity: 100},
+			trailer:      "PK\x03\x04",
+			token:        "token",
+			fn: func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP, token string, model model) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+			},
+			expectedStatusCode:   415,
+			expectedResponseBody: "{\"error\":\"file contains data of another format: jpeg image with 4 bytes after the end of the image\"}\n",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			conf := utils.NewConfig()
+			limits := utils.NewConfig().Limits
+			if tt.maxWidth != 0 {
+				limits.MaxWidth = tt.maxWidth
+			}
+			dir := t.TempDir()
 
 			mockAMQP := new(mocks.AMQP)
-			mockBucket := new(mocks.S3Bucket)
+			store := storage.NewFileSystem(dir)
 			mockSO := new(mocks.ServiceOperations)
 
-			currentService := NewAPI(mockSO, mockBucket)
+			currentService := NewAPI(mockSO, store, limits)
 			s := NewServer(mockAMQP, currentService)
 
 			s.router.HandleFunc("/api/compress",
-				s.authorize(s.compressImage())).Methods(http.MethodPost)
+				s.authorize(s.streamUpload(s.compressImage()))).Methods(http.MethodPost)
 
 			buf := &bytes.Buffer{}
 			writer := multipart.NewWriter(buf)
 			header := make(textproto.MIMEHeader)
 			header.Set("Content-Disposition", `form-data; name="uploadFile"; filename="filename.jpeg"`)
-			header.Set("Content-Type", "image/jpeg")
+			switch tt.fileType {
+			case "":
+				header.Set("Content-Type", "image/jpeg")
+			case "-":
+			default:
+				header.Set("Content-Type", tt.fileType)
+			}
 
 			content, file := createImage(t, "filename.jpeg")
+			content = append(content, tt.trailer...)
 
 			part, err := writer.CreatePart(header)
 			require.NoError(t, err)
 			_, err = io.Copy(part, bytes.NewReader(content))
 			require.NoError(t, err)
+			for name, value := range tt.fields {
+				err = writer.WriteField(name, value)
+				require.NoError(t, err)
+			}
 			err = writer.Close()
 			require.NoError(t, err)
 			err = file.Close()
 			require.NoError(t, err)
 
-			tt.fn(mockSO, mockBucket, mockAMQP, tt.token, modelStruct, conf.Storage)
+			tt.fn(mockSO, mockAMQP, tt.token, modelStruct)
 
 			w := httptest.NewRecorder()
 			req := httptest.NewRequest(http.MethodPost, "/api/compress", buf)
@@ -346,6 +496,15 @@ func TestHandler_compressImage(t *testing.T) {
 
 			q := req.URL.Query()
 			q.Add(tt.params.name, strconv.Itoa(tt.params.quantity))
+			if tt.mode != "" {
+				q.Add("mode", tt.mode)
+			}
+			if tt.gravity != "" {
+				q.Add("gravity", tt.gravity)
+			}
+			if tt.colors != "" {
+				q.Add("colors", tt.colors)
+			}
 			req.URL.RawQuery = q.Encode()
 
 			err = req.Body.Close()
@@ -356,6 +515,10 @@ func TestHandler_compressImage(t *testing.T) {
 			mockAMQP.AssertExpectations(t)
 			require.Equal(t, tt.expectedStatusCode, w.Code)
 			require.Equal(t, tt.expectedResponseBody, w.Body.String())
+			if tt.expectedStatusCode >= http.StatusBadRequest && tt.expectedStatusCode < http.StatusInternalServerError {
+				uploads, _ := ioutil.ReadDir(filepath.Join(dir, "uploads"))
+				require.Empty(t, uploads)
+			}
 
 			cleanAfterTest(t)
 		})
@@ -401,7 +564,7 @@ func TestHandler_findImage(t *testing.T) {
 				mockSO.On("IsAuthenticated", mock.Anything, s, s).Return(nil)
 				mockSO.On("FindRequestStatus", mock.Anything, s, compressedID).Return(models.Done, nil)
 				mockSO.On("FindResultedImage", mock.Anything, compressedID).Return(resultedImage, nil)
-				mockSO.On("SaveImage", mock.Anything, mock.Anything, mock.Anything).Return(&models.SavedImage{}, nil)
+				mockSO.On("SaveImage", mock.Anything).Return(&models.SavedImage{File: ioutil.NopCloser(bytes.NewReader(nil))}, nil)
 			},
 			expectedStatusCode:   200,
 			expectedResponseBody: "",
@@ -420,7 +583,7 @@ func TestHandler_findImage(t *testing.T) {
 				mockSO.On("IsAuthenticated", mock.Anything, s, s).Return(nil)
 				if isOriginal {
 					mockSO.On("FindOriginalImage", mock.Anything, compressedID).Return(models.Image{}, nil)
-					mockSO.On("SaveImage", mock.Anything, mock.Anything, mock.Anything).Return(&models.SavedImage{}, nil)
+					mockSO.On("SaveImage", mock.Anything).Return(&models.SavedImage{File: ioutil.NopCloser(bytes.NewReader(nil))}, nil)
 				}
 			},
 			expectedStatusCode:   200,
@@ -457,7 +620,7 @@ func TestHandler_findImage(t *testing.T) {
 				mockSO.On("IsAuthenticated", mock.Anything, s, s).Return(nil)
 				mockSO.On("FindRequestStatus", mock.Anything, s, compressedID).Return(models.Done, nil)
 				mockSO.On("FindResultedImage", mock.Anything, compressedID).Return(resultedImage, nil)
-				mockSO.On("SaveImage", mock.Anything, mock.Anything, mock.Anything).Return(&models.SavedImage{}, utils.ErrSaveImage)
+				mockSO.On("SaveImage", mock.Anything).Return(&models.SavedImage{}, utils.ErrSaveImage)
 			},
 			expectedStatusCode:   500,
 			expectedResponseBody: "{\"error\":\"cannot save image:cannot save image\"}\n",
@@ -495,7 +658,7 @@ func TestHandler_findImage(t *testing.T) {
 				mockSO.On("IsAuthenticated", mock.Anything, s, s).Return(nil)
 				if isOriginal {
 					mockSO.On("FindOriginalImage", mock.Anything, compressedID).Return(models.Image{ID: s, UploadedName: "filename", UploadedLocation: "location"}, nil)
-					mockSO.On("SaveImage", mock.Anything, mock.Anything, mock.Anything).Return(&models.SavedImage{}, utils.ErrSaveImage)
+					mockSO.On("SaveImage", mock.Anything).Return(&models.SavedImage{}, utils.ErrSaveImage)
 				}
 			},
 			expectedStatusCode:   500,
@@ -507,10 +670,10 @@ func TestHandler_findImage(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockBucket := new(mocks.S3Bucket)
+			store := storage.NewFileSystem(t.TempDir())
 			mockSO := new(mocks.ServiceOperations)
 
-			currentService := NewAPI(mockSO, mockBucket)
+			currentService := NewAPI(mockSO, store, utils.NewConfig().Limits)
 			mq := broker.NewAMQPBrokerAPI()
 
 			s := NewServer(mq, currentService)
@@ -572,7 +735,7 @@ func TestHandler_convertImage(t *testing.T) {
 
 	q := amqp.Queue{Name: "", Messages: 1, Consumers: 1}
 
-	type fnBehavior func(mockSO *mocks.ServiceOperations, mockBucket *mocks.S3Bucket, mockAMQP *mocks.AMQP, token string, model model, storage string)
+	type fnBehavior func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP, token string, model model)
 
 	tests := []struct {
 		name                 string
@@ -580,6 +743,7 @@ func TestHandler_convertImage(t *testing.T) {
 		headerValues         []string
 		inputImage           models.Image
 		contentType          string
+		query                map[string]string
 		token                string
 		convertedID          string
 		userID               uuid.UUID
@@ -591,26 +755,17 @@ func TestHandler_convertImage(t *testing.T) {
 			name:         "Convert image without errors",
 			headerNames:  []string{"Authorization", "Content-Type"},
 			headerValues: []string{"Bearer token", "image/jpeg", `multipart/form-data; boundary="foo123"`},
+			query:        map[string]string{"format": "png"},
 			token:        "token",
-			fn: func(mockSO *mocks.ServiceOperations, mockBucket *mocks.S3Bucket, mockAMQP *mocks.AMQP, token string, model model, storage string) {
-				asString := "00000000-0000-0000-0000-000000000000"
-				s := uuid.MustParse(asString)
-				mockSO.On("ParseToken", token).Return(s, nil)
-				switch storage {
-				case aws:
-					mockBucket.On("UploadToS3Bucket", mock.Anything, mock.Anything).Return(mock.Anything, nil)
-					mockSO.On("UploadImage", mock.Anything, mock.Anything).Return(model.image.ID, nil)
-					mockSO.On("CreateRequest", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.req.ID, nil)
-					mockAMQP.On("DeclareQueue", "publisher").Return(q, nil)
-					mockSO.On("UpdateStatus", mock.Anything, model.req.ID, models.Processing).Return(nil)
-					mockAMQP.On("Publish", "", q.Name, mock.Anything).Return(nil)
-				case local:
-					mockSO.On("UploadImage", mock.Anything, mock.Anything).Return(model.image.ID, nil)
-					mockSO.On("CreateRequest", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.req.ID, nil)
-					mockAMQP.On("DeclareQueue", "publisher").Return(q, nil)
-					mockSO.On("UpdateStatus", mock.Anything, model.req.ID, models.Processing).Return(nil)
-					mockAMQP.On("Publish", "", q.Name, mock.Anything).Return(nil)
-				}
+			fn: func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP, token string, model model) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+				mockSO.On("UploadImage", mock.Anything, mock.Anything).Return(model.image.ID, nil)
+				mockSO.On("CreateRequest", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.req.ID, nil)
+				mockAMQP.On("DeclareQueue", "publisher").Return(q, nil)
+				mockSO.On("UpdateStatus", mock.Anything, model.req.ID, models.Processing).Return(nil)
+				mockAMQP.On("Publish", "", q.Name, mock.Anything).Return(nil)
 			},
 			expectedStatusCode:   202,
 			expectedResponseBody: "{\"Request ID\":\"00000000-0000-0000-0000-000000000000\"}\n",
@@ -619,18 +774,13 @@ func TestHandler_convertImage(t *testing.T) {
 			name:         "Failed upload file",
 			headerNames:  []string{"Authorization", "Content-Type"},
 			headerValues: []string{"Bearer token", "image/jpeg", `multipart/form-data; boundary="foo123"`},
+			query:        map[string]string{"format": "png"},
 			token:        "token",
-			fn: func(mockSO *mocks.ServiceOperations, mockBucket *mocks.S3Bucket, mockAMQP *mocks.AMQP, token string, model model, storage string) {
+			fn: func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP, token string, model model) {
 				asString := "00000000-0000-0000-0000-000000000000"
 				s := uuid.MustParse(asString)
 				mockSO.On("ParseToken", token).Return(s, nil)
-				switch storage {
-				case aws:
-					mockBucket.On("UploadToS3Bucket", mock.Anything, mock.Anything).Return(mock.Anything, nil)
-					mockSO.On("UploadImage", mock.Anything, mock.Anything).Return(uplImg.ID, utils.ErrUploadImageToDB)
-				case local:
-					mockSO.On("UploadImage", mock.Anything, mock.Anything).Return(uplImg.ID, utils.ErrUploadImageToDB)
-				}
+				mockSO.On("UploadImage", mock.Anything, mock.Anything).Return(uplImg.ID, utils.ErrUploadImageToDB)
 			},
 			expectedStatusCode:   500,
 			expectedResponseBody: "{\"error\":\"cannot upload the file:unable to insert image into database\"}\n",
@@ -639,24 +789,16 @@ func TestHandler_convertImage(t *testing.T) {
 			name:         "Failed update status",
 			headerNames:  []string{"Authorization", "Content-Type"},
 			headerValues: []string{"Bearer token", "image/jpeg", `multipart/form-data; boundary="foo123"`},
+			query:        map[string]string{"format": "png"},
 			token:        "token",
-			fn: func(mockSO *mocks.ServiceOperations, mockBucket *mocks.S3Bucket, mockAMQP *mocks.AMQP, token string, model model, storage string) {
-				asString := "00000000-0000-0000-0000-000000000000"
-				s := uuid.MustParse(asString)
-				mockSO.On("ParseToken", token).Return(s, nil)
-				switch storage {
-				case aws:
-					mockBucket.On("UploadToS3Bucket", mock.Anything, mock.Anything).Return(mock.Anything, nil)
-					mockSO.On("UploadImage", mock.Anything, mock.Anything).Return(model.image.ID, nil)
-					mockSO.On("CreateRequest", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.req.ID, nil)
-					mockAMQP.On("DeclareQueue", "publisher").Return(q, nil)
-					mockSO.On("UpdateStatus", mock.Anything, uplImg.ID, models.Processing).Return(utils.ErrUpdateStatusRequest)
-				case local:
-					mockSO.On("UploadImage", mock.Anything, mock.Anything).Return(uplImg.ID, nil)
-					mockSO.On("CreateRequest", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.req.ID, nil)
-					mockAMQP.On("DeclareQueue", "publisher").Return(q, nil)
-					mockSO.On("UpdateStatus", mock.Anything, model.req.ID, models.Processing).Return(utils.ErrUpdateStatusRequest)
-				}
+			fn: func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP, token string, model model) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+				mockSO.On("UploadImage", mock.Anything, mock.Anything).Return(uplImg.ID, nil)
+				mockSO.On("CreateRequest", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.req.ID, nil)
+				mockAMQP.On("DeclareQueue", "publisher").Return(q, nil)
+				mockSO.On("UpdateStatus", mock.Anything, model.req.ID, models.Processing).Return(utils.ErrUpdateStatusRequest)
 			},
 			expectedStatusCode:   500,
 			expectedResponseBody: "{\"error\":\"cannot update image status\"}\n",
@@ -665,39 +807,115 @@ func TestHandler_convertImage(t *testing.T) {
 			name:         "Failed create request",
 			headerNames:  []string{"Authorization", "Content-Type"},
 			headerValues: []string{"Bearer token", "image/jpeg", `multipart/form-data; boundary="foo123"`},
+			query:        map[string]string{"format": "png"},
 			token:        "token",
-			fn: func(mockSO *mocks.ServiceOperations, mockBucket *mocks.S3Bucket, mockAMQP *mocks.AMQP, token string, model model, storage string) {
+			fn: func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP, token string, model model) {
 				asString := "00000000-0000-0000-0000-000000000000"
 				s := uuid.MustParse(asString)
 				mockSO.On("ParseToken", token).Return(s, nil)
-				switch storage {
-				case aws:
-					mockBucket.On("UploadToS3Bucket", mock.Anything, mock.Anything).Return(mock.Anything, nil)
-					mockSO.On("UploadImage", mock.Anything, mock.Anything).Return(model.image.ID, nil)
-					mockSO.On("CreateRequest", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(s, utils.ErrCreateRequest)
-				case local:
-					mockSO.On("UploadImage", mock.Anything, mock.Anything).Return(model.image.ID, nil)
-					mockSO.On("CreateRequest", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(s, utils.ErrCreateRequest)
-				}
+				mockSO.On("UploadImage", mock.Anything, mock.Anything).Return(model.image.ID, nil)
+				mockSO.On("CreateRequest", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(s, utils.ErrCreateRequest)
 			},
 			expectedStatusCode:   500,
 			expectedResponseBody: "{\"error\":\"cannot create request\"}\n",
 		},
+		{
+			name:         "Quality out of range",
+			headerNames:  []string{"Authorization", "Content-Type"},
+			headerValues: []string{"Bearer token", "image/jpeg", `multipart/form-data; boundary="foo123"`},
+			query:        map[string]string{"format": "png", "quality": "101"},
+			token:        "token",
+			fn: func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP, token string, model model) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"quality must be between 1 and 100\"}\n",
+		},
+		{
+			name:         "PNG level out of range",
+			headerNames:  []string{"Authorization", "Content-Type"},
+			headerValues: []string{"Bearer token", "image/jpeg", `multipart/form-data; boundary="foo123"`},
+			query:        map[string]string{"format": "png", "png_level": "10"},
+			token:        "token",
+			fn: func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP, token string, model model) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"png_level must be between 0 and 9\"}\n",
+		},
+		{
+			name:         "Unsupported metadata policy",
+			headerNames:  []string{"Authorization", "Content-Type"},
+			headerValues: []string{"Bearer token", "image/jpeg", `multipart/form-data; boundary="foo123"`},
+			query:        map[string]string{"format": "png", "metadata": "all"},
+			token:        "token",
+			fn: func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP, token string, model model) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"metadata policy is not supported. Please use strip, keep or keep-safe\"}\n",
+		},
+		{
+			name:         "Invalid auto_orient",
+			headerNames:  []string{"Authorization", "Content-Type"},
+			headerValues: []string{"Bearer token", "image/jpeg", `multipart/form-data; boundary="foo123"`},
+			query:        map[string]string{"format": "png", "auto_orient": "sometimes"},
+			token:        "token",
+			fn: func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP, token string, model model) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"cannot convert string to bool\"}\n",
+		},
+		{
+			name:         "Missing target format",
+			headerNames:  []string{"Authorization", "Content-Type"},
+			headerValues: []string{"Bearer token", "image/jpeg", `multipart/form-data; boundary="foo123"`},
+			token:        "token",
+			fn: func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP, token string, model model) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"target format must not be empty\"}\n",
+		},
+		{
+			name:         "Unsupported target format",
+			headerNames:  []string{"Authorization", "Content-Type"},
+			headerValues: []string{"Bearer token", "image/jpeg", `multipart/form-data; boundary="foo123"`},
+			query:        map[string]string{"format": "svg"},
+			token:        "token",
+			fn: func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP, token string, model model) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"unsupported target format. Please use one of: bmp, gif, jpeg, png, tiff\"}\n",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			conf := utils.NewConfig()
 
 			mockAMQP := new(mocks.AMQP)
-			mockBucket := new(mocks.S3Bucket)
+			store := storage.NewFileSystem(t.TempDir())
 			mockSO := new(mocks.ServiceOperations)
 
-			currentService := NewAPI(mockSO, mockBucket)
+			currentService := NewAPI(mockSO, store, utils.NewConfig().Limits)
 			s := NewServer(mockAMQP, currentService)
 
 			s.router.HandleFunc("/api/convert",
-				s.authorize(s.convertImage())).Methods(http.MethodPost)
+				s.authorize(s.streamUpload(s.convertImage()))).Methods(http.MethodPost)
 
 			content, file := createImage(t, "filename.jpeg")
 
@@ -715,7 +933,7 @@ func TestHandler_convertImage(t *testing.T) {
 			err = file.Close()
 			require.NoError(t, err)
 
-			tt.fn(mockSO, mockBucket, mockAMQP, tt.token, modelStruct, conf.Storage)
+			tt.fn(mockSO, mockAMQP, tt.token, modelStruct)
 
 			w := httptest.NewRecorder()
 			req := httptest.NewRequest(http.MethodPost, "/api/convert", buf)
@@ -727,6 +945,12 @@ func TestHandler_convertImage(t *testing.T) {
 			req.Header.Set("Authorization", "Bearer token")
 			req.Header.Set("Content-Type", writer.FormDataContentType())
 
+			q := req.URL.Query()
+			for name, value := range tt.query {
+				q.Add(name, value)
+			}
+			req.URL.RawQuery = q.Encode()
+
 			err = req.Body.Close()
 			require.NoError(t, err)
 
@@ -740,86 +964,1267 @@ func TestHandler_convertImage(t *testing.T) {
 	}
 }
 
-func TestHandler_findStatus(t *testing.T) {
-	type fnBehavior func(mockSO *mocks.ServiceOperations, token string, compressedID uuid.UUID, isOriginal bool)
+// queuedBehavior mocks the calls of a handler that uploads the image and queues the request.
+type queuedBehavior func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP)
 
-	type params struct {
-		name       string
-		isOriginal bool
-	}
+// queuedTest is a case of a handler that uploads the image and queues the request.
+type queuedTest struct {
+	name                 string
+	query                map[string]string
+	fn                   queuedBehavior
+	expectedStatusCode   int
+	expectedResponseBody string
+}
 
-	tests := []struct {
-		name                 string
-		headerName           []string
-		headerValue          []string
-		params               params
-		token                string
-		requestID            uuid.UUID
-		fn                   fnBehavior
-		expectedStatusCode   int
-		expectedResponseBody string
-	}{
-		{
-			name:        "Find compressed image without errors",
-			headerName:  []string{"Authorization", "Content-Type"},
-			headerValue: []string{"Bearer token"},
-			token:       "token",
-			requestID:   [16]byte{00000000 - 0000 - 0000 - 0000 - 000000000000},
-			params:      params{name: "original", isOriginal: false},
-			fn: func(mockSO *mocks.ServiceOperations, token string, compressedID uuid.UUID, isOriginal bool) {
-				asString := "00000000-0000-0000-0000-000000000000"
-				s := uuid.MustParse(asString)
-				mockSO.On("ParseToken", token).Return(s, nil)
-				mockSO.On("IsAuthenticated", mock.Anything, s, s).Return(nil)
-				mockSO.On("FindRequestStatus", mock.Anything, s, compressedID).Return(models.Done, nil)
-			},
-			expectedStatusCode:   200,
-			expectedResponseBody: "{\"request_id\":\"00000000-0000-0000-0000-000000000000\",\"status\":\"done\"}\n",
-		},
-		{
-			name:        "Error cannot find status for this request",
-			headerName:  []string{"Authorization", "Content-Type"},
-			headerValue: []string{"Bearer token"},
-			token:       "token",
-			params:      params{name: "original", isOriginal: false},
-			requestID:   [16]byte{00000000 - 0000 - 0000 - 0000 - 000000000000},
-			fn: func(mockSO *mocks.ServiceOperations, token string, compressedID uuid.UUID, isOriginal bool) {
-				asString := "00000000-0000-0000-0000-000000000000"
-				s := uuid.MustParse(asString)
-				mockSO.On("ParseToken", token).Return(s, nil)
-				mockSO.On("IsAuthenticated", mock.Anything, s, s).Return(nil)
-				mockSO.On("FindRequestStatus", mock.Anything, s, compressedID).Return(models.Status(""), utils.ErrGetStatus)
-			},
-			expectedStatusCode:   404,
-			expectedResponseBody: "{\"error\":\"cannot find status for this request\"}\n",
-		},
+// expectToken mocks the authorization of the user, the request is rejected before the upload.
+func expectToken() queuedBehavior {
+	return func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP) {
+		mockSO.On("ParseToken", "token").Return(uuid.Nil, nil)
 	}
+}
 
-	getCompressedURL := "/api/compress/%s"
+// expectQueued mocks the upload of the image and the publishing of the message that matches the argument.
+func expectQueued(message interface{}) queuedBehavior {
+	return func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP) {
+		q := amqp.Queue{Name: "", Messages: 1, Consumers: 1}
+
+		mockSO.On("ParseToken", "token").Return(uuid.Nil, nil)
+		mockSO.On("UploadImage", mock.Anything, mock.Anything).Return(uuid.Nil, nil)
+		mockSO.On("CreateRequest", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(uuid.Nil, nil)
+		mockAMQP.On("DeclareQueue", "publisher").Return(q, nil)
+		mockSO.On("UpdateStatus", mock.Anything, uuid.Nil, models.Processing).Return(nil)
+		mockAMQP.On("Publish", "", q.Name, message).Return(nil)
+	}
+}
 
+// runQueuedTests posts an image with the query of each case to the handler.
+func runQueuedTests(t *testing.T, path string, handler func(s *Server) http.HandlerFunc, tests []queuedTest) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockBucket := new(mocks.S3Bucket)
-			mockSO := new(mocks.ServiceOperations)
 
-			currentService := NewAPI(mockSO, mockBucket)
-			mq := broker.NewAMQPBrokerAPI()
+			mockAMQP := new(mocks.AMQP)
+			store := storage.NewFileSystem(t.TempDir())
+			mockSO := new(mocks.ServiceOperations)
 
-			s := NewServer(mq, currentService)
+			currentService := NewAPI(mockSO, store, utils.NewConfig().Limits)
+			s := NewServer(mockAMQP, currentService)
 
-			tt.fn(mockSO, tt.token, tt.requestID, tt.params.isOriginal)
+			s.router.HandleFunc(path,
+				s.authorize(s.streamUpload(handler(s)))).Methods(http.MethodPost)
 
-			s.router.HandleFunc(fmt.Sprintf(getCompressedURL, "{requestID}"),
-				s.authorize(s.findStatus())).Methods(http.MethodGet)
+			content, file := createImage(t, "filename.jpeg")
 
-			w := httptest.NewRecorder()
-			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf(getCompressedURL, tt.requestID), nil)
+			buf := &bytes.Buffer{}
+			writer := multipart.NewWriter(buf)
+			header := make(textproto.MIMEHeader)
+			header.Set("Content-Disposition", `form-data; name="uploadFile"; filename="filename.jpeg"`)
+			header.Set("Content-Type", "image/jpeg")
+			part, err := writer.CreatePart(header)
+			require.NoError(t, err)
+			_, err = io.Copy(part, bytes.NewReader(content))
+			require.NoError(t, err)
+			err = writer.Close()
+			require.NoError(t, err)
+			err = file.Close()
+			require.NoError(t, err)
+
+			tt.fn(mockSO, mockAMQP)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, path, buf)
+
+			body, err := ioutil.ReadAll(req.Body)
+			require.NoError(t, err)
+			req.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+
+			req.Header.Set("Authorization", "Bearer token")
+			req.Header.Set("Content-Type", writer.FormDataContentType())
 
 			q := req.URL.Query()
-			q.Add(tt.params.name, strconv.FormatBool(tt.params.isOriginal))
+			for name, value := range tt.query {
+				q.Add(name, value)
+			}
 			req.URL.RawQuery = q.Encode()
 
-			req.Header.Set(tt.headerName[0], tt.headerValue[0])
+			err = req.Body.Close()
+			require.NoError(t, err)
+
+			s.ServeHTTP(w, req)
+			mockSO.AssertExpectations(t)
+			require.Equal(t, tt.expectedStatusCode, w.Code)
+			require.Equal(t, tt.expectedResponseBody, w.Body.String())
+
+			cleanAfterTest(t)
+		})
+	}
+}
+
+func TestHandler_cropImage(t *testing.T) {
+	tests := []queuedTest{
+		{
+			name:                 "Crop rectangle without errors",
+			query:                map[string]string{"x": "10", "y": "10", "width": "100", "height": "50"},
+			fn:                   expectQueued(mock.Anything),
+			expectedStatusCode:   202,
+			expectedResponseBody: "{\"Request ID\":\"00000000-0000-0000-0000-000000000000\"}\n",
+		},
+		{
+			name:                 "Crop aspect ratio without errors",
+			query:                map[string]string{"aspect": "16:9", "gravity": "north-east"},
+			fn:                   expectQueued(mock.Anything),
+			expectedStatusCode:   202,
+			expectedResponseBody: "{\"Request ID\":\"00000000-0000-0000-0000-000000000000\"}\n",
+		},
+		{
+			name:                 "Crop aspect ratio with smart gravity without errors",
+			query:                map[string]string{"aspect": "1:1", "gravity": "smart"},
+			fn:                   expectQueued(mock.Anything),
+			expectedStatusCode:   202,
+			expectedResponseBody: "{\"Request ID\":\"00000000-0000-0000-0000-000000000000\"}\n",
+		},
+		{
+			name:                 "Missing crop parameters",
+			query:                map[string]string{},
+			fn:                   expectToken(),
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"crop requires either width and height or aspect ratio\"}\n",
+		},
+		{
+			name:                 "Both rectangle and aspect ratio",
+			query:                map[string]string{"width": "100", "height": "50", "aspect": "1:1"},
+			fn:                   expectToken(),
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"crop requires either width and height or aspect ratio\"}\n",
+		},
+		{
+			name:                 "Negative offset",
+			query:                map[string]string{"x": "-1", "width": "100", "height": "50"},
+			fn:                   expectToken(),
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"width and height must not be negative\"}\n",
+		},
+		{
+			name:                 "Incorrect aspect ratio",
+			query:                map[string]string{"aspect": "16x9"},
+			fn:                   expectToken(),
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"aspect ratio is incorrect. Please use W:H notation, e.g. 16:9\"}\n",
+		},
+		{
+			name:                 "Unsupported gravity",
+			query:                map[string]string{"aspect": "1:1", "gravity": "top"},
+			fn:                   expectToken(),
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"gravity is not supported. Please use center, north, south, east, west, north-east, north-west, south-east, south-west or smart\"}\n",
+		},
+	}
+
+	runQueuedTests(t, "/api/crop", func(s *Server) http.HandlerFunc { return s.cropImage() }, tests)
+}
+
+func TestHandler_pipelineImage(t *testing.T) {
+	tests := []queuedTest{
+		{
+			name:                 "Run pipeline without errors",
+			query:                map[string]string{"steps": `[{"op": "crop", "aspect": "4:3"}, {"op": "resize", "width": 800, "height": 600}, {"op": "grayscale"}, {"op": "convert", "format": "jpeg", "quality": 82}]`},
+			fn:                   expectQueued(mock.Anything),
+			expectedStatusCode:   202,
+			expectedResponseBody: "{\"Request ID\":\"00000000-0000-0000-0000-000000000000\"}\n",
+		},
+		{
+			name:  "Run pipeline with text watermark",
+			query: map[string]string{"steps": `[{"op": "resize", "width": 800}, {"op": "watermark", "text": "© Studio", "position": "top-left", "opacity": 0.8}]`},
+			fn: func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP) {
+				expectQueued(mock.MatchedBy(func(message models.QueuedMessage) bool {
+					wm := message.Steps[1].Watermark
+					return wm.Text == "© Studio" && wm.Position == models.TopLeft && wm.Opacity == 0.8 && wm.Scale == DefaultWatermarkScale
+				}))(mockSO, mockAMQP)
+			},
+			expectedStatusCode:   202,
+			expectedResponseBody: "{\"Request ID\":\"00000000-0000-0000-0000-000000000000\"}\n",
+		},
+		{
+			name:  "Run pipeline with saved watermark",
+			query: map[string]string{"steps": `[{"op": "watermark", "margin": 8}]`},
+			fn: func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP) {
+				expectQueued(mock.MatchedBy(func(message models.QueuedMessage) bool {
+					wm := message.Steps[0].Watermark
+					return wm.Text == "saved" && wm.Position == models.Tiled && wm.Margin == 8
+				}))(mockSO, mockAMQP)
+				mockSO.On("FindWatermark", mock.Anything, uuid.Nil).Return(models.Watermark{Text: "saved", Color: "#ffffff", Position: models.Tiled, Opacity: 0.3, Margin: 16, Scale: 0.2}, nil)
+			},
+			expectedStatusCode:   202,
+			expectedResponseBody: "{\"Request ID\":\"00000000-0000-0000-0000-000000000000\"}\n",
+		},
+		{
+			name:  "Watermark step without text or saved watermark",
+			query: map[string]string{"steps": `[{"op": "watermark"}]`},
+			fn: func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP) {
+				expectToken()(mockSO, mockAMQP)
+				mockSO.On("FindWatermark", mock.Anything, uuid.Nil).Return(models.Watermark{}, utils.ErrWatermarkNotFound)
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"step 1: watermark requires either a logo or a text, or a saved default watermark\"}\n",
+		},
+		{
+			name:                 "Missing steps",
+			query:                map[string]string{},
+			fn:                   expectToken(),
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"steps must be a JSON array of 1 to 20 operations\"}\n",
+		},
+		{
+			name:                 "Unknown step field",
+			query:                map[string]string{"steps": `[{"op": "resize", "widht": 800}]`},
+			fn:                   expectToken(),
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"steps must be a JSON array of 1 to 20 operations\"}\n",
+		},
+		{
+			name:                 "Unsupported operation",
+			query:                map[string]string{"steps": `[{"op": "sharpen"}]`},
+			fn:                   expectToken(),
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"step 1: operation is not supported. Please use resize, crop, transform, grayscale, filter, watermark or convert\"}\n",
+		},
+		{
+			name:                 "Convert before the last step",
+			query:                map[string]string{"steps": `[{"op": "convert", "format": "png"}, {"op": "grayscale"}]`},
+			fn:                   expectToken(),
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"step 1: convert can only be the last step\"}\n",
+		},
+		{
+			name:                 "Resize without size",
+			query:                map[string]string{"steps": `[{"op": "grayscale"}, {"op": "resize"}]`},
+			fn:                   expectToken(),
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"step 2: resize requires width or height\"}\n",
+		},
+		{
+			name:                 "Quality of the convert step out of range",
+			query:                map[string]string{"steps": `[{"op": "convert", "format": "jpeg", "quality": 0}]`},
+			fn:                   expectToken(),
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"quality must be between 1 and 100\"}\n",
+		},
+	}
+
+	runQueuedTests(t, "/api/pipeline", func(s *Server) http.HandlerFunc { return s.pipelineImage() }, tests)
+}
+
+func TestHandler_filterImage(t *testing.T) {
+	tests := []queuedTest{
+		{
+			name:                 "Filter image without errors",
+			query:                map[string]string{"filters": `[{"filter": "blur", "radius": 2}, {"filter": "contrast", "value": 15}, {"filter": "sepia"}]`},
+			fn:                   expectQueued(mock.Anything),
+			expectedStatusCode:   202,
+			expectedResponseBody: "{\"Request ID\":\"00000000-0000-0000-0000-000000000000\"}\n",
+		},
+		{
+			name:                 "Filter image and convert without errors",
+			query:                map[string]string{"filters": `[{"filter": "sharpen", "value": 1.5}]`, "format": "png"},
+			fn:                   expectQueued(mock.Anything),
+			expectedStatusCode:   202,
+			expectedResponseBody: "{\"Request ID\":\"00000000-0000-0000-0000-000000000000\"}\n",
+		},
+		{
+			name:                 "Missing filters",
+			query:                map[string]string{},
+			fn:                   expectToken(),
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"filters must be a JSON array of 1 to 20 adjustments\"}\n",
+		},
+		{
+			name:                 "Unsupported filter",
+			query:                map[string]string{"filters": `[{"filter": "emboss"}]`},
+			fn:                   expectToken(),
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"filter is not supported. Please use grayscale, sepia, blur, sharpen, brightness, contrast, gamma or saturation\"}\n",
+		},
+		{
+			name:                 "Filter value out of range",
+			query:                map[string]string{"filters": `[{"filter": "brightness", "value": 150}]`},
+			fn:                   expectToken(),
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"brightness: filter value is out of range\"}\n",
+		},
+		{
+			name:                 "Blur without radius",
+			query:                map[string]string{"filters": `[{"filter": "blur"}]`},
+			fn:                   expectToken(),
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"blur: filter value is out of range\"}\n",
+		},
+	}
+
+	runQueuedTests(t, "/api/filter", func(s *Server) http.HandlerFunc { return s.filterImage() }, tests)
+}
+
+func TestHandler_thumbnailImage(t *testing.T) {
+	tests := []queuedTest{
+		{
+			name:                 "Create thumbnails without errors",
+			query:                map[string]string{"widths": "320,640,1280,1920", "formats": "jpeg,png"},
+			fn:                   expectQueued(mock.Anything),
+			expectedStatusCode:   202,
+			expectedResponseBody: "{\"Request ID\":\"00000000-0000-0000-0000-000000000000\"}\n",
+		},
+		{
+			name:                 "Create thumbnails in the original format without errors",
+			query:                map[string]string{"widths": "320, 640"},
+			fn:                   expectQueued(mock.Anything),
+			expectedStatusCode:   202,
+			expectedResponseBody: "{\"Request ID\":\"00000000-0000-0000-0000-000000000000\"}\n",
+		},
+		{
+			name:                 "Missing widths",
+			query:                map[string]string{},
+			fn:                   expectToken(),
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"widths must be a comma separated list of 1 to 10 widths between 1 and 10000\"}\n",
+		},
+		{
+			name:                 "Incorrect width",
+			query:                map[string]string{"widths": "320,wide"},
+			fn:                   expectToken(),
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"widths must be a comma separated list of 1 to 10 widths between 1 and 10000\"}\n",
+		},
+		{
+			name:                 "Width out of range",
+			query:                map[string]string{"widths": "0,640"},
+			fn:                   expectToken(),
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"widths must be a comma separated list of 1 to 10 widths between 1 and 10000\"}\n",
+		},
+		{
+			name:                 "Too many formats",
+			query:                map[string]string{"widths": "320", "formats": "jpeg,png,gif,bmp,tiff,jpeg"},
+			fn:                   expectToken(),
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"formats must be a comma separated list of up to 5 formats\"}\n",
+		},
+	}
+
+	runQueuedTests(t, "/api/thumbnails", func(s *Server) http.HandlerFunc { return s.thumbnailImage() }, tests)
+}
+
+func TestHandler_paletteImage(t *testing.T) {
+	tests := []queuedTest{
+		{
+			name:                 "Find palette without errors",
+			query:                map[string]string{"colors": "8"},
+			fn:                   expectQueued(mock.Anything),
+			expectedStatusCode:   202,
+			expectedResponseBody: "{\"Request ID\":\"00000000-0000-0000-0000-000000000000\"}\n",
+		},
+		{
+			name:                 "Find palette with default colors",
+			query:                map[string]string{},
+			fn:                   expectQueued(mock.Anything),
+			expectedStatusCode:   202,
+			expectedResponseBody: "{\"Request ID\":\"00000000-0000-0000-0000-000000000000\"}\n",
+		},
+		{
+			name:                 "Too many colors",
+			query:                map[string]string{"colors": "17"},
+			fn:                   expectToken(),
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"colors must be between 1 and 16\"}\n",
+		},
+		{
+			name:                 "Incorrect colors",
+			query:                map[string]string{"colors": "many"},
+			fn:                   expectToken(),
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"colors must be between 1 and 16\"}\n",
+		},
+	}
+
+	runQueuedTests(t, "/api/palette", func(s *Server) http.HandlerFunc { return s.paletteImage() }, tests)
+}
+func TestHandler_watermarkImage(t *testing.T) {
+	tests := []queuedTest{
+		{
+			name:                 "Watermark with text without errors",
+			query:                map[string]string{"text": "© Stock", "position": "tiled", "opacity": "0.3"},
+			fn:                   expectQueued(mock.Anything),
+			expectedStatusCode:   202,
+			expectedResponseBody: "{\"Request ID\":\"00000000-0000-0000-0000-000000000000\"}\n",
+		},
+		{
+			name:  "Watermark with saved default without errors",
+			query: map[string]string{"scale": "0.1"},
+			fn: func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP) {
+				expectQueued(mock.Anything)(mockSO, mockAMQP)
+				mockSO.On("FindWatermark", mock.Anything, uuid.Nil).Return(models.Watermark{Text: "preview", Color: "ffffff", Position: models.TopLeft, Opacity: 0.3, Margin: 8, Scale: 0.5}, nil)
+			},
+			expectedStatusCode:   202,
+			expectedResponseBody: "{\"Request ID\":\"00000000-0000-0000-0000-000000000000\"}\n",
+		},
+		{
+			name:  "Missing watermark",
+			query: map[string]string{},
+			fn: func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP) {
+				expectToken()(mockSO, mockAMQP)
+				mockSO.On("FindWatermark", mock.Anything, uuid.Nil).Return(models.Watermark{}, utils.ErrWatermarkNotFound)
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"watermark requires either a logo or a text, or a saved default watermark\"}\n",
+		},
+		{
+			name:  "Cannot find saved watermark",
+			query: map[string]string{},
+			fn: func(mockSO *mocks.ServiceOperations, mockAMQP *mocks.AMQP) {
+				expectToken()(mockSO, mockAMQP)
+				mockSO.On("FindWatermark", mock.Anything, uuid.Nil).Return(models.Watermark{}, utils.ErrFindWatermark)
+			},
+			expectedStatusCode:   500,
+			expectedResponseBody: "{\"error\":\"cannot find watermark\"}\n",
+		},
+		{
+			name:                 "Unsupported position",
+			query:                map[string]string{"text": "preview", "position": "middle"},
+			fn:                   expectToken(),
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"position is not supported. Please use top-left, top-right, bottom-left, bottom-right, center or tiled\"}\n",
+		},
+		{
+			name:                 "Opacity out of range",
+			query:                map[string]string{"text": "preview", "opacity": "1.5"},
+			fn:                   expectToken(),
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"opacity must be greater than 0 and at most 1\"}\n",
+		},
+		{
+			name:                 "Incorrect text color",
+			query:                map[string]string{"text": "preview", "color": "white"},
+			fn:                   expectToken(),
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"text color is incorrect. Please use RGB, RRGGBB or RRGGBBAA hex notation\"}\n",
+		},
+	}
+
+	runQueuedTests(t, "/api/watermark", func(s *Server) http.HandlerFunc { return s.watermarkImage() }, tests)
+}
+func TestHandler_transformImage(t *testing.T) {
+	tests := []queuedTest{
+		{
+			name:                 "Rotate image without errors",
+			query:                map[string]string{"rotate": "90"},
+			fn:                   expectQueued(mock.Anything),
+			expectedStatusCode:   202,
+			expectedResponseBody: "{\"Request ID\":\"00000000-0000-0000-0000-000000000000\"}\n",
+		},
+		{
+			name:                 "Rotate by an angle and flip without errors",
+			query:                map[string]string{"rotate": "12.5", "flip": "vertical", "background": "000"},
+			fn:                   expectQueued(mock.Anything),
+			expectedStatusCode:   202,
+			expectedResponseBody: "{\"Request ID\":\"00000000-0000-0000-0000-000000000000\"}\n",
+		},
+		{
+			name:                 "Missing transformation",
+			query:                map[string]string{},
+			fn:                   expectToken(),
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"transform requires rotate or flip\"}\n",
+		},
+		{
+			name:                 "Incorrect angle",
+			query:                map[string]string{"rotate": "right"},
+			fn:                   expectToken(),
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"cannot convert string to float\"}\n",
+		},
+		{
+			name:                 "Unsupported flip",
+			query:                map[string]string{"flip": "diagonal"},
+			fn:                   expectToken(),
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"flip is not supported. Please use horizontal, vertical or both\"}\n",
+		},
+		{
+			name:                 "Incorrect background",
+			query:                map[string]string{"rotate": "30", "background": "blue"},
+			fn:                   expectToken(),
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"background color is incorrect. Please use RGB, RRGGBB or RRGGBBAA hex notation\"}\n",
+		},
+	}
+
+	runQueuedTests(t, "/api/transform", func(s *Server) http.HandlerFunc { return s.transformImage() }, tests)
+}
+
+func TestHandler_findStatus(t *testing.T) {
+	type fnBehavior func(mockSO *mocks.ServiceOperations, token string, compressedID uuid.UUID, isOriginal bool)
+
+	type params struct {
+		name       string
+		isOriginal bool
+	}
+
+	tests := []struct {
+		name                 string
+		headerName           []string
+		headerValue          []string
+		params               params
+		token                string
+		requestID            uuid.UUID
+		fn                   fnBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:        "Find compressed image without errors",
+			headerName:  []string{"Authorization", "Content-Type"},
+			headerValue: []string{"Bearer token"},
+			token:       "token",
+			requestID:   [16]byte{00000000 - 0000 - 0000 - 0000 - 000000000000},
+			params:      params{name: "original", isOriginal: false},
+			fn: func(mockSO *mocks.ServiceOperations, token string, compressedID uuid.UUID, isOriginal bool) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+				mockSO.On("IsAuthenticated", mock.Anything, s, s).Return(nil)
+				mockSO.On("FindRequest", mock.Anything, s, compressedID).Return(models.Request{ServiceName: models.Compression, Status: models.Done}, nil)
+				mockSO.On("FindResultedImage", mock.Anything, compressedID).Return(models.Image{BlurHash: "LEHV6nWB2yk8pyo0adR*.7kCMdnj", Placeholder: "data:image/jpeg;base64,", UploadedSize: 2048, ResultedSize: 512}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: "{\"request_id\":\"00000000-0000-0000-0000-000000000000\",\"status\":\"done\",\"blurhash\":\"LEHV6nWB2yk8pyo0adR*.7kCMdnj\",\"placeholder\":\"data:image/jpeg;base64,\",\"uploaded_size\":2048,\"resulted_size\":512,\"size_saved\":1536}\n",
+		},
+		{
+			name:        "Find status of palette request without errors",
+			headerName:  []string{"Authorization", "Content-Type"},
+			headerValue: []string{"Bearer token"},
+			token:       "token",
+			requestID:   [16]byte{00000000 - 0000 - 0000 - 0000 - 000000000000},
+			params:      params{name: "original", isOriginal: false},
+			fn: func(mockSO *mocks.ServiceOperations, token string, compressedID uuid.UUID, isOriginal bool) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+				mockSO.On("IsAuthenticated", mock.Anything, s, s).Return(nil)
+				mockSO.On("FindRequest", mock.Anything, s, compressedID).Return(models.Request{ServiceName: models.PaletteAnalysis, Status: models.Done}, nil)
+				mockSO.On("FindPalette", mock.Anything, compressedID).Return([]models.PaletteColor{
+					{Color: "#f0f0f0", Coverage: 62.5},
+					{Color: "#c81e1e", Coverage: 25},
+					{Color: "#141ec8", Coverage: 12.5},
+				}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: "{\"request_id\":\"00000000-0000-0000-0000-000000000000\",\"status\":\"done\",\"palette\":[{\"color\":\"#f0f0f0\",\"coverage\":62.5},{\"color\":\"#c81e1e\",\"coverage\":25},{\"color\":\"#141ec8\",\"coverage\":12.5}]}\n",
+		},
+		{
+			name:        "Error cannot find palette of done request",
+			headerName:  []string{"Authorization", "Content-Type"},
+			headerValue: []string{"Bearer token"},
+			token:       "token",
+			requestID:   [16]byte{00000000 - 0000 - 0000 - 0000 - 000000000000},
+			params:      params{name: "original", isOriginal: false},
+			fn: func(mockSO *mocks.ServiceOperations, token string, compressedID uuid.UUID, isOriginal bool) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+				mockSO.On("IsAuthenticated", mock.Anything, s, s).Return(nil)
+				mockSO.On("FindRequest", mock.Anything, s, compressedID).Return(models.Request{ServiceName: models.PaletteAnalysis, Status: models.Done}, nil)
+				mockSO.On("FindPalette", mock.Anything, compressedID).Return(nil, utils.ErrFindPalette)
+			},
+			expectedStatusCode:   500,
+			expectedResponseBody: "{\"error\":\"cannot find palette\"}\n",
+		},
+		{
+			name:        "Find status of queued request without placeholders",
+			headerName:  []string{"Authorization", "Content-Type"},
+			headerValue: []string{"Bearer token"},
+			token:       "token",
+			requestID:   [16]byte{00000000 - 0000 - 0000 - 0000 - 000000000000},
+			params:      params{name: "original", isOriginal: false},
+			fn: func(mockSO *mocks.ServiceOperations, token string, compressedID uuid.UUID, isOriginal bool) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+				mockSO.On("IsAuthenticated", mock.Anything, s, s).Return(nil)
+				mockSO.On("FindRequest", mock.Anything, s, compressedID).Return(models.Request{ServiceName: models.Compression, Status: models.Queued}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: "{\"request_id\":\"00000000-0000-0000-0000-000000000000\",\"status\":\"queued\"}\n",
+		},
+		{
+			name:        "Error cannot find status for this request",
+			headerName:  []string{"Authorization", "Content-Type"},
+			headerValue: []string{"Bearer token"},
+			token:       "token",
+			params:      params{name: "original", isOriginal: false},
+			requestID:   [16]byte{00000000 - 0000 - 0000 - 0000 - 000000000000},
+			fn: func(mockSO *mocks.ServiceOperations, token string, compressedID uuid.UUID, isOriginal bool) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+				mockSO.On("IsAuthenticated", mock.Anything, s, s).Return(nil)
+				mockSO.On("FindRequest", mock.Anything, s, compressedID).Return(models.Request{}, utils.ErrGetStatus)
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: "{\"error\":\"cannot find status for this request\"}\n",
+		},
+	}
+
+	getCompressedURL := "/api/compress/%s"
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := storage.NewFileSystem(t.TempDir())
+			mockSO := new(mocks.ServiceOperations)
+
+			currentService := NewAPI(mockSO, store, utils.NewConfig().Limits)
+			mq := broker.NewAMQPBrokerAPI()
+
+			s := NewServer(mq, currentService)
+
+			tt.fn(mockSO, tt.token, tt.requestID, tt.params.isOriginal)
+
+			s.router.HandleFunc(fmt.Sprintf(getCompressedURL, "{requestID}"),
+				s.authorize(s.findStatus())).Methods(http.MethodGet)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf(getCompressedURL, tt.requestID), nil)
+
+			q := req.URL.Query()
+			q.Add(tt.params.name, strconv.FormatBool(tt.params.isOriginal))
+			req.URL.RawQuery = q.Encode()
+
+			req.Header.Set(tt.headerName[0], tt.headerValue[0])
+
+			s.ServeHTTP(w, req)
+			mockSO.AssertExpectations(t)
+			require.Equal(t, tt.expectedStatusCode, w.Code)
+			require.Equal(t, tt.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_saveWatermark(t *testing.T) {
+	type fnBehavior func(mockSO *mocks.ServiceOperations, token string)
+
+	tests := []struct {
+		name                 string
+		token                string
+		query                map[string]string
+		fn                   fnBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:  "Save watermark without errors",
+			token: "token",
+			query: map[string]string{"text": "preview", "position": "center"},
+			fn: func(mockSO *mocks.ServiceOperations, token string) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+				mockSO.On("SaveWatermark", mock.Anything, s, models.Watermark{Text: "preview", Color: "ffffff", Position: models.Centered, Opacity: 0.5, Margin: 16, Scale: 0.25}).Return(nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: "{\"text\":\"preview\",\"color\":\"ffffff\",\"position\":\"center\",\"opacity\":0.5,\"margin\":16,\"scale\":0.25,\"has_logo\":false}\n",
+		},
+		{
+			name:  "Missing logo and text",
+			token: "token",
+			query: map[string]string{"position": "center"},
+			fn: func(mockSO *mocks.ServiceOperations, token string) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"watermark requires either a logo or a text, or a saved default watermark\"}\n",
+		},
+		{
+			name:  "Negative margin",
+			token: "token",
+			query: map[string]string{"text": "preview", "margin": "-1"},
+			fn: func(mockSO *mocks.ServiceOperations, token string) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"margin must not be negative\"}\n",
+		},
+		{
+			name:  "Error cannot save watermark",
+			token: "token",
+			query: map[string]string{"text": "preview"},
+			fn: func(mockSO *mocks.ServiceOperations, token string) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+				mockSO.On("SaveWatermark", mock.Anything, s, mock.Anything).Return(utils.ErrSaveWatermark)
+			},
+			expectedStatusCode:   500,
+			expectedResponseBody: "{\"error\":\"cannot save watermark\"}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := storage.NewFileSystem(t.TempDir())
+			mockSO := new(mocks.ServiceOperations)
+
+			currentService := NewAPI(mockSO, store, utils.NewConfig().Limits)
+			mq := broker.NewAMQPBrokerAPI()
+
+			s := NewServer(mq, currentService)
+
+			tt.fn(mockSO, tt.token)
+
+			s.router.HandleFunc("/api/watermark/default",
+				s.authorize(s.saveWatermark())).Methods(http.MethodPut)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPut, "/api/watermark/default", nil)
+
+			q := req.URL.Query()
+			for name, value := range tt.query {
+				q.Add(name, value)
+			}
+			req.URL.RawQuery = q.Encode()
+
+			req.Header.Set("Authorization", "Bearer token")
+
+			s.ServeHTTP(w, req)
+			mockSO.AssertExpectations(t)
+			require.Equal(t, tt.expectedStatusCode, w.Code)
+			require.Equal(t, tt.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_findWatermark(t *testing.T) {
+	type fnBehavior func(mockSO *mocks.ServiceOperations, token string)
+
+	tests := []struct {
+		name                 string
+		token                string
+		fn                   fnBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:  "Find watermark without errors",
+			token: "token",
+			fn: func(mockSO *mocks.ServiceOperations, token string) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+				mockSO.On("FindWatermark", mock.Anything, s).Return(models.Watermark{Logo: []byte("logo"), Position: models.Tiled, Opacity: 0.3, Margin: 8, Scale: 0.1}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: "{\"position\":\"tiled\",\"opacity\":0.3,\"margin\":8,\"scale\":0.1,\"has_logo\":true}\n",
+		},
+		{
+			name:  "Error watermark not found",
+			token: "token",
+			fn: func(mockSO *mocks.ServiceOperations, token string) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+				mockSO.On("FindWatermark", mock.Anything, s).Return(models.Watermark{}, utils.ErrWatermarkNotFound)
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: "{\"error\":\"no default watermark is saved\"}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := storage.NewFileSystem(t.TempDir())
+			mockSO := new(mocks.ServiceOperations)
+
+			currentService := NewAPI(mockSO, store, utils.NewConfig().Limits)
+			mq := broker.NewAMQPBrokerAPI()
+
+			s := NewServer(mq, currentService)
+
+			tt.fn(mockSO, tt.token)
+
+			s.router.HandleFunc("/api/watermark/default",
+				s.authorize(s.findWatermark())).Methods(http.MethodGet)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/watermark/default", nil)
+			req.Header.Set("Authorization", "Bearer token")
+
+			s.ServeHTTP(w, req)
+			mockSO.AssertExpectations(t)
+			require.Equal(t, tt.expectedStatusCode, w.Code)
+			require.Equal(t, tt.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_deleteWatermark(t *testing.T) {
+	type fnBehavior func(mockSO *mocks.ServiceOperations, token string)
+
+	tests := []struct {
+		name                 string
+		token                string
+		fn                   fnBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:  "Delete watermark without errors",
+			token: "token",
+			fn: func(mockSO *mocks.ServiceOperations, token string) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+				mockSO.On("DeleteWatermark", mock.Anything, s).Return(nil)
+			},
+			expectedStatusCode:   204,
+			expectedResponseBody: "",
+		},
+		{
+			name:  "Error watermark not found",
+			token: "token",
+			fn: func(mockSO *mocks.ServiceOperations, token string) {
+				asString := "00000000-0000-0000-0000-000000000000"
+				s := uuid.MustParse(asString)
+				mockSO.On("ParseToken", token).Return(s, nil)
+				mockSO.On("DeleteWatermark", mock.Anything, s).Return(utils.ErrWatermarkNotFound)
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: "{\"error\":\"no default watermark is saved\"}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := storage.NewFileSystem(t.TempDir())
+			mockSO := new(mocks.ServiceOperations)
+
+			currentService := NewAPI(mockSO, store, utils.NewConfig().Limits)
+			mq := broker.NewAMQPBrokerAPI()
+
+			s := NewServer(mq, currentService)
+
+			tt.fn(mockSO, tt.token)
+
+			s.router.HandleFunc("/api/watermark/default",
+				s.authorize(s.deleteWatermark())).Methods(http.MethodDelete)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodDelete, "/api/watermark/default", nil)
+			req.Header.Set("Authorization", "Bearer token")
+
+			s.ServeHTTP(w, req)
+			mockSO.AssertExpectations(t)
+			require.Equal(t, tt.expectedStatusCode, w.Code)
+			require.Equal(t, tt.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_findThumbnails(t *testing.T) {
+	type fnBehavior func(mockSO *mocks.ServiceOperations, token string, requestID uuid.UUID)
+
+	asString := "00000000-0000-0000-0000-000000000000"
+	id := uuid.MustParse(asString)
+	renditions := []models.Rendition{
+		{ID: id, Width: 320, Height: 240, Format: "jpeg", ResultedName: "320w-thb-filename.jpeg"},
+		{ID: id, Width: 640, Height: 480, Format: "jpeg", ResultedName: "640w-thb-filename.jpeg"},
+		{ID: id, Width: 320, Height: 240, Format: "png", ResultedName: "320w-thb-filename.png"},
+	}
+
+	tests := []struct {
+		name                 string
+		token                string
+		requestID            string
+		fn                   fnBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:      "Find thumbnails without errors",
+			token:     "token",
+			requestID: asString,
+			fn: func(mockSO *mocks.ServiceOperations, token string, requestID uuid.UUID) {
+				mockSO.On("ParseToken", token).Return(id, nil)
+				mockSO.On("IsAuthenticated", mock.Anything, id, requestID).Return(nil)
+				mockSO.On("FindRequestStatus", mock.Anything, id, requestID).Return(models.Done, nil)
+				mockSO.On("FindRenditions", mock.Anything, requestID).Return(renditions, nil)
+			},
+			expectedStatusCode: 200,
+			expectedResponseBody: "{\"request_id\":\"" + asString + "\",\"renditions\":[" +
+				"{\"id\":\"" + asString + "\",\"width\":320,\"height\":240,\"format\":\"jpeg\",\"resulted_name\":\"320w-thb-filename.jpeg\",\"url\":\"/api/thumbnails/" + asString + "/" + asString + "\"}," +
+				"{\"id\":\"" + asString + "\",\"width\":640,\"height\":480,\"format\":\"jpeg\",\"resulted_name\":\"640w-thb-filename.jpeg\",\"url\":\"/api/thumbnails/" + asString + "/" + asString + "\"}," +
+				"{\"id\":\"" + asString + "\",\"width\":320,\"height\":240,\"format\":\"png\",\"resulted_name\":\"320w-thb-filename.png\",\"url\":\"/api/thumbnails/" + asString + "/" + asString + "\"}]," +
+				"\"srcset\":{\"jpeg\":\"/api/thumbnails/" + asString + "/" + asString + " 320w, /api/thumbnails/" + asString + "/" + asString + " 640w\"," +
+				"\"png\":\"/api/thumbnails/" + asString + "/" + asString + " 320w\"}}\n",
+		},
+		{
+			name:      "Error image is being processed",
+			token:     "token",
+			requestID: asString,
+			fn: func(mockSO *mocks.ServiceOperations, token string, requestID uuid.UUID) {
+				mockSO.On("ParseToken", token).Return(id, nil)
+				mockSO.On("IsAuthenticated", mock.Anything, id, requestID).Return(nil)
+				mockSO.On("FindRequestStatus", mock.Anything, id, requestID).Return(models.Processing, nil)
+			},
+			expectedStatusCode:   409,
+			expectedResponseBody: "{\"error\":\"cannot get thumbnails:the image is being processed at the moment\"}\n",
+		},
+		{
+			name:      "Error access denied",
+			token:     "token",
+			requestID: asString,
+			fn: func(mockSO *mocks.ServiceOperations, token string, requestID uuid.UUID) {
+				mockSO.On("ParseToken", token).Return(id, nil)
+				mockSO.On("IsAuthenticated", mock.Anything, id, requestID).Return(utils.ErrUserAuthentication)
+			},
+			expectedStatusCode:   403,
+			expectedResponseBody: "{\"error\":\"access denied\"}\n",
+		},
+		{
+			name:      "Error incorrect request id",
+			token:     "token",
+			requestID: "request",
+			fn: func(mockSO *mocks.ServiceOperations, token string, requestID uuid.UUID) {
+				mockSO.On("ParseToken", token).Return(id, nil)
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"invalid path in request\"}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := storage.NewFileSystem(t.TempDir())
+			mockSO := new(mocks.ServiceOperations)
+
+			currentService := NewAPI(mockSO, store, utils.NewConfig().Limits)
+			mq := broker.NewAMQPBrokerAPI()
+
+			s := NewServer(mq, currentService)
+
+			requestID, _ := uuid.Parse(tt.requestID)
+			tt.fn(mockSO, tt.token, requestID)
+
+			s.router.HandleFunc("/api/thumbnails/{requestID}",
+				s.authorize(s.findThumbnails())).Methods(http.MethodGet)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/thumbnails/"+tt.requestID, nil)
+			req.Header.Set("Authorization", "Bearer token")
+
+			s.ServeHTTP(w, req)
+			mockSO.AssertExpectations(t)
+			require.Equal(t, tt.expectedStatusCode, w.Code)
+			require.Equal(t, tt.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_findThumbnail(t *testing.T) {
+	type fnBehavior func(mockSO *mocks.ServiceOperations, token string, id uuid.UUID)
+
+	asString := "00000000-0000-0000-0000-000000000000"
+	id := uuid.MustParse(asString)
+
+	tests := []struct {
+		name                 string
+		token                string
+		fn                   fnBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:  "Find thumbnail without errors",
+			token: "token",
+			fn: func(mockSO *mocks.ServiceOperations, token string, id uuid.UUID) {
+				mockSO.On("ParseToken", token).Return(id, nil)
+				mockSO.On("IsAuthenticated", mock.Anything, id, id).Return(nil)
+				mockSO.On("FindRendition", mock.Anything, id, id).Return(models.Rendition{ID: id, Width: 320, Format: "jpeg", ResultedName: "320w-thb-filename.jpeg"}, nil)
+				mockSO.On("SaveImage", "results/320w-thb-filename.jpeg").Return(&models.SavedImage{File: ioutil.NopCloser(bytes.NewReader(nil))}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: "",
+		},
+		{
+			name:  "Error rendition not found",
+			token: "token",
+			fn: func(mockSO *mocks.ServiceOperations, token string, id uuid.UUID) {
+				mockSO.On("ParseToken", token).Return(id, nil)
+				mockSO.On("IsAuthenticated", mock.Anything, id, id).Return(nil)
+				mockSO.On("FindRendition", mock.Anything, id, id).Return(models.Rendition{}, utils.ErrFindRendition)
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: "{\"error\":\"cannot find image:no such rendition\"}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := storage.NewFileSystem(t.TempDir())
+			mockSO := new(mocks.ServiceOperations)
+
+			currentService := NewAPI(mockSO, store, utils.NewConfig().Limits)
+			mq := broker.NewAMQPBrokerAPI()
+
+			s := NewServer(mq, currentService)
+
+			tt.fn(mockSO, tt.token, id)
+
+			s.router.HandleFunc("/api/thumbnails/{requestID}/{renditionID}",
+				s.authorize(s.findThumbnail())).Methods(http.MethodGet)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/thumbnails/%s/%s", id, id), nil)
+			req.Header.Set("Authorization", "Bearer token")
+
+			s.ServeHTTP(w, req)
+			mockSO.AssertExpectations(t)
+			require.Equal(t, tt.expectedStatusCode, w.Code)
+			require.Equal(t, tt.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_findSimilarImages(t *testing.T) {
+	type fnBehavior func(mockSO *mocks.ServiceOperations, token string)
+
+	asString := "00000000-0000-0000-0000-000000000000"
+	id := uuid.MustParse(asString)
+	hash := uint64(0xf0e1d2c3b4a59687)
+
+	tests := []struct {
+		name                 string
+		token                string
+		imageID              string
+		query                string
+		fn                   fnBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:    "Find similar images without errors",
+			token:   "token",
+			imageID: asString,
+			query:   "?distance=4",
+			fn: func(mockSO *mocks.ServiceOperations, token string) {
+				mockSO.On("ParseToken", token).Return(id, nil)
+				mockSO.On("FindImageHash", mock.Anything, id, id).Return(hash, nil)
+				mockSO.On("FindSimilarImages", mock.Anything, id, id, hash, 4).Return([]models.SimilarImage{
+					{ID: id, RequestID: id, UploadedName: "copy.jpeg", Distance: 1},
+				}, nil)
+			},
+			expectedStatusCode: 200,
+			expectedResponseBody: "[{\"image_id\":\"" + asString + "\",\"request_id\":\"" + asString +
+				"\",\"uploaded_name\":\"copy.jpeg\",\"distance\":1}]\n",
+		},
+		{
+			name:    "Find similar images with default distance",
+			token:   "token",
+			imageID: asString,
+			fn: func(mockSO *mocks.ServiceOperations, token string) {
+				mockSO.On("ParseToken", token).Return(id, nil)
+				mockSO.On("FindImageHash", mock.Anything, id, id).Return(hash, nil)
+				mockSO.On("FindSimilarImages", mock.Anything, id, id, hash, DefaultHashDistance).Return([]models.SimilarImage{}, nil)
+			},
+			expectedStatusCode:   200,
+			expectedResponseBody: "[]\n",
+		},
+		{
+			name:    "Error image has not been analysed",
+			token:   "token",
+			imageID: asString,
+			fn: func(mockSO *mocks.ServiceOperations, token string) {
+				mockSO.On("ParseToken", token).Return(id, nil)
+				mockSO.On("FindImageHash", mock.Anything, id, id).Return(uint64(0), utils.ErrImageHashPending)
+			},
+			expectedStatusCode:   409,
+			expectedResponseBody: "{\"error\":\"the image has not been analysed yet\"}\n",
+		},
+		{
+			name:    "Error image not found",
+			token:   "token",
+			imageID: asString,
+			fn: func(mockSO *mocks.ServiceOperations, token string) {
+				mockSO.On("ParseToken", token).Return(id, nil)
+				mockSO.On("FindImageHash", mock.Anything, id, id).Return(uint64(0), utils.ErrImageNotFound)
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: "{\"error\":\"no such image\"}\n",
+		},
+		{
+			name:    "Distance out of range",
+			token:   "token",
+			imageID: asString,
+			query:   "?distance=65",
+			fn: func(mockSO *mocks.ServiceOperations, token string) {
+				mockSO.On("ParseToken", token).Return(id, nil)
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"distance must be between 0 and 64\"}\n",
+		},
+		{
+			name:    "Error incorrect image id",
+			token:   "token",
+			imageID: "image",
+			fn: func(mockSO *mocks.ServiceOperations, token string) {
+				mockSO.On("ParseToken", token).Return(id, nil)
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"invalid path in request\"}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := storage.NewFileSystem(t.TempDir())
+			mockSO := new(mocks.ServiceOperations)
+
+			currentService := NewAPI(mockSO, store, utils.NewConfig().Limits)
+			mq := broker.NewAMQPBrokerAPI()
+
+			s := NewServer(mq, currentService)
+
+			tt.fn(mockSO, tt.token)
+
+			s.router.HandleFunc("/api/images/{imageID}/similar",
+				s.authorize(s.findSimilarImages())).Methods(http.MethodGet)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/images/"+tt.imageID+"/similar"+tt.query, nil)
+			req.Header.Set("Authorization", "Bearer token")
+
+			s.ServeHTTP(w, req)
+			mockSO.AssertExpectations(t)
+			require.Equal(t, tt.expectedStatusCode, w.Code)
+			require.Equal(t, tt.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_inspectImage(t *testing.T) {
+	type fnBehavior func(mockSO *mocks.ServiceOperations, token string)
+
+	asString := "00000000-0000-0000-0000-000000000000"
+	id := uuid.MustParse(asString)
+
+	original := models.ImageInfo{
+		Format:     "jpeg",
+		Width:      640,
+		Height:     480,
+		ColorModel: "ycbcr",
+		BitDepth:   8,
+		Size:       2048,
+		SHA256:     "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+		Exif:       &models.ExifInfo{Make: "Canon", Model: "EOS 5D", DateTaken: "2021-06-01T10:20:30", Orientation: 6, HasGPS: true},
+	}
+	result := models.ImageInfo{
+		Format:     "png",
+		Width:      320,
+		Height:     240,
+		ColorModel: "paletted",
+		BitDepth:   6,
+		Size:       512,
+		SHA256:     "60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752",
+	}
+
+	tests := []struct {
+		name                 string
+		token                string
+		imageID              string
+		fn                   fnBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:    "Inspect image without errors",
+			token:   "token",
+			imageID: asString,
+			fn: func(mockSO *mocks.ServiceOperations, token string) {
+				mockSO.On("ParseToken", token).Return(id, nil)
+				mockSO.On("FindImage", mock.Anything, id, id).Return(models.Image{ID: id, UploadedName: "filename.jpeg", ResultedName: "filename.png", UploadedSize: 2048, UploadedSHA256: original.SHA256}, nil)
+				mockSO.On("InspectImage", "uploads/filename.jpeg", int64(2048), original.SHA256).Return(original, nil)
+				mockSO.On("InspectImage", "results/filename.png", int64(0), "").Return(result, nil)
+			},
+			expectedStatusCode: 200,
+			expectedResponseBody: "{\"image_id\":\"" + asString + "\",\"original\":{\"format\":\"jpeg\",\"width\":640,\"height\":480,\"color_model\":\"ycbcr\",\"bit_depth\":8,\"has_alpha\":false,\"size\":2048," +
+				"\"sha256\":\"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08\",\"exif\":{\"make\":\"Canon\",\"model\":\"EOS 5D\",\"date_taken\":\"2021-06-01T10:20:30\",\"orientation\":6,\"has_gps\":true}}," +
+				"\"result\":{\"format\":\"png\",\"width\":320,\"height\":240,\"color_model\":\"paletted\",\"bit_depth\":6,\"has_alpha\":false,\"size\":512,\"sha256\":\"60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752\"}}\n",
+		},
+		{
+			name:    "Inspect image that has not been processed",
+			token:   "token",
+			imageID: asString,
+			fn: func(mockSO *mocks.ServiceOperations, token string) {
+				mockSO.On("ParseToken", token).Return(id, nil)
+				mockSO.On("FindImage", mock.Anything, id, id).Return(models.Image{ID: id, UploadedName: "filename.png"}, nil)
+				mockSO.On("InspectImage", "uploads/filename.png", int64(0), "").Return(result, nil)
+			},
+			expectedStatusCode: 200,
+			expectedResponseBody: "{\"image_id\":\"" + asString + "\",\"original\":{\"format\":\"png\",\"width\":320,\"height\":240,\"color_model\":\"paletted\",\"bit_depth\":6,\"has_alpha\":false,\"size\":512," +
+				"\"sha256\":\"60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752\"}}\n",
+		},
+		{
+			name:    "Error image not found",
+			token:   "token",
+			imageID: asString,
+			fn: func(mockSO *mocks.ServiceOperations, token string) {
+				mockSO.On("ParseToken", token).Return(id, nil)
+				mockSO.On("FindImage", mock.Anything, id, id).Return(models.Image{}, utils.ErrImageNotFound)
+			},
+			expectedStatusCode:   404,
+			expectedResponseBody: "{\"error\":\"no such image\"}\n",
+		},
+		{
+			name:    "Error cannot inspect image",
+			token:   "token",
+			imageID: asString,
+			fn: func(mockSO *mocks.ServiceOperations, token string) {
+				mockSO.On("ParseToken", token).Return(id, nil)
+				mockSO.On("FindImage", mock.Anything, id, id).Return(models.Image{ID: id, UploadedName: "filename.jpeg"}, nil)
+				mockSO.On("InspectImage", "uploads/filename.jpeg", int64(0), "").Return(models.ImageInfo{}, utils.ErrInspectImage)
+			},
+			expectedStatusCode:   500,
+			expectedResponseBody: "{\"error\":\"cannot inspect image\"}\n",
+		},
+		{
+			name:    "Error incorrect image id",
+			token:   "token",
+			imageID: "image",
+			fn: func(mockSO *mocks.ServiceOperations, token string) {
+				mockSO.On("ParseToken", token).Return(id, nil)
+			},
+			expectedStatusCode:   400,
+			expectedResponseBody: "{\"error\":\"invalid path in request\"}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			store := storage.NewFileSystem(t.TempDir())
+			mockSO := new(mocks.ServiceOperations)
+
+			currentService := NewAPI(mockSO, store, utils.NewConfig().Limits)
+			mq := broker.NewAMQPBrokerAPI()
+
+			s := NewServer(mq, currentService)
+
+			tt.fn(mockSO, tt.token)
+
+			s.router.HandleFunc("/api/images/{imageID}/info",
+				s.authorize(s.inspectImage())).Methods(http.MethodGet)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/images/"+tt.imageID+"/info", nil)
+			req.Header.Set("Authorization", "Bearer token")
 
 			s.ServeHTTP(w, req)
 			mockSO.AssertExpectations(t)
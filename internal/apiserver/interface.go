@@ -3,10 +3,9 @@ package apiserver
 import (
 	"context"
 	"image"
-	"io"
-	"os"
 
 	"github.com/alisavch/image-service/internal/models"
+	"github.com/alisavch/image-service/internal/service"
 
 	"github.com/google/uuid"
 	"github.com/streadway/amqp"
@@ -34,28 +33,35 @@ type Authorization interface {
 
 // Image contains methods for working with images.
 type Image interface {
-	CompressImage(width int, format, resultedName string, img image.Image, newImg *os.File, storage string) (models.Image, error)
+	CompressImage(resize models.Resize, format, resultedName string, img image.Image, opts ...service.EncodeOption) (models.Image, error)
+	CropImage(crop models.Crop, format, resultedName string, img image.Image, opts ...service.EncodeOption) (models.Image, error)
+	TransformImage(transform models.Transform, format, resultedName string, img image.Image, opts ...service.EncodeOption) (models.Image, error)
+	PipelineImage(steps []models.Step, format, resultedName string, img image.Image, opts ...service.EncodeOption) (models.Image, error)
 	UploadResultedImage(ctx context.Context, img models.Image) error
-	ChangeFormat(filename string) (string, error)
-	ConvertToType(format, resultedName string, img image.Image, newImg *os.File, storage string) (models.Image, error)
+	ChangeFormat(filename, format string) (string, error)
+	ConvertToType(format, resultedName string, img image.Image, opts ...service.EncodeOption) (models.Image, error)
 	FindRequestStatus(ctx context.Context, userID, requestID uuid.UUID) (models.Status, error)
+	FindRequest(ctx context.Context, userID, requestID uuid.UUID) (models.Request, error)
 	UploadImage(ctx context.Context, img models.Image) (uuid.UUID, error)
 	CreateRequest(ctx context.Context, user models.User, img models.Image, req models.Request) (uuid.UUID, error)
 	FindResultedImage(ctx context.Context, id uuid.UUID) (models.Image, error)
 	FindOriginalImage(ctx context.Context, id uuid.UUID) (models.Image, error)
 	FindUserRequestHistory(ctx context.Context, id uuid.UUID) ([]models.History, error)
-	SaveImage(filename, location, storage string) (*models.SavedImage, error)
+	SaveImage(key string) (*models.SavedImage, error)
+	InspectImage(key string, size int64, digest string) (models.ImageInfo, error)
 	UpdateStatus(ctx context.Context, id uuid.UUID, status models.Status) error
-	FillInTheResultingImageForAWS(resultedName string) (models.Image, error)
-	FillInTheResultingImage(storage, resultedName string, newImg *os.File) (models.Image, error)
 	CompleteRequest(ctx context.Context, id uuid.UUID, status models.Status) error
 	IsAuthenticated(ctx context.Context, userID, requestID uuid.UUID) error
-}
-
-// S3Bucket contains the basic functions for interacting with the bucket.
-type S3Bucket interface {
-	UploadToS3Bucket(file io.Reader, filename string) (string, error)
-	DownloadFromS3Bucket(filename string) (*os.File, error)
+	SaveWatermark(ctx context.Context, userID uuid.UUID, wm models.Watermark) error
+	FindWatermark(ctx context.Context, userID uuid.UUID) (models.Watermark, error)
+	DeleteWatermark(ctx context.Context, userID uuid.UUID) error
+	ThumbnailImage(thumbnails models.Thumbnails, format, resultedName string, img image.Image, opts ...service.EncodeOption) ([]models.Rendition, error)
+	FindRenditions(ctx context.Context, requestID uuid.UUID) ([]models.Rendition, error)
+	FindRendition(ctx context.Context, requestID, renditionID uuid.UUID) (models.Rendition, error)
+	FindImage(ctx context.Context, userID, imageID uuid.UUID) (models.Image, error)
+	FindImageHash(ctx context.Context, userID, imageID uuid.UUID) (uint64, error)
+	FindSimilarImages(ctx context.Context, userID, imageID uuid.UUID, hash uint64, distance int) ([]models.SimilarImage, error)
+	FindPalette(ctx context.Context, requestID uuid.UUID) ([]models.PaletteColor, error)
 }
 
 // ServiceOperations combines the basic service operations.
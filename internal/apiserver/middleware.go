@@ -1,21 +1,28 @@
 package apiserver
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	_ "image/gif"  // It allows using gif
 	_ "image/jpeg" // It allows using jpeg
 	_ "image/png"  // It allows using png
 	"io"
 	"mime/multipart"
 	"net/http"
-	"os"
+	"net/url"
 	"strings"
 
 	"github.com/alisavch/image-service/internal/models"
 	"github.com/alisavch/image-service/internal/service"
+	"github.com/alisavch/image-service/internal/storage"
 	"github.com/alisavch/image-service/internal/utils"
 
 	"github.com/google/uuid"
+	_ "golang.org/x/image/bmp"  // It allows using bmp
+	_ "golang.org/x/image/tiff" // It allows using tiff
+	_ "golang.org/x/image/webp" // It allows using webp
 )
 
 type key string
@@ -23,8 +30,11 @@ type key string
 const (
 	authorizationHeader key = "Authorization"
 	userCtx             key = "userId"
-	aws                     = "AWS"
-	local                   = "local"
+	uploadCtx           key = "upload"
+	// uploadField is the name of the form field the image is uploaded in.
+	uploadField = "uploadFile"
+	// maxFormSize limits the fields sent along with the uploaded image, the logo of a watermark included.
+	maxFormSize = 10 << 20
 )
 
 // Request is an interface which must be implemented by request models.
@@ -100,138 +110,185 @@ func (s *Server) authorize(next http.Handler) http.HandlerFunc {
 	}
 }
 
-type uploaded struct {
-	file    multipart.File
-	handler *multipart.FileHeader
+// streamedUpload is the image stored by streamUpload, it is kept until uploadImage registers it.
+type streamedUpload struct {
+	image      models.Image
+	err        error
+	registered bool
 }
 
-// Build builds a request to load an image.
-func (req *uploaded) Build(r *http.Request) error {
-	err := r.ParseMultipartForm(32 << 20)
-	if err != nil {
-		return err
-	}
+// streamUpload reads the multipart body part by part and streams the uploaded image straight to the storage,
+// so the file is never buffered as a whole. The other fields are small, they are kept as a regular form and
+// read by the handler with r.FormValue. The stored image is removed when the handler fails before registering it.
+func (s *Server) streamUpload(next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		upload := &streamedUpload{}
 
-	req.file, req.handler, err = r.FormFile("uploadFile")
-	if err != nil {
-		return err
-	}
+		mr, err := r.MultipartReader()
+		if err != nil {
+			upload.err = err
+			setForm(r, emptyForm())
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), uploadCtx, upload)))
+			return
+		}
 
-	return nil
-}
+		form, err := s.readUpload(mr, upload)
+		defer func() {
+			if err := form.RemoveAll(); err != nil {
+				s.logger.Printf("%s:%s", "failed form.RemoveAll", err)
+			}
+		}()
+		// The fields after a rejected image are not read, so the rejection is reported before the handler builds the request.
+		if upload.err != nil {
+			s.errorJSON(w, uploadStatus(upload.err), upload.err)
+			return
+		}
+		if err != nil {
+			upload.err = err
+		}
+		setForm(r, form)
 
-// Validate builds a request to validate the upload of an image.
-func (req uploaded) Validate() error {
-	if !(req.handler.Header["Content-Type"][0] == "image/jpeg" || req.handler.Header["Content-Type"][0] == "image/png") {
-		return utils.ErrAllowedFormat
-	}
-	return nil
-}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), uploadCtx, upload)))
 
-func (s *Server) uploadImage(r *http.Request, uploadedImage models.Image) (models.Image, error) {
-	var req uploaded
-	err := ParseRequest(r, &req)
-	if err != nil {
-		return models.Image{}, err
+		if upload.err == nil && upload.image.UploadedName != "" && !upload.registered {
+			s.removeUpload(upload.image)
+		}
 	}
+}
 
-	req.handler.Filename = strings.ReplaceAll(uuid.New().String(), "-", "") + req.handler.Filename
+// readUpload stores the uploaded image and collects the other fields, the reading stops when the image is rejected.
+func (s *Server) readUpload(mr *multipart.Reader, upload *streamedUpload) (*multipart.Form, error) {
+	body := &bytes.Buffer{}
+	fields := multipart.NewWriter(body)
 
-	conf := utils.NewConfig()
-	switch conf.Storage {
-	case aws:
-		uploadedImage, err := s.uploadImageToAWS(r, req)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			return models.Image{}, err
+			return emptyForm(), err
 		}
 
-		return uploadedImage, nil
+		if part.FormName() == uploadField && part.FileName() != "" && upload.image.UploadedName == "" {
+			upload.image, upload.err = s.storeUpload(part)
+			if upload.err != nil {
+				break
+			}
+			continue
+		}
 
-	case local:
-		uploadedImage, err := s.uploadImageLocally(r, req)
+		dst, err := fields.CreatePart(part.Header)
 		if err != nil {
-			return models.Image{}, err
+			return emptyForm(), err
 		}
-
-		return uploadedImage, nil
+		if _, err := io.Copy(dst, io.LimitReader(part, int64(maxFormSize-body.Len()+1))); err != nil {
+			return emptyForm(), err
+		}
+		if body.Len() > maxFormSize {
+			return emptyForm(), multipart.ErrMessageTooLarge
+		}
+	}
+	if err := fields.Close(); err != nil {
+		return emptyForm(), err
 	}
 
-	return uploadedImage, nil
+	return multipart.NewReader(body, fields.Boundary()).ReadForm(maxFormSize)
 }
 
-func (s *Server) uploadImageLocally(r *http.Request, req uploaded) (models.Image, error) {
-	err := s.prepareImageForLocalLoading(req)
+// storeUpload streams the uploaded image to the storage, its SHA-256 and size are computed on the way.
+func (s *Server) storeUpload(part *multipart.Part) (models.Image, error) {
+	reader, err := service.NewUploadReader(part, part.Header.Get("Content-Type"), part.FileName(), s.service.limits)
 	if err != nil {
 		return models.Image{}, err
 	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			s.logger.Printf("%s:%s", "failed reader.Close", err)
+		}
+	}()
+
+	filename := strings.ReplaceAll(uuid.New().String(), "-", "") + part.FileName()
 
-	currentDir, err := os.Getwd()
+	location, err := s.service.Put(storage.UploadKey(filename), reader)
+	// The storage wraps the errors of the reader, the rejection of the image is reported as it is.
+	if readErr := reader.Err(); readErr != nil {
+		return models.Image{}, readErr
+	}
 	if err != nil {
 		return models.Image{}, err
 	}
 
-	uploadedImage := fillInTheUploadedImageNameAndLocation(req.handler.Filename, currentDir+"/uploads/")
-
-	uploadedID, err := s.service.ServiceOperations.UploadImage(r.Context(), uploadedImage)
-	if err != nil {
-		return models.Image{}, fmt.Errorf("%s:%s", utils.ErrUpload, err)
-	}
-	uploadedImage.ID = uploadedID
+	uploadedImage := fillInTheUploadedImageNameAndLocation(filename, location)
+	uploadedImage.UploadedSize = reader.Size()
+	uploadedImage.UploadedFormat = reader.Format().Name
+	uploadedImage.UploadedSHA256 = reader.SHA256()
 
 	return uploadedImage, nil
 }
 
-func (s *Server) uploadImageToAWS(r *http.Request, req uploaded) (models.Image, error) {
-	imageLocation, err := s.service.UploadToS3Bucket(req.file, req.handler.Filename)
-	if err != nil {
-		return models.Image{}, err
+// uploadStatus maps the error of the upload to the status of the response.
+func uploadStatus(err error) int {
+	switch {
+	case errors.Is(err, utils.ErrImageTooLarge) || errors.Is(err, utils.ErrObjectTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, utils.ErrDecode):
+		return http.StatusBadRequest
+	case errors.Is(err, utils.ErrAllowedFormat) || errors.Is(err, utils.ErrContentMismatch) || errors.Is(err, utils.ErrPolyglot):
+		return http.StatusUnsupportedMediaType
+	default:
+		return http.StatusInternalServerError
 	}
+}
 
-	err = req.file.Close()
-	if err != nil {
-		return models.Image{}, err
+// removeUpload removes the stored image the request was not created for.
+func (s *Server) removeUpload(img models.Image) {
+	if err := s.service.Delete(storage.UploadKey(img.UploadedName)); err != nil {
+		s.logger.Printf("%s:%s", "Failed to remove the upload", err)
 	}
+}
 
-	uploadedImage := fillInTheUploadedImageNameAndLocation(req.handler.Filename, imageLocation)
+// uploadImage registers the image stored by streamUpload.
+func (s *Server) uploadImage(r *http.Request) (models.Image, error) {
+	upload, ok := r.Context().Value(uploadCtx).(*streamedUpload)
+	if !ok {
+		return models.Image{}, http.ErrMissingFile
+	}
+	if upload.err != nil {
+		return models.Image{}, upload.err
+	}
+	if upload.image.UploadedName == "" {
+		return models.Image{}, http.ErrMissingFile
+	}
 
+	uploadedImage := upload.image
 	uploadedID, err := s.service.ServiceOperations.UploadImage(r.Context(), uploadedImage)
 	if err != nil {
 		return models.Image{}, fmt.Errorf("%s:%s", utils.ErrUpload, err)
 	}
+	upload.registered = true
 	uploadedImage.ID = uploadedID
 
 	return uploadedImage, nil
 }
 
-func (s *Server) prepareImageForLocalLoading(req uploaded) error {
-	err := service.EnsureBaseDir("./uploads/")
-	if err != nil {
-		return nil
+// setForm sets the fields read along with the upload on the request the way r.ParseMultipartForm does.
+func setForm(r *http.Request, form *multipart.Form) {
+	if r.Form == nil {
+		_ = r.ParseForm()
 	}
-
-	out, err := os.Create(fmt.Sprintf("./uploads/%s", req.handler.Filename))
-	if err != nil {
-		return utils.ErrCreateFile
+	if r.PostForm == nil {
+		r.PostForm = make(url.Values)
 	}
-	defer func(out *os.File) {
-		err := out.Close()
-		if err != nil {
-			s.logger.Fatalf("%s:%s", "failed fileReader.Close", err)
-		}
-	}(out)
-
-	_, err = io.Copy(out, req.file)
-	if err != nil {
-		return utils.ErrCopyFile
+	for k, v := range form.Value {
+		r.Form[k] = append(r.Form[k], v...)
+		r.PostForm[k] = append(r.PostForm[k], v...)
 	}
-	defer func(file multipart.File) {
-		err := file.Close()
-		if err != nil {
-			s.logger.Fatalf("%s:%s", "failed fileReader.Close", err)
-		}
-	}(req.file)
+	r.MultipartForm = form
+}
 
-	return nil
+func emptyForm() *multipart.Form {
+	return &multipart.Form{Value: map[string][]string{}, File: map[string][]*multipart.FileHeader{}}
 }
 
 func fillInTheUploadedImageNameAndLocation(name, location string) models.Image {
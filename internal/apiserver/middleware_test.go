@@ -0,0 +1,85 @@
+package apiserver
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"path/filepath"
+	"testing"
+
+	"github.com/alisavch/image-service/internal/apiserver/mocks"
+	"github.com/alisavch/image-service/internal/storage"
+	"github.com/alisavch/image-service/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_streamUploadRejectedBeforeFields(t *testing.T) {
+	content := &bytes.Buffer{}
+	require.NoError(t, png.Encode(content, image.NewGray(image.Rect(0, 0, 64, 64))))
+
+	tests := []struct {
+		name                 string
+		maxFileSize          int64
+		contentType          string
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:                 "File over the size limit",
+			maxFileSize:          50,
+			contentType:          "image/png",
+			expectedStatusCode:   413,
+			expectedResponseBody: "{\"error\":\"image is too large: file size 99 exceeds the limit of 50\"}\n",
+		},
+		{
+			name:                 "Content type does not match the file",
+			contentType:          "image/jpeg",
+			expectedStatusCode:   415,
+			expectedResponseBody: "{\"error\":\"content of the file does not match its type: declared image/jpeg, detected png\"}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			mockSO := new(mocks.ServiceOperations)
+			mockSO.On("ParseToken", "token").Return(uuid.New(), nil)
+			s := NewServer(new(mocks.AMQP), NewAPI(mockSO, storage.NewFileSystem(dir), utils.LimitsConfig{MaxFileSize: tt.maxFileSize}))
+			s.router.HandleFunc("/api/pipeline",
+				s.authorize(s.streamUpload(s.pipelineImage()))).Methods(http.MethodPost)
+
+			// The image comes first, the steps the handler requires are sent after it.
+			body := &bytes.Buffer{}
+			writer := multipart.NewWriter(body)
+			header := make(textproto.MIMEHeader)
+			header.Set("Content-Disposition", `form-data; name="uploadFile"; filename="filename.png"`)
+			header.Set("Content-Type", tt.contentType)
+			part, err := writer.CreatePart(header)
+			require.NoError(t, err)
+			_, err = part.Write(content.Bytes())
+			require.NoError(t, err)
+			require.NoError(t, writer.WriteField("steps", `[{"op":"grayscale"}]`))
+			require.NoError(t, writer.Close())
+
+			req := httptest.NewRequest(http.MethodPost, "/api/pipeline", body)
+			req.Header.Set("Authorization", "Bearer token")
+			req.Header.Set("Content-Type", writer.FormDataContentType())
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, req)
+
+			mockSO.AssertExpectations(t)
+			require.Equal(t, tt.expectedStatusCode, w.Code)
+			require.Equal(t, tt.expectedResponseBody, w.Body.String())
+			uploads, _ := ioutil.ReadDir(filepath.Join(dir, "uploads"))
+			require.Empty(t, uploads)
+		})
+	}
+}
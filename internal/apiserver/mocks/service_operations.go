@@ -6,13 +6,10 @@ import (
 	context "context"
 	image "image"
 
-	mock "github.com/stretchr/testify/mock"
-
 	models "github.com/alisavch/image-service/internal/models"
-
-	os "os"
-
+	service "github.com/alisavch/image-service/internal/service"
 	uuid "github.com/google/uuid"
+	mock "github.com/stretchr/testify/mock"
 )
 
 // ServiceOperations is an autogenerated mock type for the ServiceOperations type
@@ -20,20 +17,20 @@ type ServiceOperations struct {
 	mock.Mock
 }
 
-// ChangeFormat provides a mock function with given fields: filename
-func (_m *ServiceOperations) ChangeFormat(filename string) (string, error) {
-	ret := _m.Called(filename)
+// ChangeFormat provides a mock function with given fields: filename, format
+func (_m *ServiceOperations) ChangeFormat(filename string, format string) (string, error) {
+	ret := _m.Called(filename, format)
 
 	var r0 string
-	if rf, ok := ret.Get(0).(func(string) string); ok {
-		r0 = rf(filename)
+	if rf, ok := ret.Get(0).(func(string, string) string); ok {
+		r0 = rf(filename, format)
 	} else {
 		r0 = ret.Get(0).(string)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(filename)
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(filename, format)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -55,20 +52,27 @@ func (_m *ServiceOperations) CompleteRequest(ctx context.Context, id uuid.UUID,
 	return r0
 }
 
-// CompressImage provides a mock function with given fields: width, format, resultedName, img, newImg, storage
-func (_m *ServiceOperations) CompressImage(width int, format string, resultedName string, img image.Image, newImg *os.File, storage string) (models.Image, error) {
-	ret := _m.Called(width, format, resultedName, img, newImg, storage)
+// CompressImage provides a mock function with given fields: resize, format, resultedName, img, opts
+func (_m *ServiceOperations) CompressImage(resize models.Resize, format string, resultedName string, img image.Image, opts ...service.EncodeOption) (models.Image, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, resize, format, resultedName, img)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
 
 	var r0 models.Image
-	if rf, ok := ret.Get(0).(func(int, string, string, image.Image, *os.File, string) models.Image); ok {
-		r0 = rf(width, format, resultedName, img, newImg, storage)
+	if rf, ok := ret.Get(0).(func(models.Resize, string, string, image.Image, ...service.EncodeOption) models.Image); ok {
+		r0 = rf(resize, format, resultedName, img, opts...)
 	} else {
 		r0 = ret.Get(0).(models.Image)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(int, string, string, image.Image, *os.File, string) error); ok {
-		r1 = rf(width, format, resultedName, img, newImg, storage)
+	if rf, ok := ret.Get(1).(func(models.Resize, string, string, image.Image, ...service.EncodeOption) error); ok {
+		r1 = rf(resize, format, resultedName, img, opts...)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -76,20 +80,27 @@ func (_m *ServiceOperations) CompressImage(width int, format string, resultedNam
 	return r0, r1
 }
 
-// ConvertToType provides a mock function with given fields: format, resultedName, img, newImg, storage
-func (_m *ServiceOperations) ConvertToType(format string, resultedName string, img image.Image, newImg *os.File, storage string) (models.Image, error) {
-	ret := _m.Called(format, resultedName, img, newImg, storage)
+// ConvertToType provides a mock function with given fields: format, resultedName, img, opts
+func (_m *ServiceOperations) ConvertToType(format string, resultedName string, img image.Image, opts ...service.EncodeOption) (models.Image, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, format, resultedName, img)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
 
 	var r0 models.Image
-	if rf, ok := ret.Get(0).(func(string, string, image.Image, *os.File, string) models.Image); ok {
-		r0 = rf(format, resultedName, img, newImg, storage)
+	if rf, ok := ret.Get(0).(func(string, string, image.Image, ...service.EncodeOption) models.Image); ok {
+		r0 = rf(format, resultedName, img, opts...)
 	} else {
 		r0 = ret.Get(0).(models.Image)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string, string, image.Image, *os.File, string) error); ok {
-		r1 = rf(format, resultedName, img, newImg, storage)
+	if rf, ok := ret.Get(1).(func(string, string, image.Image, ...service.EncodeOption) error); ok {
+		r1 = rf(format, resultedName, img, opts...)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -143,20 +154,27 @@ func (_m *ServiceOperations) CreateUser(ctx context.Context, user models.User) (
 	return r0, r1
 }
 
-// FillInTheResultingImage provides a mock function with given fields: storage, resultedName, newImg
-func (_m *ServiceOperations) FillInTheResultingImage(storage string, resultedName string, newImg *os.File) (models.Image, error) {
-	ret := _m.Called(storage, resultedName, newImg)
+// CropImage provides a mock function with given fields: crop, format, resultedName, img, opts
+func (_m *ServiceOperations) CropImage(crop models.Crop, format string, resultedName string, img image.Image, opts ...service.EncodeOption) (models.Image, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, crop, format, resultedName, img)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
 
 	var r0 models.Image
-	if rf, ok := ret.Get(0).(func(string, string, *os.File) models.Image); ok {
-		r0 = rf(storage, resultedName, newImg)
+	if rf, ok := ret.Get(0).(func(models.Crop, string, string, image.Image, ...service.EncodeOption) models.Image); ok {
+		r0 = rf(crop, format, resultedName, img, opts...)
 	} else {
 		r0 = ret.Get(0).(models.Image)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string, string, *os.File) error); ok {
-		r1 = rf(storage, resultedName, newImg)
+	if rf, ok := ret.Get(1).(func(models.Crop, string, string, image.Image, ...service.EncodeOption) error); ok {
+		r1 = rf(crop, format, resultedName, img, opts...)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -164,20 +182,55 @@ func (_m *ServiceOperations) FillInTheResultingImage(storage string, resultedNam
 	return r0, r1
 }
 
-// FillInTheResultingImageForAWS provides a mock function with given fields: resultedName
-func (_m *ServiceOperations) FillInTheResultingImageForAWS(resultedName string) (models.Image, error) {
-	ret := _m.Called(resultedName)
+// DeleteWatermark provides a mock function with given fields: ctx, userID
+func (_m *ServiceOperations) DeleteWatermark(ctx context.Context, userID uuid.UUID) error {
+	ret := _m.Called(ctx, userID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) error); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// FindImage provides a mock function with given fields: ctx, userID, imageID
+func (_m *ServiceOperations) FindImage(ctx context.Context, userID uuid.UUID, imageID uuid.UUID) (models.Image, error) {
+	ret := _m.Called(ctx, userID, imageID)
 
 	var r0 models.Image
-	if rf, ok := ret.Get(0).(func(string) models.Image); ok {
-		r0 = rf(resultedName)
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, uuid.UUID) models.Image); ok {
+		r0 = rf(ctx, userID, imageID)
 	} else {
 		r0 = ret.Get(0).(models.Image)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(resultedName)
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, uuid.UUID) error); ok {
+		r1 = rf(ctx, userID, imageID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindImageHash provides a mock function with given fields: ctx, userID, imageID
+func (_m *ServiceOperations) FindImageHash(ctx context.Context, userID uuid.UUID, imageID uuid.UUID) (uint64, error) {
+	ret := _m.Called(ctx, userID, imageID)
+
+	var r0 uint64
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, uuid.UUID) uint64); ok {
+		r0 = rf(ctx, userID, imageID)
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, uuid.UUID) error); ok {
+		r1 = rf(ctx, userID, imageID)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -206,6 +259,94 @@ func (_m *ServiceOperations) FindOriginalImage(ctx context.Context, id uuid.UUID
 	return r0, r1
 }
 
+// FindPalette provides a mock function with given fields: ctx, requestID
+func (_m *ServiceOperations) FindPalette(ctx context.Context, requestID uuid.UUID) ([]models.PaletteColor, error) {
+	ret := _m.Called(ctx, requestID)
+
+	var r0 []models.PaletteColor
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) []models.PaletteColor); ok {
+		r0 = rf(ctx, requestID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.PaletteColor)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, requestID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindRendition provides a mock function with given fields: ctx, requestID, renditionID
+func (_m *ServiceOperations) FindRendition(ctx context.Context, requestID uuid.UUID, renditionID uuid.UUID) (models.Rendition, error) {
+	ret := _m.Called(ctx, requestID, renditionID)
+
+	var r0 models.Rendition
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, uuid.UUID) models.Rendition); ok {
+		r0 = rf(ctx, requestID, renditionID)
+	} else {
+		r0 = ret.Get(0).(models.Rendition)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, uuid.UUID) error); ok {
+		r1 = rf(ctx, requestID, renditionID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindRenditions provides a mock function with given fields: ctx, requestID
+func (_m *ServiceOperations) FindRenditions(ctx context.Context, requestID uuid.UUID) ([]models.Rendition, error) {
+	ret := _m.Called(ctx, requestID)
+
+	var r0 []models.Rendition
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) []models.Rendition); ok {
+		r0 = rf(ctx, requestID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Rendition)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, requestID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindRequest provides a mock function with given fields: ctx, userID, requestID
+func (_m *ServiceOperations) FindRequest(ctx context.Context, userID uuid.UUID, requestID uuid.UUID) (models.Request, error) {
+	ret := _m.Called(ctx, userID, requestID)
+
+	var r0 models.Request
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, uuid.UUID) models.Request); ok {
+		r0 = rf(ctx, userID, requestID)
+	} else {
+		r0 = ret.Get(0).(models.Request)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, uuid.UUID) error); ok {
+		r1 = rf(ctx, userID, requestID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // FindRequestStatus provides a mock function with given fields: ctx, userID, requestID
 func (_m *ServiceOperations) FindRequestStatus(ctx context.Context, userID uuid.UUID, requestID uuid.UUID) (models.Status, error) {
 	ret := _m.Called(ctx, userID, requestID)
@@ -248,6 +389,29 @@ func (_m *ServiceOperations) FindResultedImage(ctx context.Context, id uuid.UUID
 	return r0, r1
 }
 
+// FindSimilarImages provides a mock function with given fields: ctx, userID, imageID, hash, distance
+func (_m *ServiceOperations) FindSimilarImages(ctx context.Context, userID uuid.UUID, imageID uuid.UUID, hash uint64, distance int) ([]models.SimilarImage, error) {
+	ret := _m.Called(ctx, userID, imageID, hash, distance)
+
+	var r0 []models.SimilarImage
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, uuid.UUID, uint64, int) []models.SimilarImage); ok {
+		r0 = rf(ctx, userID, imageID, hash, distance)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.SimilarImage)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, uuid.UUID, uint64, int) error); ok {
+		r1 = rf(ctx, userID, imageID, hash, distance)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // FindUserRequestHistory provides a mock function with given fields: ctx, id
 func (_m *ServiceOperations) FindUserRequestHistory(ctx context.Context, id uuid.UUID) ([]models.History, error) {
 	ret := _m.Called(ctx, id)
@@ -271,6 +435,27 @@ func (_m *ServiceOperations) FindUserRequestHistory(ctx context.Context, id uuid
 	return r0, r1
 }
 
+// FindWatermark provides a mock function with given fields: ctx, userID
+func (_m *ServiceOperations) FindWatermark(ctx context.Context, userID uuid.UUID) (models.Watermark, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 models.Watermark
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) models.Watermark); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Get(0).(models.Watermark)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GenerateToken provides a mock function with given fields: ctx, username, password
 func (_m *ServiceOperations) GenerateToken(ctx context.Context, username string, password string) (string, error) {
 	ret := _m.Called(ctx, username, password)
@@ -292,6 +477,27 @@ func (_m *ServiceOperations) GenerateToken(ctx context.Context, username string,
 	return r0, r1
 }
 
+// InspectImage provides a mock function with given fields: key, size, digest
+func (_m *ServiceOperations) InspectImage(key string, size int64, digest string) (models.ImageInfo, error) {
+	ret := _m.Called(key, size, digest)
+
+	var r0 models.ImageInfo
+	if rf, ok := ret.Get(0).(func(string, int64, string) models.ImageInfo); ok {
+		r0 = rf(key, size, digest)
+	} else {
+		r0 = ret.Get(0).(models.ImageInfo)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(string, int64, string) error); ok {
+		r1 = rf(key, size, digest)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // IsAuthenticated provides a mock function with given fields: ctx, userID, requestID
 func (_m *ServiceOperations) IsAuthenticated(ctx context.Context, userID uuid.UUID, requestID uuid.UUID) error {
 	ret := _m.Called(ctx, userID, requestID)
@@ -329,13 +535,41 @@ func (_m *ServiceOperations) ParseToken(token string) (uuid.UUID, error) {
 	return r0, r1
 }
 
-// SaveImage provides a mock function with given fields: filename, location, storage
-func (_m *ServiceOperations) SaveImage(filename string, location string, storage string) (*models.SavedImage, error) {
-	ret := _m.Called(filename, location, storage)
+// PipelineImage provides a mock function with given fields: steps, format, resultedName, img, opts
+func (_m *ServiceOperations) PipelineImage(steps []models.Step, format string, resultedName string, img image.Image, opts ...service.EncodeOption) (models.Image, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, steps, format, resultedName, img)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 models.Image
+	if rf, ok := ret.Get(0).(func([]models.Step, string, string, image.Image, ...service.EncodeOption) models.Image); ok {
+		r0 = rf(steps, format, resultedName, img, opts...)
+	} else {
+		r0 = ret.Get(0).(models.Image)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func([]models.Step, string, string, image.Image, ...service.EncodeOption) error); ok {
+		r1 = rf(steps, format, resultedName, img, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SaveImage provides a mock function with given fields: key
+func (_m *ServiceOperations) SaveImage(key string) (*models.SavedImage, error) {
+	ret := _m.Called(key)
 
 	var r0 *models.SavedImage
-	if rf, ok := ret.Get(0).(func(string, string, string) *models.SavedImage); ok {
-		r0 = rf(filename, location, storage)
+	if rf, ok := ret.Get(0).(func(string) *models.SavedImage); ok {
+		r0 = rf(key)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*models.SavedImage)
@@ -343,8 +577,80 @@ func (_m *ServiceOperations) SaveImage(filename string, location string, storage
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string, string, string) error); ok {
-		r1 = rf(filename, location, storage)
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SaveWatermark provides a mock function with given fields: ctx, userID, wm
+func (_m *ServiceOperations) SaveWatermark(ctx context.Context, userID uuid.UUID, wm models.Watermark) error {
+	ret := _m.Called(ctx, userID, wm)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, models.Watermark) error); ok {
+		r0 = rf(ctx, userID, wm)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ThumbnailImage provides a mock function with given fields: thumbnails, format, resultedName, img, opts
+func (_m *ServiceOperations) ThumbnailImage(thumbnails models.Thumbnails, format string, resultedName string, img image.Image, opts ...service.EncodeOption) ([]models.Rendition, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, thumbnails, format, resultedName, img)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 []models.Rendition
+	if rf, ok := ret.Get(0).(func(models.Thumbnails, string, string, image.Image, ...service.EncodeOption) []models.Rendition); ok {
+		r0 = rf(thumbnails, format, resultedName, img, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Rendition)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(models.Thumbnails, string, string, image.Image, ...service.EncodeOption) error); ok {
+		r1 = rf(thumbnails, format, resultedName, img, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// TransformImage provides a mock function with given fields: transform, format, resultedName, img, opts
+func (_m *ServiceOperations) TransformImage(transform models.Transform, format string, resultedName string, img image.Image, opts ...service.EncodeOption) (models.Image, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, transform, format, resultedName, img)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 models.Image
+	if rf, ok := ret.Get(0).(func(models.Transform, string, string, image.Image, ...service.EncodeOption) models.Image); ok {
+		r0 = rf(transform, format, resultedName, img, opts...)
+	} else {
+		r0 = ret.Get(0).(models.Image)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(models.Transform, string, string, image.Image, ...service.EncodeOption) error); ok {
+		r1 = rf(transform, format, resultedName, img, opts...)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -0,0 +1,238 @@
+package apiserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"sync"
+	"testing"
+
+	"github.com/alisavch/image-service/internal/apiserver/mocks"
+	"github.com/alisavch/image-service/internal/broker"
+	"github.com/alisavch/image-service/internal/models"
+	"github.com/alisavch/image-service/internal/service"
+	"github.com/alisavch/image-service/internal/storage"
+	"github.com/alisavch/image-service/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryRepo keeps the images and the requests of the round trip, the methods the flow does not call are left nil.
+type memoryRepo struct {
+	service.ImageRepo
+
+	mu       sync.Mutex
+	images   map[uuid.UUID]models.Image
+	requests map[uuid.UUID]memoryRequest
+}
+
+type memoryRequest struct {
+	userID  uuid.UUID
+	imageID uuid.UUID
+	service models.Service
+	status  models.Status
+}
+
+func newMemoryRepo() *memoryRepo {
+	return &memoryRepo{images: map[uuid.UUID]models.Image{}, requests: map[uuid.UUID]memoryRequest{}}
+}
+
+func (m *memoryRepo) UploadImage(_ context.Context, img models.Image) (uuid.UUID, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	img.ID = uuid.New()
+	m.images[img.ID] = img
+	return img.ID, nil
+}
+
+func (m *memoryRepo) UploadResultedImage(_ context.Context, img models.Image) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.images[img.ID]; !ok {
+		return utils.ErrUploadImageToDB
+	}
+	m.images[img.ID] = img
+	return nil
+}
+
+func (m *memoryRepo) CreateRequest(_ context.Context, user models.User, img models.Image, req models.Request) (uuid.UUID, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := uuid.New()
+	m.requests[id] = memoryRequest{userID: user.ID, imageID: img.ID, service: req.ServiceName, status: models.Queued}
+	return id, nil
+}
+
+func (m *memoryRepo) UpdateStatus(_ context.Context, id uuid.UUID, status models.Status) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	req, ok := m.requests[id]
+	if !ok {
+		return utils.ErrUpdateStatusRequest
+	}
+	req.status = status
+	m.requests[id] = req
+	return nil
+}
+
+func (m *memoryRepo) CompleteRequest(ctx context.Context, id uuid.UUID, status models.Status) error {
+	return m.UpdateStatus(ctx, id, status)
+}
+
+func (m *memoryRepo) SaveImageHash(context.Context, uuid.UUID, uint64) error {
+	return nil
+}
+
+func (m *memoryRepo) IsAuthenticated(_ context.Context, userID, requestID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if req, ok := m.requests[requestID]; !ok || req.userID != userID {
+		return utils.ErrUserAuthentication
+	}
+	return nil
+}
+
+func (m *memoryRepo) FindRequestStatus(_ context.Context, userID, requestID uuid.UUID) (models.Status, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	req, ok := m.requests[requestID]
+	if !ok || req.userID != userID {
+		return "", utils.ErrGetStatus
+	}
+	return req.status, nil
+}
+
+func (m *memoryRepo) FindRequest(_ context.Context, userID, requestID uuid.UUID) (models.Request, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	req, ok := m.requests[requestID]
+	if !ok || req.userID != userID {
+		return models.Request{}, utils.ErrGetStatus
+	}
+	return models.Request{ID: requestID, UserAccountID: userID, ServiceName: req.service, Status: req.status}, nil
+}
+
+func (m *memoryRepo) FindResultedImage(_ context.Context, id uuid.UUID) (models.Image, error) {
+	return m.findImage(id, utils.ErrFindTheResultingImage)
+}
+
+func (m *memoryRepo) FindOriginalImage(_ context.Context, id uuid.UUID) (models.Image, error) {
+	return m.findImage(id, utils.ErrFindOriginalImage)
+}
+
+func (m *memoryRepo) findImage(requestID uuid.UUID, notFound error) (models.Image, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	req, ok := m.requests[requestID]
+	if !ok {
+		return models.Image{}, notFound
+	}
+	return m.images[req.imageID], nil
+}
+
+// inProcessQueue hands the published messages straight to the consumer.
+type inProcessQueue struct {
+	consumer *broker.AMQPBrokerConsumer
+	err      error
+}
+
+func (q *inProcessQueue) Publish(_, _ string, message models.QueuedMessage) error {
+	q.err = q.consumer.Process(message)
+	return nil
+}
+
+func (q *inProcessQueue) DeclareQueue(name string) (amqp.Queue, error) {
+	return amqp.Queue{Name: name}, nil
+}
+
+func TestRoundTrip_compressImage(t *testing.T) {
+	userID := uuid.New()
+	store := storage.NewMemory(0, 0)
+	images := service.NewImageService(newMemoryRepo(), store)
+
+	auth := new(mocks.Authorization)
+	auth.On("ParseToken", "token").Return(userID, nil)
+
+	limits := utils.NewConfig().Limits
+	queue := &inProcessQueue{consumer: broker.NewAMQPBrokerConsumer(images, store, limits)}
+	s := NewServer(queue, NewAPI(struct {
+		*mocks.Authorization
+		*service.ImageService
+	}{auth, images}, store, limits))
+
+	original := image.NewNRGBA(image.Rect(0, 0, 64, 48))
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			original.Set(x, y, color.NRGBA{R: uint8(x * 4), G: uint8(y * 5), B: 128, A: 255})
+		}
+	}
+	content := &bytes.Buffer{}
+	require.NoError(t, png.Encode(content, original))
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", `form-data; name="uploadFile"; filename="filename.png"`)
+	header.Set("Content-Type", "image/png")
+	part, err := writer.CreatePart(header)
+	require.NoError(t, err)
+	_, err = part.Write(content.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/compress?width=32", body)
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	require.NoError(t, queue.err)
+
+	var accepted map[string]uuid.UUID
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &accepted))
+	requestID := accepted["Request ID"]
+
+	uploads, err := store.List("uploads/")
+	require.NoError(t, err)
+	require.Len(t, uploads, 1)
+	results, err := store.List("results/")
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/download/"+requestID.String(), nil)
+	req.Header.Set("Authorization", "Bearer token")
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	require.Equal(t, results[0].Size, int64(w.Body.Len()))
+
+	compressed, format, err := image.Decode(w.Body)
+	require.NoError(t, err)
+	require.Equal(t, "png", format)
+	require.Equal(t, image.Pt(32, 24), compressed.Bounds().Size())
+
+	req = httptest.NewRequest(http.MethodGet, "/api/download/"+requestID.String()+"?original=true", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, content.Bytes(), w.Body.Bytes())
+}
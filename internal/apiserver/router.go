@@ -60,7 +60,7 @@ func (s *Server) newAPIRouter() {
 	// swagger:operation GET /api/history history history
 	// ---
 	// summary: Finds users history.
-	// description: Lists all queries created by user.
+	// description: Lists all queries created by user, the finished ones carry the BlurHash and the placeholder data URI of the result.
 	// responses:
 	//   "200":
 	//     description: successful operation
@@ -72,12 +72,64 @@ func (s *Server) newAPIRouter() {
 	// swagger:operation POST /api/compress compress compress
 	// ---
 	// summary: Compresses the image.
-	// description: Receives an image from an input form and compresses it, also allows you to enter width in the query string.
+	// description: Receives an image from an input form and resizes it into the box set by width and height in the query string.
 	// parameters:
 	// - name: width
 	//   in: query
 	//   type: integer
 	//   required: false
+	// - name: height
+	//   in: query
+	//   type: integer
+	//   required: false
+	// - name: mode
+	//   in: query
+	//   type: string
+	//   enum: [fit, fill, pad, exact]
+	//   required: false
+	//   description: fit keeps the aspect ratio within the box and never enlarges the image, fill crops the box out of the scaled image according to the gravity, pad fills the rest of the box with the background, exact stretches to the box. Fill, pad and exact enlarge an image smaller than the box.
+	// - name: background
+	//   in: query
+	//   type: string
+	//   required: false
+	//   description: hex color used by the pad mode, white by default.
+	// - name: gravity
+	//   in: query
+	//   type: string
+	//   enum: [center, north, south, east, west, north-east, north-west, south-east, south-west, smart]
+	//   required: false
+	//   description: the part of the image kept by the fill mode, center by default, smart keeps the most detailed part.
+	// - name: quality
+	//   in: query
+	//   type: integer
+	//   required: false
+	//   description: JPEG quality from 1 to 100, 95 by default.
+	// - name: png_level
+	//   in: query
+	//   type: integer
+	//   required: false
+	//   description: PNG compression level from 0 to 9, 9 by default.
+	// - name: colors
+	//   in: query
+	//   type: integer
+	//   required: false
+	//   description: Quantizes PNG results to a palette of 2 to 256 colors, truecolor by default.
+	// - name: dither
+	//   in: query
+	//   type: boolean
+	//   required: false
+	//   description: Floyd–Steinberg dithering of the quantized PNG results, true by default.
+	// - name: metadata
+	//   in: query
+	//   type: string
+	//   enum: [strip, keep, keep-safe]
+	//   required: false
+	//   description: Metadata policy, keep-safe keeps the color profile, EXIF and XMP without the location, serial numbers and owner, strip by default.
+	// - name: auto_orient
+	//   in: query
+	//   type: boolean
+	//   required: false
+	//   description: Rotate and flip the image according to its EXIF orientation, true by default.
 	// - name: uploadFile
 	//   in: body
 	//   required: true
@@ -86,16 +138,299 @@ func (s *Server) newAPIRouter() {
 	// responses:
 	//   "202":
 	//     description: request accepted
+	//   "400":
+	//     description: bad request
 	//   "401":
 	//     description: login required
+	//   "413":
+	//     description: image is too large
+	//   "415":
+	//     description: file content does not match its type
 	//   "500":
 	//     description: internal server error
-	apiRouter.HandleFunc("/compress", s.authorize(s.compressImage())).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/compress", s.authorize(s.streamUpload(s.compressImage()))).Methods(http.MethodPost)
 	// swagger:operation POST /api/convert convert convert
 	// ---
 	// summary: Converts the image.
-	// description: Receives an image from an input form and converts it PNG to JPG and vice versa.
+	// description: Receives an image from an input form and converts it to the target format.
+	// parameters:
+	// - name: format
+	//   in: query
+	//   type: string
+	//   enum: [bmp, gif, jpeg, png, tiff]
+	//   required: true
+	//   description: the name of the target format.
+	// - name: quality
+	//   in: query
+	//   type: integer
+	//   required: false
+	//   description: JPEG quality from 1 to 100, 95 by default.
+	// - name: png_level
+	//   in: query
+	//   type: integer
+	//   required: false
+	//   description: PNG compression level from 0 to 9, 9 by default.
+	// - name: metadata
+	//   in: query
+	//   type: string
+	//   enum: [strip, keep, keep-safe]
+	//   required: false
+	//   description: Metadata policy, keep-safe keeps the color profile, EXIF and XMP without the location, serial numbers and owner, strip by default.
+	// - name: auto_orient
+	//   in: query
+	//   type: boolean
+	//   required: false
+	//   description: Rotate and flip the image according to its EXIF orientation, true by default.
+	// - name: uploadFile
+	//   in: body
+	//   required: true
+	//   schema:
+	//     "$ref": "#/definitions/Image"
+	// responses:
+	//   "202":
+	//     description: request accepted
+	//   "400":
+	//     description: bad request
+	//   "401":
+	//     description: login required
+	//   "413":
+	//     description: image is too large
+	//   "415":
+	//     description: file content does not match its type
+	//   "500":
+	//     description: internal server error
+	apiRouter.HandleFunc("/convert", s.authorize(s.streamUpload(s.convertImage()))).Methods(http.MethodPost)
+	// swagger:operation POST /api/crop crop crop
+	// ---
+	// summary: Crops the image.
+	// description: Receives an image from an input form and crops it to the rectangle or the aspect ratio.
+	// parameters:
+	// - name: x
+	//   in: query
+	//   type: integer
+	//   required: false
+	//   description: left edge of the rectangle, 0 by default.
+	// - name: y
+	//   in: query
+	//   type: integer
+	//   required: false
+	//   description: top edge of the rectangle, 0 by default.
+	// - name: width
+	//   in: query
+	//   type: integer
+	//   required: false
+	//   description: width of the rectangle, it is set together with height instead of aspect.
+	// - name: height
+	//   in: query
+	//   type: integer
+	//   required: false
+	//   description: height of the rectangle, it is set together with width instead of aspect.
+	// - name: aspect
+	//   in: query
+	//   type: string
+	//   required: false
+	//   description: aspect ratio in W:H notation, the largest part of the image with this ratio is kept.
+	// - name: gravity
+	//   in: query
+	//   type: string
+	//   enum: [center, north, south, east, west, north-east, north-west, south-east, south-west, smart]
+	//   required: false
+	//   description: the part of the image kept by the aspect ratio, center by default, smart keeps the most detailed part.
+	// - name: quality
+	//   in: query
+	//   type: integer
+	//   required: false
+	//   description: JPEG quality from 1 to 100, 95 by default.
+	// - name: png_level
+	//   in: query
+	//   type: integer
+	//   required: false
+	//   description: PNG compression level from 0 to 9, 9 by default.
+	// - name: metadata
+	//   in: query
+	//   type: string
+	//   enum: [strip, keep, keep-safe]
+	//   required: false
+	//   description: Metadata policy, keep-safe keeps the color profile, EXIF and XMP without the location, serial numbers and owner, strip by default.
+	// - name: auto_orient
+	//   in: query
+	//   type: boolean
+	//   required: false
+	//   description: Rotate and flip the image according to its EXIF orientation, true by default.
+	// - name: uploadFile
+	//   in: body
+	//   required: true
+	//   schema:
+	//     "$ref": "#/definitions/Image"
+	// responses:
+	//   "202":
+	//     description: request accepted
+	//   "400":
+	//     description: bad request
+	//   "401":
+	//     description: login required
+	//   "413":
+	//     description: image is too large
+	//   "415":
+	//     description: file content does not match its type
+	//   "500":
+	//     description: internal server error
+	apiRouter.HandleFunc("/crop", s.authorize(s.streamUpload(s.cropImage()))).Methods(http.MethodPost)
+	// swagger:operation POST /api/transform transform transform
+	// ---
+	// summary: Rotates and flips the image.
+	// description: Receives an image from an input form, flips it and then rotates it clockwise.
+	// parameters:
+	// - name: rotate
+	//   in: query
+	//   type: number
+	//   required: false
+	//   description: clockwise rotation in degrees, angles that are not multiples of 90 enlarge the canvas.
+	// - name: flip
+	//   in: query
+	//   type: string
+	//   enum: [horizontal, vertical, both]
+	//   required: false
+	//   description: the direction the image is mirrored in.
+	// - name: background
+	//   in: query
+	//   type: string
+	//   required: false
+	//   description: color of the corners uncovered by the rotation in hex notation, ffffff by default.
+	// - name: quality
+	//   in: query
+	//   type: integer
+	//   required: false
+	//   description: JPEG quality from 1 to 100, 95 by default.
+	// - name: png_level
+	//   in: query
+	//   type: integer
+	//   required: false
+	//   description: PNG compression level from 0 to 9, 9 by default.
+	// - name: metadata
+	//   in: query
+	//   type: string
+	//   enum: [strip, keep, keep-safe]
+	//   required: false
+	//   description: Metadata policy, keep-safe keeps the color profile, EXIF and XMP without the location, serial numbers and owner, strip by default.
+	// - name: auto_orient
+	//   in: query
+	//   type: boolean
+	//   required: false
+	//   description: Rotate and flip the image according to its EXIF orientation, true by default.
+	// - name: uploadFile
+	//   in: body
+	//   required: true
+	//   schema:
+	//     "$ref": "#/definitions/Image"
+	// responses:
+	//   "202":
+	//     description: request accepted
+	//   "400":
+	//     description: bad request
+	//   "401":
+	//     description: login required
+	//   "413":
+	//     description: image is too large
+	//   "415":
+	//     description: file content does not match its type
+	//   "500":
+	//     description: internal server error
+	apiRouter.HandleFunc("/transform", s.authorize(s.streamUpload(s.transformImage()))).Methods(http.MethodPost)
+	// swagger:operation POST /api/pipeline pipeline pipeline
+	// ---
+	// summary: Runs a chain of operations on the image.
+	// description: Receives an image from an input form and applies the steps in order, only the final image is stored.
+	// parameters:
+	// - name: steps
+	//   in: formData
+	//   type: string
+	//   required: true
+	//   description: >
+	//     JSON array of up to 20 steps, e.g. [{"op":"crop","aspect":"4:3"},{"op":"resize","width":800,"height":600},
+	//     {"op":"grayscale"},{"op":"watermark","text":"© Studio","position":"bottom-right"},{"op":"convert","format":"jpeg","quality":82}].
+	//     Operations are resize, crop, transform, grayscale, filter, watermark and convert, they take the parameters of the matching endpoints.
+	//     watermark takes text, color, position, opacity, margin and scale, the saved default watermark is used when no text is sent.
+	//     convert can only be the last step.
+	// - name: quality
+	//   in: query
+	//   type: integer
+	//   required: false
+	//   description: JPEG quality from 1 to 100, 95 by default.
+	// - name: png_level
+	//   in: query
+	//   type: integer
+	//   required: false
+	//   description: PNG compression level from 0 to 9, 9 by default.
+	// - name: metadata
+	//   in: query
+	//   type: string
+	//   enum: [strip, keep, keep-safe]
+	//   required: false
+	//   description: Metadata policy, keep-safe keeps the color profile, EXIF and XMP without the location, serial numbers and owner, strip by default.
+	// - name: auto_orient
+	//   in: query
+	//   type: boolean
+	//   required: false
+	//   description: Rotate and flip the image according to its EXIF orientation, true by default.
+	// - name: uploadFile
+	//   in: body
+	//   required: true
+	//   schema:
+	//     "$ref": "#/definitions/Image"
+	// responses:
+	//   "202":
+	//     description: request accepted
+	//   "400":
+	//     description: bad request
+	//   "401":
+	//     description: login required
+	//   "413":
+	//     description: image is too large
+	//   "415":
+	//     description: file content does not match its type
+	//   "500":
+	//     description: internal server error
+	apiRouter.HandleFunc("/pipeline", s.authorize(s.streamUpload(s.pipelineImage()))).Methods(http.MethodPost)
+	// swagger:operation POST /api/filter filter filter
+	// ---
+	// summary: Applies color adjustments and filters to the image.
+	// description: Receives an image from an input form and applies the filters in order.
 	// parameters:
+	// - name: filters
+	//   in: formData
+	//   type: string
+	//   required: true
+	//   description: >
+	//     JSON array of up to 20 adjustments, e.g. [{"filter":"brightness","value":10},{"filter":"blur","radius":2}].
+	//     grayscale and sepia take no value, brightness, contrast and saturation take a value from -100 to 100,
+	//     gamma takes a value up to 10, blur takes a radius up to 50, sharpen takes a value up to 10 and an optional radius.
+	// - name: format
+	//   in: query
+	//   type: string
+	//   required: false
+	//   description: the name of the target format, the format of the original is kept by default.
+	// - name: quality
+	//   in: query
+	//   type: integer
+	//   required: false
+	//   description: JPEG quality from 1 to 100, 95 by default.
+	// - name: png_level
+	//   in: query
+	//   type: integer
+	//   required: false
+	//   description: PNG compression level from 0 to 9, 9 by default.
+	// - name: metadata
+	//   in: query
+	//   type: string
+	//   enum: [strip, keep, keep-safe]
+	//   required: false
+	//   description: Metadata policy, keep-safe keeps the color profile, EXIF and XMP without the location, serial numbers and owner, strip by default.
+	// - name: auto_orient
+	//   in: query
+	//   type: boolean
+	//   required: false
+	//   description: Rotate and flip the image according to its EXIF orientation, true by default.
 	// - name: uploadFile
 	//   in: body
 	//   required: true
@@ -104,11 +439,377 @@ func (s *Server) newAPIRouter() {
 	// responses:
 	//   "202":
 	//     description: request accepted
+	//   "400":
+	//     description: bad request
+	//   "401":
+	//     description: login required
+	//   "413":
+	//     description: image is too large
+	//   "415":
+	//     description: file content does not match its type
+	//   "500":
+	//     description: internal server error
+	apiRouter.HandleFunc("/filter", s.authorize(s.streamUpload(s.filterImage()))).Methods(http.MethodPost)
+	// swagger:operation POST /api/watermark watermark watermark
+	// ---
+	// summary: Overlays a logo or a text on the image.
+	// description: Receives an image from an input form and overlays the watermark, the saved default watermark is used when neither logo nor text is sent.
+	// parameters:
+	// - name: text
+	//   in: formData
+	//   type: string
+	//   required: false
+	//   description: text of up to 200 characters rendered with the embedded Go font, it is used instead of a logo.
+	// - name: logo
+	//   in: formData
+	//   type: file
+	//   required: false
+	//   description: PNG logo of up to 1 MB, it is used instead of a text.
+	// - name: color
+	//   in: query
+	//   type: string
+	//   required: false
+	//   description: color of the text in hex notation, ffffff by default.
+	// - name: position
+	//   in: query
+	//   type: string
+	//   enum: [top-left, top-right, bottom-left, bottom-right, center, tiled]
+	//   required: false
+	//   description: the place of the watermark, bottom-right by default.
+	// - name: opacity
+	//   in: query
+	//   type: number
+	//   required: false
+	//   description: opacity of the watermark greater than 0 and at most 1, 0.5 by default.
+	// - name: margin
+	//   in: query
+	//   type: integer
+	//   required: false
+	//   description: distance in pixels from the edges of the image and between tiles, 16 by default.
+	// - name: scale
+	//   in: query
+	//   type: number
+	//   required: false
+	//   description: width of the watermark relative to the width of the image, 0.25 by default.
+	// - name: format
+	//   in: query
+	//   type: string
+	//   required: false
+	//   description: the name of the target format, the format of the original is kept by default.
+	// - name: quality
+	//   in: query
+	//   type: integer
+	//   required: false
+	//   description: JPEG quality from 1 to 100, 95 by default.
+	// - name: png_level
+	//   in: query
+	//   type: integer
+	//   required: false
+	//   description: PNG compression level from 0 to 9, 9 by default.
+	// - name: metadata
+	//   in: query
+	//   type: string
+	//   enum: [strip, keep, keep-safe]
+	//   required: false
+	//   description: Metadata policy, keep-safe keeps the color profile, EXIF and XMP without the location, serial numbers and owner, strip by default.
+	// - name: auto_orient
+	//   in: query
+	//   type: boolean
+	//   required: false
+	//   description: Rotate and flip the image according to its EXIF orientation, true by default.
+	// - name: uploadFile
+	//   in: body
+	//   required: true
+	//   schema:
+	//     "$ref": "#/definitions/Image"
+	// responses:
+	//   "202":
+	//     description: request accepted
+	//   "400":
+	//     description: bad request
+	//   "401":
+	//     description: login required
+	//   "413":
+	//     description: image is too large
+	//   "415":
+	//     description: file content does not match its type
+	//   "500":
+	//     description: internal server error
+	apiRouter.HandleFunc("/watermark", s.authorize(s.streamUpload(s.watermarkImage()))).Methods(http.MethodPost)
+	// swagger:operation PUT /api/watermark/default saveWatermark saveWatermark
+	// ---
+	// summary: Saves the default watermark of the user.
+	// description: The default watermark is used by the watermark operation when neither logo nor text is sent, the previous one is replaced.
+	// parameters:
+	// - name: text
+	//   in: formData
+	//   type: string
+	//   required: false
+	//   description: text of up to 200 characters rendered with the embedded Go font, it is used instead of a logo.
+	// - name: logo
+	//   in: formData
+	//   type: file
+	//   required: false
+	//   description: PNG logo of up to 1 MB, it is used instead of a text.
+	// - name: color
+	//   in: query
+	//   type: string
+	//   required: false
+	//   description: color of the text in hex notation, ffffff by default.
+	// - name: position
+	//   in: query
+	//   type: string
+	//   enum: [top-left, top-right, bottom-left, bottom-right, center, tiled]
+	//   required: false
+	//   description: the place of the watermark, bottom-right by default.
+	// - name: opacity
+	//   in: query
+	//   type: number
+	//   required: false
+	//   description: opacity of the watermark greater than 0 and at most 1, 0.5 by default.
+	// - name: margin
+	//   in: query
+	//   type: integer
+	//   required: false
+	//   description: distance in pixels from the edges of the image and between tiles, 16 by default.
+	// - name: scale
+	//   in: query
+	//   type: number
+	//   required: false
+	//   description: width of the watermark relative to the width of the image, 0.25 by default.
+	// responses:
+	//   "200":
+	//     description: successful operation
+	//   "400":
+	//     description: bad request
+	//   "401":
+	//     description: login required
+	//   "413":
+	//     description: image is too large
+	//   "500":
+	//     description: internal server error
+	apiRouter.HandleFunc("/watermark/default", s.authorize(s.saveWatermark())).Methods(http.MethodPut)
+	// swagger:operation GET /api/watermark/default findWatermark findWatermark
+	// ---
+	// summary: Finds the default watermark of the user.
+	// description: Shows the settings of the default watermark, has_logo tells if it has a logo.
+	// responses:
+	//   "200":
+	//     description: successful operation
+	//   "401":
+	//     description: login required
+	//   "404":
+	//     description: watermark not found
+	//   "500":
+	//     description: internal server error
+	apiRouter.HandleFunc("/watermark/default", s.authorize(s.findWatermark())).Methods(http.MethodGet)
+	// swagger:operation DELETE /api/watermark/default deleteWatermark deleteWatermark
+	// ---
+	// summary: Deletes the default watermark of the user.
+	// description: Deletes the default watermark of the user.
+	// responses:
+	//   "204":
+	//     description: watermark deleted
+	//   "401":
+	//     description: login required
+	//   "404":
+	//     description: watermark not found
+	//   "500":
+	//     description: internal server error
+	apiRouter.HandleFunc("/watermark/default", s.authorize(s.deleteWatermark())).Methods(http.MethodDelete)
+	// swagger:operation POST /api/thumbnails thumbnails thumbnails
+	// ---
+	// summary: Produces a set of renditions of the image.
+	// description: Receives an image from an input form and resizes it to every width in every format, each rendition is stored as a separate result.
+	// parameters:
+	// - name: widths
+	//   in: query
+	//   type: string
+	//   required: true
+	//   description: comma separated list of up to 10 widths, e.g. 320,640,1280,1920. Widths larger than the image are skipped.
+	// - name: formats
+	//   in: query
+	//   type: string
+	//   required: false
+	//   description: comma separated list of up to 5 target formats, e.g. jpeg,png. The format of the original is kept by default.
+	// - name: quality
+	//   in: query
+	//   type: integer
+	//   required: false
+	//   description: JPEG quality from 1 to 100, 95 by default.
+	// - name: png_level
+	//   in: query
+	//   type: integer
+	//   required: false
+	//   description: PNG compression level from 0 to 9, 9 by default.
+	// - name: metadata
+	//   in: query
+	//   type: string
+	//   enum: [strip, keep, keep-safe]
+	//   required: false
+	//   description: Metadata policy, keep-safe keeps the color profile, EXIF and XMP without the location, serial numbers and owner, strip by default.
+	// - name: auto_orient
+	//   in: query
+	//   type: boolean
+	//   required: false
+	//   description: Rotate and flip the image according to its EXIF orientation, true by default.
+	// - name: uploadFile
+	//   in: body
+	//   required: true
+	//   schema:
+	//     "$ref": "#/definitions/Image"
+	// responses:
+	//   "202":
+	//     description: request accepted
+	//   "400":
+	//     description: bad request
+	//   "401":
+	//     description: login required
+	//   "413":
+	//     description: image is too large
+	//   "415":
+	//     description: file content does not match its type
+	//   "500":
+	//     description: internal server error
+	apiRouter.HandleFunc("/thumbnails", s.authorize(s.streamUpload(s.thumbnailImage()))).Methods(http.MethodPost)
+	// swagger:operation GET /api/thumbnails/{requestID} findThumbnails findThumbnails
+	// ---
+	// summary: Lists the renditions of the request.
+	// description: Lists the renditions with their download paths, srcset contains the ready-made srcset attribute for every format.
+	// parameters:
+	// - name: requestID
+	//   in: path
+	//   description: requestID of the thumbnail request
+	//   required: true
+	//   type: string
+	// responses:
+	//   "200":
+	//     description: successful operation
+	//   "400":
+	//     description: bad request
+	//   "401":
+	//     description: login required
+	//   "403":
+	//     description: forbidden
+	//   "404":
+	//     description: renditions not found
+	//   "409":
+	//     description: image is being processed
+	apiRouter.HandleFunc("/thumbnails/{requestID}", s.authorize(s.findThumbnails())).Methods(http.MethodGet)
+	// swagger:operation GET /api/thumbnails/{requestID}/{renditionID} findThumbnail findThumbnail
+	// ---
+	// summary: Downloads a rendition.
+	// description: Downloads one of the renditions of the request.
+	// parameters:
+	// - name: requestID
+	//   in: path
+	//   description: requestID of the thumbnail request
+	//   required: true
+	//   type: string
+	// - name: renditionID
+	//   in: path
+	//   description: id of the rendition
+	//   required: true
+	//   type: string
+	// responses:
+	//   "200":
+	//     description: successful operation
+	//   "400":
+	//     description: bad request
+	//   "401":
+	//     description: login required
+	//   "403":
+	//     description: forbidden
+	//   "404":
+	//     description: rendition not found
+	//   "500":
+	//     description: internal server error
+	apiRouter.HandleFunc("/thumbnails/{requestID}/{renditionID}", s.authorize(s.findThumbnail())).Methods(http.MethodGet)
+	// swagger:operation GET /api/images/{imageID}/info inspectImage inspectImage
+	// ---
+	// summary: Inspects the image.
+	// description: Describes the original image and the result of its request from their headers, the format, dimensions, color model, bit depth, transparency, file size, SHA-256 and the main EXIF fields are returned without decoding the pixels.
+	// parameters:
+	// - name: imageID
+	//   in: path
+	//   description: image_id from the history
+	//   required: true
+	//   type: string
+	// responses:
+	//   "200":
+	//     description: successful operation
+	//   "400":
+	//     description: bad request
+	//   "401":
+	//     description: login required
+	//   "404":
+	//     description: image not found
+	//   "500":
+	//     description: internal server error
+	apiRouter.HandleFunc("/images/{imageID}/info", s.authorize(s.inspectImage())).Methods(http.MethodGet)
+	// swagger:operation GET /api/images/{imageID}/similar findSimilarImages findSimilarImages
+	// ---
+	// summary: Finds similar images.
+	// description: Lists the other uploads of the user whose perceptual hash is within the Hamming distance of the image, the closest go first. The distance is computed for every image of the user, the search is a full scan.
+	// parameters:
+	// - name: imageID
+	//   in: path
+	//   description: image_id from the history
+	//   required: true
+	//   type: string
+	// - name: distance
+	//   in: query
+	//   type: integer
+	//   required: false
+	//   description: the largest Hamming distance from 0 to 64, 10 by default.
+	// responses:
+	//   "200":
+	//     description: successful operation
+	//   "400":
+	//     description: bad request
+	//   "401":
+	//     description: login required
+	//   "404":
+	//     description: image not found
+	//   "409":
+	//     description: image has not been analysed yet
+	//   "500":
+	//     description: internal server error
+	apiRouter.HandleFunc("/images/{imageID}/similar", s.authorize(s.findSimilarImages())).Methods(http.MethodGet)
+	// swagger:operation POST /api/palette palette palette
+	// ---
+	// summary: Finds the dominant colors of the image.
+	// description: Receives an image from an input form and finds its dominant colors, the palette is returned by the status of the request once it is done.
+	// parameters:
+	// - name: colors
+	//   in: query
+	//   type: integer
+	//   required: false
+	//   description: number of the dominant colors from 1 to 16, 5 by default.
+	// - name: auto_orient
+	//   in: query
+	//   type: boolean
+	//   required: false
+	//   description: Rotate and flip the image according to its EXIF orientation, true by default.
+	// - name: uploadFile
+	//   in: body
+	//   required: true
+	//   schema:
+	//     "$ref": "#/definitions/Image"
+	// responses:
+	//   "202":
+	//     description: request accepted
+	//   "400":
+	//     description: bad request
 	//   "401":
 	//     description: login required
+	//   "413":
+	//     description: image is too large
+	//   "415":
+	//     description: file content does not match its type
 	//   "500":
 	//     description: internal server error
-	apiRouter.HandleFunc("/convert", s.authorize(s.convertImage())).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/palette", s.authorize(s.streamUpload(s.paletteImage()))).Methods(http.MethodPost)
 	// swagger:operation GET /api/download/{requestID} findImage findImage
 	// ---
 	// summary: Finds and downloads an image.
@@ -141,7 +842,7 @@ func (s *Server) newAPIRouter() {
 	// swagger:operation GET /api/status/{requestID} findRequestStatus findRequestStatus
 	// ---
 	// summary: Finds the status of the request.
-	// description: Finds the status of the request.
+	// description: Finds the status of the request, a finished request also carries the BlurHash and the placeholder data URI of the result, the sizes of the original and the result with the bytes saved and the palette of a palette request.
 	// parameters:
 	// - name: requestID
 	//   in: path
@@ -10,6 +10,8 @@ import (
 
 	"github.com/alisavch/image-service/internal/log"
 	"github.com/alisavch/image-service/internal/models"
+	"github.com/alisavch/image-service/internal/storage"
+	"github.com/alisavch/image-service/internal/utils"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -25,17 +27,19 @@ func NewLogger() *Logger {
 	return &Logger{log.NewLogger()}
 }
 
-// Service combines the interfaces for interaction with the service.
+// Service combines the interfaces for interaction with the service and the limits of the uploaded images.
 type Service struct {
 	ServiceOperations
-	S3Bucket
+	storage.Storage
+	limits utils.LimitsConfig
 }
 
 // NewAPI configures Service.
-func NewAPI(operations ServiceOperations, bucket S3Bucket) *Service {
+func NewAPI(operations ServiceOperations, store storage.Storage, limits utils.LimitsConfig) *Service {
 	return &Service{
 		ServiceOperations: operations,
-		S3Bucket:          bucket,
+		Storage:           store,
+		limits:            limits,
 	}
 }
 
@@ -89,10 +93,15 @@ func (s *Server) respondFormData(w http.ResponseWriter, code int, id uuid.UUID)
 }
 
 func (s *Server) respondImage(w http.ResponseWriter, image *models.SavedImage) {
-	w.WriteHeader(http.StatusOK)
+	defer func() {
+		if err := image.File.Close(); err != nil {
+			s.logger.Printf("%s:%s", "failed image.File.Close", err)
+		}
+	}()
 	w.Header().Set("Content-Disposition", "attachment; filename="+image.Filename)
 	w.Header().Set("Content-Type", image.ContentType)
 	w.Header().Set("Content-Length", strconv.FormatInt(image.Filesize, 10))
+	w.WriteHeader(http.StatusOK)
 	_, err := io.Copy(w, image.File)
 	if err != nil {
 		return
@@ -1,5 +1,10 @@
 package broker
 
+import (
+	"github.com/alisavch/image-service/internal/storage"
+	"github.com/alisavch/image-service/internal/utils"
+)
+
 // AMQPBrokerAPI contains interfaces.
 type AMQPBrokerAPI struct {
 	*ProcessMessage
@@ -15,7 +20,7 @@ type AMQPBrokerConsumer struct {
 	*ProcessMessage
 }
 
-// NewAMQPBrokerConsumer configures AMQPBrokerConsumer.
-func NewAMQPBrokerConsumer(image Image, bucket S3Bucket) *AMQPBrokerConsumer {
-	return &AMQPBrokerConsumer{ProcessMessage: NewProcessMessageConsumer(NewService(image, bucket))}
+// NewAMQPBrokerConsumer configures AMQPBrokerConsumer, the original images that exceed the limits are not processed.
+func NewAMQPBrokerConsumer(image Image, store storage.Storage, limits utils.LimitsConfig) *AMQPBrokerConsumer {
+	return &AMQPBrokerConsumer{ProcessMessage: NewProcessMessageConsumer(NewService(image, store), limits)}
 }
@@ -3,12 +3,11 @@ package broker
 import (
 	"context"
 	"image"
-	"io"
-	"os"
 
 	"github.com/google/uuid"
 
 	"github.com/alisavch/image-service/internal/models"
+	"github.com/alisavch/image-service/internal/service"
 )
 
 // FormattingOutput contains methods for formatting log output.
@@ -18,18 +17,20 @@ type FormattingOutput interface {
 	Errorf(format string, args ...interface{})
 }
 
-// S3Bucket contains the basic functions for interacting with the bucket.
-type S3Bucket interface {
-	UploadToS3Bucket(file io.Reader, filename string) (string, error)
-	DownloadFromS3Bucket(filename string) (*os.File, error)
-}
-
 // Image contains methods for working with images.
 type Image interface {
-	CompressImage(width int, format, resultedName string, img image.Image, newImg *os.File, storage string) (models.Image, error)
+	CompressImage(resize models.Resize, format, resultedName string, img image.Image, opts ...service.EncodeOption) (models.Image, error)
+	CropImage(crop models.Crop, format, resultedName string, img image.Image, opts ...service.EncodeOption) (models.Image, error)
+	TransformImage(transform models.Transform, format, resultedName string, img image.Image, opts ...service.EncodeOption) (models.Image, error)
+	PipelineImage(steps []models.Step, format, resultedName string, img image.Image, opts ...service.EncodeOption) (models.Image, error)
 	UploadResultedImage(ctx context.Context, img models.Image) error
-	ChangeFormat(filename string) (string, error)
-	ConvertToType(format, resultedName string, img image.Image, newImg *os.File, storage string) (models.Image, error)
+	ChangeFormat(filename, format string) (string, error)
+	ConvertToType(format, resultedName string, img image.Image, opts ...service.EncodeOption) (models.Image, error)
 	CompleteRequest(ctx context.Context, id uuid.UUID, status models.Status) error
 	UpdateStatus(ctx context.Context, id uuid.UUID, status models.Status) error
+	ThumbnailImage(thumbnails models.Thumbnails, format, resultedName string, img image.Image, opts ...service.EncodeOption) ([]models.Rendition, error)
+	CreateRenditions(ctx context.Context, requestID uuid.UUID, renditions []models.Rendition) error
+	SaveImageHash(ctx context.Context, imageID uuid.UUID, hash uint64) error
+	PaletteImage(palette models.Palette, img image.Image) ([]models.PaletteColor, error)
+	CreatePalette(ctx context.Context, requestID uuid.UUID, palette []models.PaletteColor) error
 }
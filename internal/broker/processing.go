@@ -2,51 +2,86 @@ package broker
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"image"
-	"os"
+	"path"
 
 	"github.com/alisavch/image-service/internal/service"
+	"github.com/alisavch/image-service/internal/storage"
 
 	"github.com/alisavch/image-service/internal/models"
 	"github.com/alisavch/image-service/internal/utils"
-)
 
-const (
-	aws   = "AWS"
-	local = "local"
+	"github.com/google/uuid"
 )
 
 // Process service processes.
 func (process *ProcessMessage) Process(message models.QueuedMessage) error {
-	conf := utils.NewConfig()
 	ctx := context.Background()
 
+	var result models.Image
+	var err error
 	switch message.Service {
 	case models.Compression:
-		compressedImage, err := process.Compress(message, conf.Storage)
+		result, err = process.Compress(message)
+	case models.Conversion:
+		result, err = process.Convert(message)
+	case models.Cropping:
+		result, err = process.Crop(message)
+	case models.Transformation:
+		result, err = process.Transform(message)
+	case models.Pipeline:
+		result, err = process.Pipeline(message)
+	case models.Filtering:
+		result, err = process.Filter(message)
+	case models.Watermarking:
+		result, err = process.Watermark(message)
+
+	case models.Thumbnailing:
+		var renditions []models.Rendition
+		result, renditions, err = process.Thumbnails(message)
+		if err != nil {
+			break
+		}
+
+		err = process.ImageService.CreateRenditions(ctx, message.RequestID, renditions)
 		if err != nil {
-			process.logger.Printf("%s:%s", "Failed to compress image", err)
 			return err
 		}
-		message.Image.ResultedName = compressedImage.ResultedName
-		message.Image.ResultedLocation = compressedImage.ResultedLocation
+		process.logger.Printf("%s:%d", "Renditions saved", len(renditions))
 
-	case models.Conversion:
-		convertedImage, err := process.Convert(message, conf.Storage)
+	case models.PaletteAnalysis:
+		var palette []models.PaletteColor
+		palette, err = process.Palette(message)
+		if err != nil {
+			break
+		}
+
+		err = process.ImageService.CreatePalette(ctx, message.RequestID, palette)
 		if err != nil {
-			process.logger.Printf("%s:%s", "Failed to convert image", err)
 			return err
 		}
-		message.Image.ResultedName = convertedImage.ResultedName
-		message.Image.ResultedLocation = convertedImage.ResultedLocation
+		process.logger.Printf("%s:%d", "Palette saved", len(palette))
 	}
-
-	err := process.ImageService.UploadResultedImage(ctx, message.Image)
 	if err != nil {
+		process.logger.Printf("%s %s:%s", "Failed to process image", message.Service, err)
 		return err
 	}
-	process.logger.Printf("%s:%s", "Resulted image uploaded", message.Image.ResultedName)
+
+	// The palette is saved on its own, a palette request has no resulted image.
+	if message.Service != models.PaletteAnalysis {
+		message.Image.ResultedName = result.ResultedName
+		message.Image.ResultedLocation = result.ResultedLocation
+		message.Image.ResultedSize = result.ResultedSize
+		message.Image.BlurHash = result.BlurHash
+		message.Image.Placeholder = result.Placeholder
+
+		err = process.ImageService.UploadResultedImage(ctx, message.Image)
+		if err != nil {
+			return err
+		}
+		process.logger.Printf("%s:%s", "Resulted image uploaded", message.Image.ResultedName)
+	}
 
 	err = process.ImageService.CompleteRequest(ctx, message.RequestID, models.Done)
 	if err != nil {
@@ -58,121 +93,221 @@ func (process *ProcessMessage) Process(message models.QueuedMessage) error {
 }
 
 // Compress is the compression service.
-func (process *ProcessMessage) Compress(message models.QueuedMessage, storage string) (models.Image, error) {
-	process.logger.Printf("%s:%s", "Process started", message.Service)
-	resultedName := newImgName("cmp-" + message.UploadedName)
-	process.logger.Printf("%s:%s", "Image renamed", resultedName)
-
-	img, format, file, err := process.prepareImage(message.Image, message.Image.UploadedName, resultedName)
+func (process *ProcessMessage) Compress(message models.QueuedMessage) (models.Image, error) {
+	resultedName, err := process.keepFormatName("cmp-" + message.UploadedName)
 	if err != nil {
 		return models.Image{}, err
 	}
 
-	compressedImage, err := process.ImageService.CompressImage(message.Width, format, resultedName, img, file, storage)
+	return process.processOriginal(message, resultedName, func(format string, img image.Image, opts ...service.EncodeOption) (models.Image, error) {
+		return process.ImageService.CompressImage(message.Resize, format, resultedName, img, opts...)
+	})
+}
+
+// Convert is the conversion service.
+func (process *ProcessMessage) Convert(message models.QueuedMessage) (models.Image, error) {
+	resultedName, err := process.ImageService.ChangeFormat("cnv-"+message.UploadedName, message.Format)
 	if err != nil {
 		return models.Image{}, err
 	}
-	process.logger.Printf("%s:%s", "Process finished", message.Service)
 
-	return compressedImage, nil
+	return process.processOriginal(message, resultedName, func(_ string, img image.Image, opts ...service.EncodeOption) (models.Image, error) {
+		return process.ImageService.ConvertToType(message.Format, resultedName, img, opts...)
+	})
 }
 
-// Convert is the conversion service.
-func (process *ProcessMessage) Convert(message models.QueuedMessage, storage string) (models.Image, error) {
-	process.logger.Printf("%s:%s", "Process started", message.Service)
-	convertedName, err := process.ImageService.ChangeFormat(message.UploadedName)
+// Crop is the cropping service.
+func (process *ProcessMessage) Crop(message models.QueuedMessage) (models.Image, error) {
+	resultedName, err := process.keepFormatName("crp-" + message.UploadedName)
 	if err != nil {
 		return models.Image{}, err
 	}
-	process.logger.Printf("%s:%s", "Image format changed", convertedName)
 
-	resultedName := newImgName("cnv-" + convertedName)
-	process.logger.Printf("%s:%s", "Image renamed", resultedName)
+	return process.processOriginal(message, resultedName, func(format string, img image.Image, opts ...service.EncodeOption) (models.Image, error) {
+		return process.ImageService.CropImage(message.Crop, format, resultedName, img, opts...)
+	})
+}
 
-	img, format, file, err := process.prepareImage(message.Image, message.Image.UploadedName, resultedName)
+// Transform is the rotation and flipping service.
+func (process *ProcessMessage) Transform(message models.QueuedMessage) (models.Image, error) {
+	resultedName, err := process.keepFormatName("trn-" + message.UploadedName)
 	if err != nil {
 		return models.Image{}, err
 	}
 
-	convertedImage, err := process.ImageService.ConvertToType(format, resultedName, img, file, storage)
+	return process.processOriginal(message, resultedName, func(format string, img image.Image, opts ...service.EncodeOption) (models.Image, error) {
+		return process.ImageService.TransformImage(message.Transform, format, resultedName, img, opts...)
+	})
+}
+
+// Pipeline runs the chain of operations on the image.
+func (process *ProcessMessage) Pipeline(message models.QueuedMessage) (models.Image, error) {
+	return process.runSteps(message, "ppl-")
+}
+
+// Filter is the filtering service, its adjustments are sent as a filter step optionally followed by a convert step.
+func (process *ProcessMessage) Filter(message models.QueuedMessage) (models.Image, error) {
+	return process.runSteps(message, "flt-")
+}
+
+// Watermark is the watermarking service, the watermark is sent as a watermark step optionally followed by a convert step.
+func (process *ProcessMessage) Watermark(message models.QueuedMessage) (models.Image, error) {
+	return process.runSteps(message, "wtm-")
+}
+
+// Thumbnails produces the renditions of the image in every width and format.
+// The largest rendition is returned as the result of the request, its placeholders are computed on the original
+// since every rendition is only a scaled copy of it.
+func (process *ProcessMessage) Thumbnails(message models.QueuedMessage) (models.Image, []models.Rendition, error) {
+	process.logger.Printf("%s:%s", "Process started", message.Service)
+	resultedName, err := process.keepFormatName("thb-" + message.UploadedName)
+	if err != nil {
+		return models.Image{}, nil, err
+	}
+
+	img, format, metadata, err := process.decodeOriginalImage(message.Image, message.Image.UploadedName, process.decodeOptions(message.Decoding)...)
 	if err != nil {
-		return models.Image{}, err
+		return models.Image{}, nil, err
+	}
+
+	renditions, err := process.ImageService.ThumbnailImage(message.Thumbnails, format, resultedName, img, encodeOptions(message.Encoding, metadata)...)
+	if err != nil {
+		return models.Image{}, nil, err
 	}
 	process.logger.Printf("%s:%s", "Process finished", message.Service)
 
-	return convertedImage, nil
+	largest := largestRendition(renditions)
+	result := models.Image{ResultedName: largest.ResultedName, ResultedLocation: largest.ResultedLocation}
+	withPlaceholders, err := service.FillInThePlaceholders(result, img)
+	if err != nil {
+		process.logger.Printf("%s:%s", "Failed to create placeholders", err)
+		return result, renditions, nil
+	}
+
+	return withPlaceholders, renditions, nil
 }
 
-func (process *ProcessMessage) prepareImage(uploadedImage models.Image, originalImageName, resultedImageName string) (image.Image, string, *os.File, error) {
-	conf := utils.NewConfig()
+// Palette finds the dominant colors of the original, no resulting image is written.
+func (process *ProcessMessage) Palette(message models.QueuedMessage) ([]models.PaletteColor, error) {
+	process.logger.Printf("%s:%s", "Process started", message.Service)
+	img, _, _, err := process.decodeOriginalImage(message.Image, message.Image.UploadedName, process.decodeOptions(message.Decoding)...)
+	if err != nil {
+		return nil, err
+	}
 
-	switch conf.Storage {
-	case aws:
-		img, format, resultedFile, err := process.downloadOriginalImageFormAWS(originalImageName, resultedImageName)
-		if err != nil {
-			return nil, "", nil, err
-		}
-		return img, format, resultedFile, nil
+	palette, err := process.ImageService.PaletteImage(message.Palette, img)
+	if err != nil {
+		return nil, err
+	}
+	process.logger.Printf("%s:%s", "Process finished", message.Service)
 
-	case local:
-		img, format, resultedFile, err := getOriginalImageLocally(uploadedImage, resultedImageName)
-		if err != nil {
-			return nil, "", nil, err
+	return palette, nil
+}
+
+// largestRendition returns the widest rendition in the first format, it is kept as the result of the request.
+func largestRendition(renditions []models.Rendition) models.Rendition {
+	var largest models.Rendition
+	for _, rendition := range renditions {
+		if rendition.Width > largest.Width {
+			largest = rendition
 		}
-		return img, format, resultedFile, nil
 	}
-
-	return nil, "", nil, nil
+	return largest
 }
 
-func (process *ProcessMessage) downloadOriginalImageFormAWS(originalImageName, resultedImageName string) (image.Image, string, *os.File, error) {
-	file, err := process.ImageService.DownloadFromS3Bucket(originalImageName)
+func (process *ProcessMessage) runSteps(message models.QueuedMessage, prefix string) (models.Image, error) {
+	resultedName, err := process.keepFormatName(prefix + message.UploadedName)
+	if format, ok := service.PipelineFormat(message.Steps); ok {
+		resultedName, err = process.ImageService.ChangeFormat(prefix+message.UploadedName, format)
+	}
 	if err != nil {
-		return nil, "", nil, err
+		return models.Image{}, err
 	}
 
-	img, format, err := image.Decode(file)
+	return process.processOriginal(message, resultedName, func(format string, img image.Image, opts ...service.EncodeOption) (models.Image, error) {
+		return process.ImageService.PipelineImage(message.Steps, format, resultedName, img, opts...)
+	})
+}
+
+// processOriginal decodes the original of the message and passes it to the operation with the encoding of the message.
+func (process *ProcessMessage) processOriginal(message models.QueuedMessage, resultedName string, op func(format string, img image.Image, opts ...service.EncodeOption) (models.Image, error)) (models.Image, error) {
+	process.logger.Printf("%s:%s", "Process started", message.Service)
+	process.logger.Printf("%s:%s", "Image renamed", resultedName)
+
+	img, format, metadata, err := process.decodeOriginalImage(message.Image, message.Image.UploadedName, process.decodeOptions(message.Decoding)...)
 	if err != nil {
-		return nil, "", nil, utils.ErrDecode
+		return models.Image{}, err
 	}
 
-	resultedFile, err := os.Create(resultedImageName)
+	result, err := op(format, img, encodeOptions(message.Encoding, metadata)...)
 	if err != nil {
-		return nil, "", nil, utils.ErrCreateFile
+		return models.Image{}, err
 	}
+	process.logger.Printf("%s:%s", "Process finished", message.Service)
 
-	return img, format, resultedFile, nil
+	return result, nil
 }
 
-func getOriginalImageLocally(uploadedImage models.Image, resultedImageName string) (image.Image, string, *os.File, error) {
-	file, err := os.Open(uploadedImage.UploadedLocation + uploadedImage.UploadedName)
-	if err != nil {
-		return nil, "", nil, utils.ErrOpen
+// keepFormatName names the result that keeps the format of the original, read-only formats are written as the fallback format.
+func (process *ProcessMessage) keepFormatName(name string) (string, error) {
+	if source, err := service.LookupFormat(path.Ext(name)); err == nil && !source.CanEncode() {
+		return process.ImageService.ChangeFormat(name, service.FallbackFormat)
 	}
+	return name, nil
+}
 
-	img, format, err := image.Decode(file)
+func (process *ProcessMessage) decodeOriginalImage(uploadedImage models.Image, originalImageName string, opts ...service.DecodeOption) (image.Image, string, service.Metadata, error) {
+	file, err := process.ImageService.Get(storage.UploadKey(originalImageName))
 	if err != nil {
-		return nil, "", nil, utils.ErrDecode
+		return nil, "", service.Metadata{}, err
 	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			process.logger.Printf("%s:%s", "failed file.Close", err)
+		}
+	}()
 
-	err = file.Close()
+	img, format, metadata, err := service.DecodeImageWithMetadata(file, opts...)
+	if errors.Is(err, utils.ErrImageTooLarge) {
+		return nil, "", service.Metadata{}, err
+	}
 	if err != nil {
-		return nil, "", nil, err
+		return nil, "", service.Metadata{}, utils.ErrDecode
 	}
 
-	err = service.EnsureBaseDir("./results/")
-	if err != nil {
-		return nil, "", nil, utils.ErrEnsureDir
+	process.saveImageHash(uploadedImage.ID, img)
+
+	return img, format, metadata, nil
+}
+
+// saveImageHash stores the perceptual hash of the original, a failure only loses the duplicate detection for this image.
+func (process *ProcessMessage) saveImageHash(imageID uuid.UUID, img image.Image) {
+	hash := service.DifferenceHash(img)
+	if err := process.ImageService.SaveImageHash(context.Background(), imageID, hash); err != nil {
+		process.logger.Printf("%s:%s", "Failed to save image hash", err)
+		return
 	}
+	process.logger.Printf("%s:%016x", "Image hash saved", hash)
+}
 
-	resultedFile, err := os.Create(fmt.Sprintf("./results/%s", resultedImageName))
-	if err != nil {
-		return nil, "", nil, utils.ErrCreateFile
+// encodeOptions keeps the default encoding for the messages that do not carry it.
+func encodeOptions(encoding models.Encoding, metadata service.Metadata) []service.EncodeOption {
+	if encoding == (models.Encoding{}) {
+		return nil
 	}
 
-	return img, format, resultedFile, nil
+	return []service.EncodeOption{
+		service.WithJPEGQuality(encoding.Quality),
+		service.WithPNGCompressionLevel(encoding.PNGLevel),
+		service.WithPNGColors(encoding.Colors, encoding.Dither),
+		service.WithMetadata(metadata.Filter(encoding.Metadata)),
+	}
 }
 
-func newImgName(str string) string {
-	return str
+// decodeOptions applies the decoding parameters of the message and the configured limits of the images.
+func (process *ProcessMessage) decodeOptions(decoding models.Decoding) []service.DecodeOption {
+	return []service.DecodeOption{
+		service.WithAutoOrient(decoding.AutoOrient),
+		service.WithLimits(process.limits),
+	}
 }
@@ -33,11 +33,12 @@ type ProcessMessage struct {
 	*RabbitMQ
 	repeater Repeater
 	logger   *Logger
+	limits   utils.LimitsConfig
 }
 
 // NewProcessMessageConsumer configures ProcessMessage for consumer.
-func NewProcessMessageConsumer(service *ImageService) *ProcessMessage {
-	return &ProcessMessage{ImageService: service, logger: NewLogger(), repeater: NewRepeater(NewBackoff(100*time.Millisecond, 10*time.Second, maxAttempt, nil), nil), RabbitMQ: NewRabbitMQ()}
+func NewProcessMessageConsumer(service *ImageService, limits utils.LimitsConfig) *ProcessMessage {
+	return &ProcessMessage{ImageService: service, logger: NewLogger(), repeater: NewRepeater(NewBackoff(100*time.Millisecond, 10*time.Second, maxAttempt, nil), nil), RabbitMQ: NewRabbitMQ(), limits: limits}
 }
 
 // NewProcessMessageAPI configures ProcessMessage for API.
@@ -2,7 +2,10 @@ package broker
 
 import (
 	"context"
+	"errors"
 	"time"
+
+	"github.com/alisavch/image-service/internal/utils"
 )
 
 // Action int type.
@@ -47,10 +50,20 @@ func (r *Repeater) sleep(ctx context.Context, t <-chan time.Time) error {
 	}
 }
 
-// DefaultRetryPolicy returns default retry policy.
+// DefaultRetryPolicy retries the failures of the storage and the database. The messages that fail the same way every
+// time are not retried: the images that exceed the limits or cannot be decoded, the operations that cannot be applied
+// with the parameters of the message, the unsupported target formats and the originals missing from the storage.
 func DefaultRetryPolicy(err error) Action {
-	if err != nil {
+	switch {
+	case err == nil:
+		return Succeed
+	case errors.Is(err, utils.ErrImageTooLarge),
+		errors.Is(err, utils.ErrDecode),
+		errors.Is(err, utils.ErrUnprocessable),
+		errors.Is(err, utils.ErrUnsupportedTargetFormat),
+		errors.Is(err, utils.ErrObjectNotFound):
+		return Fail
+	default:
 		return Retry
 	}
-	return Succeed
 }
@@ -0,0 +1,44 @@
+package broker
+
+import (
+	"fmt"
+	"image"
+	"testing"
+
+	"github.com/alisavch/image-service/internal/models"
+	"github.com/alisavch/image-service/internal/service"
+	"github.com/alisavch/image-service/internal/storage"
+	"github.com/alisavch/image-service/internal/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultRetryPolicy(t *testing.T) {
+	images := service.NewImageService(nil, storage.NewMemory(0, 0))
+	img := image.NewGray(image.Rect(0, 0, 10, 10))
+
+	_, cropErr := images.CropImage(models.Crop{X: 5, Width: 10, Height: 10}, "png", "crp-filename.png", img)
+	_, ratioErr := images.CompressImage(models.Resize{Width: 20, Mode: models.Fit}, "png", "cmp-filename.png", img)
+
+	tests := []struct {
+		name string
+		err  error
+		want Action
+	}{
+		{name: "Success", want: Succeed},
+		{name: "Image over the limits", err: &utils.LimitError{Limit: "width", Value: 20, Max: 10}, want: Fail},
+		{name: "Image cannot be decoded", err: utils.ErrDecode, want: Fail},
+		{name: "Crop rectangle out of bounds", err: cropErr, want: Fail},
+		{name: "Upscaled image", err: ratioErr, want: Fail},
+		{name: "Unsupported target format", err: utils.ErrUnsupportedTargetFormat, want: Fail},
+		{name: "Original missing from the storage", err: utils.ErrObjectNotFound, want: Fail},
+		{name: "Storage failure", err: fmt.Errorf("%s:%s", utils.ErrSaveImage, utils.ErrS3Uploading), want: Retry},
+		{name: "Database failure", err: utils.ErrCompleteRequest, want: Retry},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, DefaultRetryPolicy(tt.err))
+		})
+	}
+}
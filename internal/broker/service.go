@@ -1,15 +1,17 @@
 package broker
 
+import "github.com/alisavch/image-service/internal/storage"
+
 // ImageService unites interfaces.
 type ImageService struct {
 	Image
-	S3Bucket
+	storage.Storage
 }
 
 // NewService configures Service.
-func NewService(image Image, bucket S3Bucket) *ImageService {
+func NewService(image Image, store storage.Storage) *ImageService {
 	return &ImageService{
-		Image:    image,
-		S3Bucket: bucket,
+		Image:   image,
+		Storage: store,
 	}
 }
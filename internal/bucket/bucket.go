@@ -1,10 +1,15 @@
 package bucket
 
 import (
+	"crypto/tls"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net/http"
 	"os"
+	"path"
 
+	"github.com/alisavch/image-service/internal/models"
 	"github.com/alisavch/image-service/internal/utils"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -15,61 +20,75 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 )
 
-var (
-	conf = utils.NewConfig()
-	sess = connectAWS(NewS3Session())
-)
-
-// S3Session contains an environment for the AWS bucket.
+// S3Session keeps the files of the service in an AWS bucket.
 type S3Session struct {
-	bucketName       string
-	region           string
-	credentialKey    string
-	credentialSecret string
-	logger           *Logger
-	displayProgress  bool
+	sess            *session.Session
+	bucketName      string
+	logger          *Logger
+	displayProgress bool
 }
 
 // NewS3Session configures S3Session.
-func NewS3Session() *S3Session {
-	return &S3Session{
-		bucketName:       conf.Bucket.BucketName,
-		region:           conf.Bucket.AWSRegion,
-		credentialKey:    conf.Bucket.AWSAccessKeyID,
-		credentialSecret: conf.Bucket.AWSSecretAccessKey,
-		logger:           NewLogger(),
-		displayProgress:  true,
-		//request: models.RequestStatus{}
+func NewS3Session(conf utils.BucketConfig) (*S3Session, error) {
+	sess, err := connectAWS(conf)
+	if err != nil {
+		return nil, err
 	}
+
+	return &S3Session{
+		sess:            sess,
+		bucketName:      conf.BucketName,
+		logger:          NewLogger(),
+		displayProgress: true,
+	}, nil
 }
 
-func connectAWS(s3sess *S3Session) *session.Session {
-	sess, err := session.NewSession(
-		&aws.Config{
-			Region: aws.String(s3sess.region),
-			Credentials: credentials.NewStaticCredentials(
-				s3sess.credentialKey,
-				s3sess.credentialSecret,
-				""),
-		})
+// connectAWS creates the session for AWS or an S3-compatible endpoint.
+// Without static keys the credentials are looked up in the environment, the shared files and the instance role.
+func connectAWS(conf utils.BucketConfig) (*session.Session, error) {
+	cfg := aws.NewConfig().
+		WithRegion(conf.AWSRegion).
+		WithS3ForcePathStyle(conf.ForcePathStyle)
+
+	if conf.Endpoint != "" {
+		cfg = cfg.WithEndpoint(conf.Endpoint)
+	}
+	if conf.AWSAccessKeyID != "" || conf.AWSSecretAccessKey != "" {
+		cfg = cfg.WithCredentials(credentials.NewStaticCredentials(
+			conf.AWSAccessKeyID,
+			conf.AWSSecretAccessKey,
+			""))
+	}
+	if conf.InsecureSkipVerify {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		// The certificates of the development endpoints are self-signed.
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		cfg = cfg.WithHTTPClient(&http.Client{Transport: transport})
+	}
+
+	sess, err := session.NewSession(cfg)
 	if err != nil {
-		s3sess.logger.Fatalf("%s:%s", "Failed to create session", err)
+		return nil, fmt.Errorf("%s:%s", "failed to create session", err)
 	}
 
-	return sess
+	return sess, nil
 }
 
-// UploadToS3Bucket uploads an object to S3.
-func (s3sess *S3Session) UploadToS3Bucket(file io.Reader, filename string) (string, error) {
-	uploader := s3manager.NewUploader(sess, func(d *s3manager.Uploader) {
-		d.PartSize = 64 * 1024 * 1024 // 64MB per part
-		d.Concurrency = 6
+// Put uploads an object to S3.
+//
+// The uploads are streamed, so the uploader copies every part of the file into a buffer of PartSize before sending it,
+// and holds up to Concurrency+1 of them. The smallest part S3 accepts keeps the memory of an upload at about 15MB
+// whatever the size of the file, a file that fits in one part is sent with a single PutObject.
+func (s3sess *S3Session) Put(key string, file io.Reader) (string, error) {
+	uploader := s3manager.NewUploader(s3sess.sess, func(d *s3manager.Uploader) {
+		d.PartSize = s3manager.MinUploadPartSize
+		d.Concurrency = 2
 	})
 
 	result, err := uploader.Upload(&s3manager.UploadInput{
 		Body:   file,
 		Bucket: aws.String(s3sess.bucketName),
-		Key:    aws.String(filename),
+		Key:    aws.String(key),
 	})
 	if err != nil {
 		return "", fmt.Errorf("%s:%s", utils.ErrS3Uploading, err)
@@ -79,54 +98,117 @@ func (s3sess *S3Session) UploadToS3Bucket(file io.Reader, filename string) (stri
 	return result.Location, nil
 }
 
-// DownloadFromS3Bucket downloads objects from S3.
-func (s3sess *S3Session) DownloadFromS3Bucket(filename string) (*os.File, error) {
-	file, err := os.Create(filename)
+// Get downloads an object from S3 into a temporary file, the file is removed when it is closed.
+func (s3sess *S3Session) Get(key string) (io.ReadCloser, error) {
+	object, err := s3sess.Stat(key)
 	if err != nil {
-		return nil, fmt.Errorf("%s:%s", utils.ErrCreateFile, err)
+		return nil, err
 	}
 
-	s3ObjectSize := s3sess.GetS3ObjectSize(filename)
+	file, err := ioutil.TempFile("", "s3-*-"+path.Base(key))
+	if err != nil {
+		return nil, fmt.Errorf("%s:%s", utils.ErrCreateFile, err)
+	}
 
-	downloader := s3manager.NewDownloader(sess, func(d *s3manager.Downloader) {
+	downloader := s3manager.NewDownloader(s3sess.sess, func(d *s3manager.Downloader) {
 		d.PartSize = 64 * 1024 * 1024 // 64MB per part
-		d.Concurrency = 6
+		d.Concurrency = 2
 	})
 
-	pw := &progressWriter{writer: file, size: s3ObjectSize}
+	pw := &progressWriter{writer: file, size: object.Size}
 	pw.display = s3sess.displayProgress
-	pw.init(s3ObjectSize)
+	pw.init(object.Size)
 
 	numBytes, err := downloader.Download(pw, &s3.GetObjectInput{
 		Bucket: aws.String(s3sess.bucketName),
-		Key:    aws.String(filename),
+		Key:    aws.String(key),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("%s:%s", "failed to get object", err)
+		_ = (&tempFile{file}).Close()
+		return nil, fmt.Errorf("%s:%s", utils.ErrRemoteDownload, err)
 	}
 
 	pw.finish()
 
-	s3sess.logger.Printf("%s:%s", "Download status", pw.bar.String())
-	s3sess.logger.Printf("%s:%s, %d %s", "Successfully downloaded", file.Name(), numBytes, "bytes")
-	return file, nil
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		_ = (&tempFile{file}).Close()
+		return nil, err
+	}
+
+	if pw.display {
+		s3sess.logger.Printf("%s:%s", "Download status", pw.bar.String())
+	}
+	s3sess.logger.Printf("%s:%s, %d %s", "Successfully downloaded", key, numBytes, "bytes")
+	return &tempFile{file}, nil
 }
 
-// GetS3ObjectSize get the size of the file.
-func (s3sess *S3Session) GetS3ObjectSize(item string) int64 {
-	svc := s3.New(sess)
-	input := &s3.HeadObjectInput{
+// Stat gets the size and the modification time of an object.
+func (s3sess *S3Session) Stat(key string) (models.Object, error) {
+	svc := s3.New(s3sess.sess)
+	result, err := svc.HeadObject(&s3.HeadObjectInput{
 		Bucket: aws.String(s3sess.bucketName),
-		Key:    aws.String(item),
+		Key:    aws.String(key),
+	})
+	if aerr, ok := err.(awserr.Error); ok && (aerr.Code() == "NotFound" || aerr.Code() == s3.ErrCodeNoSuchKey) {
+		return models.Object{}, utils.ErrObjectNotFound
+	}
+	if err != nil {
+		return models.Object{}, fmt.Errorf("%s:%s", utils.ErrS3Stat, err)
 	}
 
-	result, err := svc.HeadObject(input)
+	return models.Object{Key: key, Size: aws.Int64Value(result.ContentLength), ModTime: aws.TimeValue(result.LastModified)}, nil
+}
+
+// Delete deletes an object from S3.
+func (s3sess *S3Session) Delete(key string) error {
+	svc := s3.New(s3sess.sess)
+	_, err := svc.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s3sess.bucketName),
+		Key:    aws.String(key),
+	})
 	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			s3sess.logger.Fatalf("%s:%s", "Error getting size of file", aerr)
-			return 0
+		return fmt.Errorf("%s:%s", utils.ErrS3Deleting, err)
+	}
+
+	s3sess.logger.Printf("%s:%s", "Successfully deleted", key)
+	return nil
+}
+
+// List lists the objects whose keys start with the prefix, S3 returns them sorted by key.
+func (s3sess *S3Session) List(prefix string) ([]models.Object, error) {
+	svc := s3.New(s3sess.sess)
+
+	var objects []models.Object
+	err := svc.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(s3sess.bucketName),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, item := range page.Contents {
+			objects = append(objects, models.Object{
+				Key:     aws.StringValue(item.Key),
+				Size:    aws.Int64Value(item.Size),
+				ModTime: aws.TimeValue(item.LastModified),
+			})
 		}
-		s3sess.logger.Fatalf("%s:%s", "Error getting size of file", err)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s:%s", utils.ErrS3Listing, err)
+	}
+
+	return objects, nil
+}
+
+// tempFile is a downloaded file that is removed when it is closed.
+type tempFile struct {
+	*os.File
+}
+
+// Close closes and removes the file.
+func (f *tempFile) Close() error {
+	err := f.File.Close()
+	if removeErr := os.Remove(f.Name()); err == nil {
+		err = removeErr
 	}
-	return *result.ContentLength
+	return err
 }
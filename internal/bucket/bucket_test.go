@@ -0,0 +1,257 @@
+package bucket
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alisavch/image-service/internal/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testBucket = "test-bucket"
+
+// fakeS3 serves the part of the S3 API the session uses, like MinIO it only understands path-style requests.
+// The parts of a multipart upload are joined when it completes, with discard set only their size is counted.
+type fakeS3 struct {
+	mu       sync.Mutex
+	objects  map[string][]byte
+	parts    map[int][]byte
+	modTime  time.Time
+	discard  bool
+	received int64
+}
+
+type listBucketResult struct {
+	XMLName     xml.Name       `xml:"ListBucketResult"`
+	Name        string         `xml:"Name"`
+	Prefix      string         `xml:"Prefix"`
+	KeyCount    int            `xml:"KeyCount"`
+	IsTruncated bool           `xml:"IsTruncated"`
+	Contents    []listedObject `xml:"Contents"`
+}
+
+type listedObject struct {
+	Key          string `xml:"Key"`
+	Size         int    `xml:"Size"`
+	LastModified string `xml:"LastModified"`
+}
+
+type initiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+type completeMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if parts[0] != testBucket {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+	key := ""
+	if len(parts) == 2 {
+		key = parts[1]
+	}
+
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && query.Get("uploadId") == "":
+		f.parts = map[int][]byte{}
+		w.Header().Set("Content-Type", "application/xml")
+		_ = xml.NewEncoder(w).Encode(initiateMultipartUploadResult{Bucket: testBucket, Key: key, UploadID: "upload-id"})
+
+	case r.Method == http.MethodPost:
+		var object []byte
+		for i := 1; i <= len(f.parts); i++ {
+			object = append(object, f.parts[i]...)
+		}
+		f.objects[key] = object
+		w.Header().Set("Content-Type", "application/xml")
+		_ = xml.NewEncoder(w).Encode(completeMultipartUploadResult{Location: "https://" + r.Host + r.URL.Path, Bucket: testBucket, Key: key, ETag: `"etag"`})
+
+	case r.Method == http.MethodPut:
+		if f.discard {
+			n, err := io.Copy(ioutil.Discard, r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			f.received += n
+			w.Header().Set("ETag", `"etag"`)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if number := query.Get("partNumber"); number != "" {
+			n, _ := strconv.Atoi(number)
+			f.parts[n] = body
+		} else {
+			f.objects[key] = body
+		}
+		w.Header().Set("ETag", `"etag"`)
+
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	case key == "":
+		result := listBucketResult{Name: testBucket, Prefix: r.URL.Query().Get("prefix")}
+		for k, data := range f.objects {
+			if strings.HasPrefix(k, result.Prefix) {
+				result.Contents = append(result.Contents, listedObject{Key: k, Size: len(data), LastModified: f.modTime.Format(time.RFC3339)})
+			}
+		}
+		sort.Slice(result.Contents, func(i, j int) bool { return result.Contents[i].Key < result.Contents[j].Key })
+		result.KeyCount = len(result.Contents)
+		w.Header().Set("Content-Type", "application/xml")
+		_ = xml.NewEncoder(w).Encode(result)
+
+	default:
+		data, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		http.ServeContent(w, r, key, f.modTime, bytes.NewReader(data))
+	}
+}
+
+func newTestSession(t *testing.T, insecureSkipVerify bool) *S3Session {
+	return newFakeSession(t, &fakeS3{objects: map[string][]byte{}, modTime: time.Date(2021, 9, 1, 0, 0, 0, 0, time.UTC)}, insecureSkipVerify)
+}
+
+func newFakeSession(t *testing.T, fake *fakeS3, insecureSkipVerify bool) *S3Session {
+	server := httptest.NewTLSServer(fake)
+	t.Cleanup(server.Close)
+
+	s3sess, err := NewS3Session(utils.BucketConfig{
+		AWSRegion:          "us-east-1",
+		AWSAccessKeyID:     "minioadmin",
+		AWSSecretAccessKey: "minioadmin",
+		BucketName:         testBucket,
+		Endpoint:           server.URL,
+		ForcePathStyle:     true,
+		InsecureSkipVerify: insecureSkipVerify,
+	})
+	require.NoError(t, err)
+	s3sess.displayProgress = false
+
+	return s3sess
+}
+
+func TestS3Session_CompatibleEndpoint(t *testing.T) {
+	s3sess := newTestSession(t, true)
+	content := []byte("image content")
+
+	location, err := s3sess.Put("uploads/filename.jpeg", bytes.NewReader(content))
+	require.NoError(t, err)
+	require.True(t, strings.HasSuffix(location, "/"+testBucket+"/uploads/filename.jpeg"), location)
+
+	_, err = s3sess.Put("results/cmp-filename.jpeg", bytes.NewReader(content[:5]))
+	require.NoError(t, err)
+
+	object, err := s3sess.Stat("uploads/filename.jpeg")
+	require.NoError(t, err)
+	require.Equal(t, int64(len(content)), object.Size)
+	require.Equal(t, time.Date(2021, 9, 1, 0, 0, 0, 0, time.UTC), object.ModTime.UTC())
+
+	file, err := s3sess.Get("uploads/filename.jpeg")
+	require.NoError(t, err)
+	got, err := ioutil.ReadAll(file)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+	require.Equal(t, content, got)
+
+	objects, err := s3sess.List("uploads/")
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	require.Equal(t, "uploads/filename.jpeg", objects[0].Key)
+	require.Equal(t, int64(len(content)), objects[0].Size)
+
+	require.NoError(t, s3sess.Delete("uploads/filename.jpeg"))
+	_, err = s3sess.Stat("uploads/filename.jpeg")
+	require.Equal(t, utils.ErrObjectNotFound, err)
+	_, err = s3sess.Get("uploads/filename.jpeg")
+	require.Equal(t, utils.ErrObjectNotFound, err)
+}
+
+func TestS3Session_VerifiesCertificates(t *testing.T) {
+	s3sess := newTestSession(t, false)
+
+	_, err := s3sess.Put("uploads/filename.jpeg", bytes.NewReader([]byte("image content")))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "certificate")
+}
+
+func TestS3Session_MultipartUpload(t *testing.T) {
+	s3sess := newTestSession(t, true)
+	content := bytes.Repeat([]byte("image content"), 1<<20)
+
+	// The body is not seekable, like the uploads streamed from the request.
+	_, err := s3sess.Put("uploads/filename.jpeg", io.MultiReader(bytes.NewReader(content)))
+	require.NoError(t, err)
+
+	file, err := s3sess.Get("uploads/filename.jpeg")
+	require.NoError(t, err)
+	got, err := ioutil.ReadAll(file)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+	require.Equal(t, content, got)
+}
+
+// zeroReader streams zeros, it is not seekable.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func TestS3Session_StreamedUploadMemory(t *testing.T) {
+	fake := &fakeS3{objects: map[string][]byte{}, discard: true}
+	s3sess := newFakeSession(t, fake, true)
+	const size = 64 << 20
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	_, err := s3sess.Put("uploads/filename.jpeg", io.LimitReader(zeroReader{}, size))
+	require.NoError(t, err)
+
+	runtime.ReadMemStats(&after)
+	require.Equal(t, int64(size), fake.received)
+
+	// The part buffers are allocated once and reused, the allocations do not grow with the size of the file.
+	allocated := after.TotalAlloc - before.TotalAlloc
+	require.Less(t, allocated, uint64(size/2))
+}
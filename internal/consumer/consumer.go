@@ -3,8 +3,8 @@ package consumer
 import (
 	"database/sql"
 
-	"github.com/alisavch/image-service/internal/bucket"
 	"github.com/alisavch/image-service/internal/service"
+	"github.com/alisavch/image-service/internal/storage"
 
 	"github.com/alisavch/image-service/internal/broker"
 	"github.com/alisavch/image-service/internal/repository"
@@ -38,10 +38,13 @@ func Consume() {
 			logger.Fatalf("%s: %s", "Failed to close database", err)
 		}
 	}(db)
+	store, err := storage.New(conf)
+	if err != nil {
+		logger.Fatalf("%s: %s", "Failed to initialize storage", err)
+	}
 	repos := repository.NewRepository(db)
-	aws := bucket.NewAWS()
-	services := service.NewService(repos, aws)
-	rabbit := broker.NewAMQPBrokerConsumer(services, aws)
+	services := service.NewService(repos, store)
+	rabbit := broker.NewAMQPBrokerConsumer(services, store, conf.Limits)
 
 	currentService := NewConversionService(rabbit)
 
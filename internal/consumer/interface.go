@@ -3,10 +3,9 @@ package consumer
 import (
 	"context"
 	"image"
-	"io"
-	"os"
 
 	"github.com/alisavch/image-service/internal/models"
+	"github.com/alisavch/image-service/internal/service"
 	"github.com/streadway/amqp"
 )
 
@@ -24,16 +23,14 @@ type DisplayLog interface {
 	Fatalf(format string, args ...interface{})
 }
 
-// S3Bucket contains the basic functions for interacting with the bucket.
-type S3Bucket interface {
-	UploadToS3Bucket(file io.Reader, filename string) (string, error)
-	DownloadFromS3Bucket(filename string) (*os.File, error)
-}
-
 // Image contains methods for working with images.
 type Image interface {
-	CompressImage(width int, format, resultedName string, img image.Image, newImg *os.File, storage string) (models.Image, error)
+	CompressImage(resize models.Resize, format, resultedName string, img image.Image, opts ...service.EncodeOption) (models.Image, error)
+	CropImage(crop models.Crop, format, resultedName string, img image.Image, opts ...service.EncodeOption) (models.Image, error)
+	TransformImage(transform models.Transform, format, resultedName string, img image.Image, opts ...service.EncodeOption) (models.Image, error)
+	PipelineImage(steps []models.Step, format, resultedName string, img image.Image, opts ...service.EncodeOption) (models.Image, error)
 	UploadResultedImage(ctx context.Context, img models.Image) error
-	ChangeFormat(filename string) (string, error)
-	ConvertToType(format, resultedName string, img image.Image, newImg *os.File, storage string) (models.Image, error)
+	ChangeFormat(filename, format string) (string, error)
+	ConvertToType(format, resultedName string, img image.Image, opts ...service.EncodeOption) (models.Image, error)
+	ThumbnailImage(thumbnails models.Thumbnails, format, resultedName string, img image.Image, opts ...service.EncodeOption) ([]models.Rendition, error)
 }
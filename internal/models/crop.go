@@ -0,0 +1,43 @@
+package models
+
+// Gravity is the part of the image that is kept when it is cropped to an aspect ratio.
+type Gravity string
+
+const (
+	// Center keeps the middle of the image.
+	Center Gravity = "center"
+	// North keeps the top edge.
+	North Gravity = "north"
+	// South keeps the bottom edge.
+	South Gravity = "south"
+	// East keeps the right edge.
+	East Gravity = "east"
+	// West keeps the left edge.
+	West Gravity = "west"
+	// NorthEast keeps the top right corner.
+	NorthEast Gravity = "north-east"
+	// NorthWest keeps the top left corner.
+	NorthWest Gravity = "north-west"
+	// SouthEast keeps the bottom right corner.
+	SouthEast Gravity = "south-east"
+	// SouthWest keeps the bottom left corner.
+	SouthWest Gravity = "south-west"
+	// Smart keeps the most detailed part of the image, it is found by the content of the image.
+	Smart Gravity = "smart"
+)
+
+// Crop contains the parameters of cropping, either an explicit rectangle or an aspect ratio with gravity.
+type Crop struct {
+	X            int
+	Y            int
+	Width        int
+	Height       int
+	AspectWidth  int
+	AspectHeight int
+	Gravity      Gravity
+}
+
+// IsRect reports whether the crop is an explicit rectangle.
+func (c Crop) IsRect() bool {
+	return c.Width > 0 && c.Height > 0
+}
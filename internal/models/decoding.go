@@ -0,0 +1,6 @@
+package models
+
+// Decoding contains the parameters applied when the uploaded image is read.
+type Decoding struct {
+	AutoOrient bool
+}
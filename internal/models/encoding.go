@@ -0,0 +1,24 @@
+package models
+
+// MetadataPolicy defines what happens to the metadata of the uploaded image.
+type MetadataPolicy string
+
+const (
+	// Strip removes all metadata from the resulting image.
+	Strip MetadataPolicy = "strip"
+	// Keep copies EXIF, ICC and XMP metadata into the resulting image.
+	Keep MetadataPolicy = "keep"
+	// KeepSafe keeps the color profile, EXIF and XMP but removes the location, the serial numbers and the owner.
+	KeepSafe MetadataPolicy = "keep-safe"
+)
+
+// Encoding contains the parameters of the output encoders.
+type Encoding struct {
+	Format   string
+	Quality  int
+	PNGLevel int
+	// Colors quantizes the PNG images to a palette, zero keeps the truecolor.
+	Colors   int
+	Dither   bool
+	Metadata MetadataPolicy
+}
@@ -0,0 +1,30 @@
+package models
+
+// FilterName is the name of the adjustment.
+type FilterName string
+
+const (
+	// GrayscaleFilter removes the colors.
+	GrayscaleFilter FilterName = "grayscale"
+	// SepiaFilter tones the image brown.
+	SepiaFilter FilterName = "sepia"
+	// BlurFilter applies the Gaussian blur with the radius.
+	BlurFilter FilterName = "blur"
+	// SharpenFilter applies the unsharp mask with the amount and the radius.
+	SharpenFilter FilterName = "sharpen"
+	// BrightnessFilter changes the brightness by the value from -100 to 100 percent.
+	BrightnessFilter FilterName = "brightness"
+	// ContrastFilter changes the contrast by the value from -100 to 100 percent.
+	ContrastFilter FilterName = "contrast"
+	// GammaFilter applies the gamma correction with the value above 0, 1 keeps the image as it is.
+	GammaFilter FilterName = "gamma"
+	// SaturationFilter changes the saturation by the value from -100 to 100 percent.
+	SaturationFilter FilterName = "saturation"
+)
+
+// Adjustment is a single filter applied to the image.
+type Adjustment struct {
+	Filter FilterName `json:"filter"`
+	Value  float64    `json:"value,omitempty"`
+	Radius float64    `json:"radius,omitempty"`
+}
@@ -1,13 +1,21 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 // History is general information about requests.
 type History struct {
+	ImageID       uuid.UUID `json:"image_id"`
 	UploadedName  string    `json:"uploaded_name"`
 	ResultedName  string    `json:"resulted_name"`
 	ServiceName   Service   `json:"service_name"`
 	TimeStarted   time.Time `json:"time_started"`
 	TimeCompleted time.Time `json:"time_completed"`
 	Status        Status    `json:"status"`
+	Pipeline      string    `json:"pipeline,omitempty"`
+	BlurHash      string    `json:"blurhash,omitempty"`
+	Placeholder   string    `json:"placeholder,omitempty"`
 }
@@ -32,4 +32,34 @@ type Image struct {
 	//
 	// required: false
 	ResultedLocation string `json:"resulted_location,omitempty"`
+
+	// the size of the uploaded image in bytes
+	//
+	// required: false
+	UploadedSize int64 `json:"uploaded_size,omitempty"`
+
+	// the format of the uploaded image detected from its content
+	//
+	// required: false
+	UploadedFormat string `json:"uploaded_format,omitempty"`
+
+	// the hex encoded SHA-256 of the uploaded image
+	//
+	// required: false
+	UploadedSHA256 string `json:"uploaded_sha256,omitempty"`
+
+	// the size of the resulted image in bytes
+	//
+	// required: false
+	ResultedSize int64 `json:"resulted_size,omitempty"`
+
+	// the BlurHash of the resulted image
+	//
+	// required: false
+	BlurHash string `json:"blurhash,omitempty"`
+
+	// the resulted image scaled down to a few pixels as a data URI
+	//
+	// required: false
+	Placeholder string `json:"placeholder,omitempty"`
 }
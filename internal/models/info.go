@@ -0,0 +1,33 @@
+package models
+
+import "github.com/google/uuid"
+
+// ImageInfo describes the file of an image, it is read from the header without decoding the pixels.
+type ImageInfo struct {
+	Format     string `json:"format"`
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	ColorModel string `json:"color_model"`
+	// BitDepth is the number of bits per channel, or per index of the paletted images.
+	BitDepth int       `json:"bit_depth"`
+	HasAlpha bool      `json:"has_alpha"`
+	Size     int64     `json:"size"`
+	SHA256   string    `json:"sha256"`
+	Exif     *ExifInfo `json:"exif,omitempty"`
+}
+
+// ExifInfo contains the EXIF fields clients usually decide on.
+type ExifInfo struct {
+	Make        string `json:"make,omitempty"`
+	Model       string `json:"model,omitempty"`
+	DateTaken   string `json:"date_taken,omitempty"`
+	Orientation int    `json:"orientation"`
+	HasGPS      bool   `json:"has_gps"`
+}
+
+// ImageInspection describes the original image and the result of its request once it is done.
+type ImageInspection struct {
+	ImageID  uuid.UUID  `json:"image_id"`
+	Original ImageInfo  `json:"original"`
+	Result   *ImageInfo `json:"result,omitempty"`
+}
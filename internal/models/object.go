@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// Object describes a file kept in the storage.
+type Object struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
@@ -0,0 +1,12 @@
+package models
+
+// Palette contains the parameters of the palette extraction.
+type Palette struct {
+	Colors int
+}
+
+// PaletteColor is one of the dominant colors of the image.
+type PaletteColor struct {
+	Color    string  `json:"color"`
+	Coverage float64 `json:"coverage"`
+}
@@ -6,11 +6,42 @@ import "github.com/google/uuid"
 type QueuedMessage struct {
 	Service
 	Image
-	Width     int
+	Resize
+	Crop       Crop
+	Transform  Transform
+	Steps      []Step
+	Thumbnails Thumbnails
+	Palette    Palette
+	Decoding
+	Encoding
 	RequestID uuid.UUID
 }
 
+// MessageOptions contains the parameters of the service the message is sent for, only the ones of that service are set.
+type MessageOptions struct {
+	Resize     Resize
+	Crop       Crop
+	Transform  Transform
+	Steps      []Step
+	Thumbnails Thumbnails
+	Palette    Palette
+	Decoding   Decoding
+	Encoding   Encoding
+}
+
 // NewQueuedMessage configures QueuedMessage.
-func NewQueuedMessage(width int, requestID uuid.UUID, service Service, image Image) QueuedMessage {
-	return QueuedMessage{Service: service, Image: image, Width: width, RequestID: requestID}
+func NewQueuedMessage(requestID uuid.UUID, service Service, image Image, opts MessageOptions) QueuedMessage {
+	return QueuedMessage{
+		Service:    service,
+		Image:      image,
+		Resize:     opts.Resize,
+		Crop:       opts.Crop,
+		Transform:  opts.Transform,
+		Steps:      opts.Steps,
+		Thumbnails: opts.Thumbnails,
+		Palette:    opts.Palette,
+		Decoding:   opts.Decoding,
+		Encoding:   opts.Encoding,
+		RequestID:  requestID,
+	}
 }
@@ -0,0 +1,19 @@
+package models
+
+import "github.com/google/uuid"
+
+// Thumbnails contains the widths and the formats of the renditions produced from a single upload.
+type Thumbnails struct {
+	Widths  []int
+	Formats []string
+}
+
+// Rendition is one size of the image in one format, it is stored as a separate result of the request.
+type Rendition struct {
+	ID               uuid.UUID `json:"id"`
+	Width            int       `json:"width"`
+	Height           int       `json:"height"`
+	Format           string    `json:"format"`
+	ResultedName     string    `json:"resulted_name"`
+	ResultedLocation string    `json:"-"`
+}
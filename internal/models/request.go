@@ -18,6 +18,20 @@ const (
 	Conversion Service = "conversion"
 	// Compression is a command with an image.
 	Compression Service = "compression"
+	// Cropping is a command with an image.
+	Cropping Service = "cropping"
+	// Transformation is a command with an image.
+	Transformation Service = "transformation"
+	// Filtering is a command with an image.
+	Filtering Service = "filtering"
+	// Watermarking is a command with an image.
+	Watermarking Service = "watermarking"
+	// Thumbnailing is a command that produces several renditions of an image.
+	Thumbnailing Service = "thumbnailing"
+	// PaletteAnalysis is a command that finds the dominant colors of an image.
+	PaletteAnalysis Service = "palette"
+	// Pipeline is a chain of commands with an image.
+	Pipeline Service = "pipeline"
 	// Queued is the status of the request.
 	Queued Status = "queued"
 	// Processing is the status of the request.
@@ -37,4 +51,5 @@ type Request struct {
 	Status        Status    `json:"status,omitempty"`
 	TimeStarted   time.Time `json:"time_started,omitempty"`
 	TimeCompleted time.Time `json:"time_completed,omitempty"`
+	Pipeline      string    `json:"pipeline,omitempty"`
 }
@@ -6,4 +6,13 @@ import "github.com/google/uuid"
 type RequestStatus struct {
 	RequestID uuid.UUID `json:"request_id"`
 	Status    Status    `json:"status"`
+	// BlurHash and Placeholder are set once the request is done.
+	BlurHash    string `json:"blurhash,omitempty"`
+	Placeholder string `json:"placeholder,omitempty"`
+	// UploadedSize, ResultedSize and SizeSaved are the sizes in bytes, they are set once the request is done.
+	UploadedSize int64 `json:"uploaded_size,omitempty"`
+	ResultedSize int64 `json:"resulted_size,omitempty"`
+	SizeSaved    int64 `json:"size_saved,omitempty"`
+	// Palette is set once a palette request is done.
+	Palette []PaletteColor `json:"palette,omitempty"`
 }
@@ -0,0 +1,27 @@
+package models
+
+// ResizeMode is the way the image is placed into the requested box.
+type ResizeMode string
+
+const (
+	// Fit scales the image down to fit within the box while maintaining the aspect ratio.
+	// It never enlarges the image, a box larger than the image is rejected.
+	Fit ResizeMode = "fit"
+	// Fill scales the image to cover the box and crops what does not fit according to the gravity.
+	// The result always has the size of the box, so a smaller image is enlarged.
+	Fill ResizeMode = "fill"
+	// Pad scales the image to fit within the box and fills the rest of it with the background color.
+	// The result always has the size of the box, so a smaller image is enlarged.
+	Pad ResizeMode = "pad"
+	// Exact stretches the image to the box ignoring the aspect ratio, a smaller image is enlarged.
+	Exact ResizeMode = "exact"
+)
+
+// Resize contains the box the image is resized to.
+type Resize struct {
+	Width      int
+	Height     int
+	Mode       ResizeMode
+	Background string
+	Gravity    Gravity
+}
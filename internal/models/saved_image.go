@@ -1,10 +1,10 @@
 package models
 
-import "os"
+import "io"
 
 // SavedImage common information about image.
 type SavedImage struct {
-	File        *os.File
+	File        io.ReadCloser
 	Filename    string
 	ContentType string
 	Filesize    int64
@@ -0,0 +1,11 @@
+package models
+
+import "github.com/google/uuid"
+
+// SimilarImage is an image of the user that looks like the requested one.
+type SimilarImage struct {
+	ID           uuid.UUID `json:"image_id"`
+	RequestID    uuid.UUID `json:"request_id"`
+	UploadedName string    `json:"uploaded_name"`
+	Distance     int       `json:"distance"`
+}
@@ -0,0 +1,32 @@
+package models
+
+// Operation is the name of a pipeline step.
+type Operation string
+
+const (
+	// ResizeStep resizes the image like the compression does.
+	ResizeStep Operation = "resize"
+	// CropStep crops the image.
+	CropStep Operation = "crop"
+	// TransformStep rotates and flips the image.
+	TransformStep Operation = "transform"
+	// GrayscaleStep removes the colors of the image.
+	GrayscaleStep Operation = "grayscale"
+	// FilterStep applies the list of adjustments.
+	FilterStep Operation = "filter"
+	// WatermarkStep overlays the logo or the text on the image.
+	WatermarkStep Operation = "watermark"
+	// ConvertStep sets the format of the result, it can only be the last step.
+	ConvertStep Operation = "convert"
+)
+
+// Step is a single operation of the pipeline, only the parameters of its operation are set.
+type Step struct {
+	Operation Operation
+	Resize    Resize
+	Crop      Crop
+	Transform Transform
+	Filters   []Adjustment
+	Watermark Watermark
+	Encoding  Encoding
+}
@@ -0,0 +1,27 @@
+package models
+
+// Flip is the direction the image is mirrored in.
+type Flip string
+
+const (
+	// Horizontal mirrors the image from left to right.
+	Horizontal Flip = "horizontal"
+	// Vertical mirrors the image from top to bottom.
+	Vertical Flip = "vertical"
+	// Both mirrors the image in both directions.
+	Both Flip = "both"
+)
+
+// Transform contains the parameters of the rotation and flipping.
+type Transform struct {
+	// Angle is the clockwise rotation in degrees.
+	Angle float64
+	Flip  Flip
+	// Background fills the corners uncovered by a rotation that is not a multiple of 90 degrees.
+	Background string
+}
+
+// IsEmpty reports whether the transformation leaves the image as it is.
+func (t Transform) IsEmpty() bool {
+	return t.Angle == 0 && t.Flip == ""
+}
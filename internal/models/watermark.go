@@ -0,0 +1,35 @@
+package models
+
+// Position is the place of the watermark on the image.
+type Position string
+
+const (
+	// TopLeft places the watermark in the top left corner.
+	TopLeft Position = "top-left"
+	// TopRight places the watermark in the top right corner.
+	TopRight Position = "top-right"
+	// BottomLeft places the watermark in the bottom left corner.
+	BottomLeft Position = "bottom-left"
+	// BottomRight places the watermark in the bottom right corner.
+	BottomRight Position = "bottom-right"
+	// Centered places the watermark in the middle of the image.
+	Centered Position = "center"
+	// Tiled repeats the watermark over the whole image.
+	Tiled Position = "tiled"
+)
+
+// Watermark is the PNG logo or the text overlaid on the image.
+type Watermark struct {
+	Logo     []byte   `json:"logo,omitempty"`
+	Text     string   `json:"text,omitempty"`
+	Color    string   `json:"color,omitempty"`
+	Position Position `json:"position"`
+	Opacity  float64  `json:"opacity"`
+	Margin   int      `json:"margin"`
+	Scale    float64  `json:"scale"`
+}
+
+// HasSource checks that the watermark has a logo or a text.
+func (w Watermark) HasSource() bool {
+	return len(w.Logo) > 0 || w.Text != ""
+}
@@ -22,9 +22,29 @@ func NewImageRepository(db *sql.DB) *ImageRepository {
 	return &ImageRepository{db: db}
 }
 
+// inTx runs fn in a transaction, it is rolled back and failed with failErr when any statement fails.
+func (i *ImageRepository) inTx(ctx context.Context, failErr error, fn func(tx *sql.Tx) error) error {
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s:%s", failErr, err)
+	}
+
+	if err := fn(tx); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return fmt.Errorf("%s:%s:%s", failErr, err, rollbackErr)
+		}
+		return fmt.Errorf("%s:%s", failErr, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s:%s", failErr, err)
+	}
+	return nil
+}
+
 // FindUserRequestHistory allows to get the history of interaction with the user's service.
 func (i *ImageRepository) FindUserRequestHistory(ctx context.Context, id uuid.UUID) ([]models.History, error) {
-	query := "SELECT i.uploaded_name, COALESCE(NULLIF(i.resulted_name, ''), 'no such photo') as resulted_name, r.service_name, r.time_started, COALESCE(NULLIF(r.time_completed, CAST('2011-01-01 00:00:00' AS TIMESTAMP)), '2000-01-01 00:00:00') as time_completed, r.status from image_service.request r INNER JOIN image_service.image i on r.image_id = i.id INNER JOIN image_service.user_account ua on ua.id = r.user_account_id where ua.id = $1"
+	query := "SELECT i.id, i.uploaded_name, COALESCE(NULLIF(i.resulted_name, ''), 'no such photo') as resulted_name, r.service_name, r.time_started, COALESCE(NULLIF(r.time_completed, CAST('2011-01-01 00:00:00' AS TIMESTAMP)), '2000-01-01 00:00:00') as time_completed, r.status, COALESCE(r.pipeline, '') as pipeline, COALESCE(i.blurhash, '') as blurhash, COALESCE(i.placeholder, '') as placeholder from image_service.request r INNER JOIN image_service.image i on r.image_id = i.id INNER JOIN image_service.user_account ua on ua.id = r.user_account_id where ua.id = $1"
 	rows, err := i.db.QueryContext(ctx, query, id)
 	if err != nil {
 		return []models.History{}, utils.ErrCreateQuery
@@ -41,7 +61,7 @@ func (i *ImageRepository) FindUserRequestHistory(ctx context.Context, id uuid.UU
 
 	for rows.Next() {
 		var hist models.History
-		if err := rows.Scan(&hist.UploadedName, &hist.ResultedName, &hist.ServiceName, &hist.TimeStarted, &hist.TimeCompleted, &hist.Status); err != nil {
+		if err := rows.Scan(&hist.ImageID, &hist.UploadedName, &hist.ResultedName, &hist.ServiceName, &hist.TimeStarted, &hist.TimeCompleted, &hist.Status, &hist.Pipeline, &hist.BlurHash, &hist.Placeholder); err != nil {
 			return history, nil
 		}
 		history = append(history, hist)
@@ -56,8 +76,8 @@ func (i *ImageRepository) FindUserRequestHistory(ctx context.Context, id uuid.UU
 // UploadImage allows to upload an image.
 func (i *ImageRepository) UploadImage(ctx context.Context, img models.Image) (uuid.UUID, error) {
 	var id uuid.UUID
-	query := "INSERT INTO image_service.image(uploaded_name, uploaded_location) VALUES($1, $2) RETURNING id"
-	row := i.db.QueryRowContext(ctx, query, img.UploadedName, img.UploadedLocation)
+	query := "INSERT INTO image_service.image(uploaded_name, uploaded_location, uploaded_size, uploaded_format, uploaded_sha256) VALUES($1, $2, NULLIF($3, 0), NULLIF($4, ''), NULLIF($5, '')) RETURNING id"
+	row := i.db.QueryRowContext(ctx, query, img.UploadedName, img.UploadedLocation, img.UploadedSize, img.UploadedFormat, img.UploadedSHA256)
 	if err := row.Scan(&id); err != nil {
 		return [16]byte{}, utils.ErrUploadImageToDB
 	}
@@ -67,8 +87,8 @@ func (i *ImageRepository) UploadImage(ctx context.Context, img models.Image) (uu
 
 // UploadResultedImage allows to upload a resulted image
 func (i *ImageRepository) UploadResultedImage(ctx context.Context, img models.Image) error {
-	query := "UPDATE image_service.image SET resulted_name = $1, resulted_location = $2 WHERE id = $3"
-	result, err := i.db.ExecContext(ctx, query, img.ResultedName, img.ResultedLocation, img.ID)
+	query := "UPDATE image_service.image SET resulted_name = $1, resulted_location = $2, blurhash = NULLIF($3, ''), placeholder = NULLIF($4, ''), resulted_size = NULLIF($5, 0) WHERE id = $6"
+	result, err := i.db.ExecContext(ctx, query, img.ResultedName, img.ResultedLocation, img.BlurHash, img.Placeholder, img.ResultedSize, img.ID)
 	if err != nil {
 		return utils.ErrUploadImageToDB
 	}
@@ -86,8 +106,8 @@ func (i *ImageRepository) UploadResultedImage(ctx context.Context, img models.Im
 // CreateRequest adds data to multiple tables and returns resulted image id.
 func (i *ImageRepository) CreateRequest(ctx context.Context, user models.User, img models.Image, req models.Request) (uuid.UUID, error) {
 	var id uuid.UUID
-	query := "INSERT INTO image_service.request(user_account_id, image_id, service_name, status, time_started) VALUES($1, $2, $3, $4, $5) RETURNING id"
-	row := i.db.QueryRowContext(ctx, query, user.ID, img.ID, req.ServiceName, req.Status, time.Now())
+	query := "INSERT INTO image_service.request(user_account_id, image_id, service_name, status, time_started, pipeline) VALUES($1, $2, $3, $4, $5, NULLIF($6, '')) RETURNING id"
+	row := i.db.QueryRowContext(ctx, query, user.ID, img.ID, req.ServiceName, req.Status, time.Now(), req.Pipeline)
 	if err := row.Scan(&id); err != nil {
 		return [16]byte{}, utils.ErrCreateRequest
 	}
@@ -108,16 +128,39 @@ func (i *ImageRepository) FindRequestStatus(ctx context.Context, userID, request
 	return models.Status(status), nil
 }
 
+// FindRequest finds the service and status of the request.
+func (i *ImageRepository) FindRequest(ctx context.Context, userID, requestID uuid.UUID) (models.Request, error) {
+	var serviceName, status string
+
+	query := "SELECT r.service_name, r.status FROM image_service.request r WHERE r.user_account_id=$1 and r.id=$2"
+	row := i.db.QueryRowContext(ctx, query, userID, requestID)
+	if err := row.Scan(&serviceName, &status); err != nil {
+		return models.Request{}, utils.ErrGetStatus
+	}
+
+	return models.Request{ID: requestID, UserAccountID: userID, ServiceName: models.Service(serviceName), Status: models.Status(status)}, nil
+}
+
 // FindResultedImage finds processed image by ID.WillReturnResult
 func (i *ImageRepository) FindResultedImage(ctx context.Context, id uuid.UUID) (models.Image, error) {
-	var filename, location string
+	var (
+		filename, location, blurHash, placeholder string
+		uploadedSize, resultedSize                int64
+	)
 
-	image := "SELECT i.resulted_name, i.resulted_location FROM image_service.image i INNER JOIN image_service.request r on i.id = r.image_id WHERE r.id=$1"
+	image := "SELECT i.resulted_name, i.resulted_location, COALESCE(i.blurhash, ''), COALESCE(i.placeholder, ''), COALESCE(i.uploaded_size, 0), COALESCE(i.resulted_size, 0) FROM image_service.image i INNER JOIN image_service.request r on i.id = r.image_id WHERE r.id=$1"
 	row := i.db.QueryRowContext(ctx, image, id)
-	if err := row.Scan(&filename, &location); err != nil {
+	if err := row.Scan(&filename, &location, &blurHash, &placeholder, &uploadedSize, &resultedSize); err != nil {
 		return models.Image{}, utils.ErrFindTheResultingImage
 	}
-	return models.Image{ResultedName: filename, ResultedLocation: location}, nil
+	return models.Image{
+		ResultedName:     filename,
+		ResultedLocation: location,
+		BlurHash:         blurHash,
+		Placeholder:      placeholder,
+		UploadedSize:     uploadedSize,
+		ResultedSize:     resultedSize,
+	}, nil
 }
 
 // FindOriginalImage finds original image by ID.
@@ -176,3 +219,232 @@ func (i *ImageRepository) IsAuthenticated(ctx context.Context, userID, requestID
 	}
 	return nil
 }
+
+// SaveWatermark saves the default watermark of the user, the previous one is replaced.
+func (i *ImageRepository) SaveWatermark(ctx context.Context, userID uuid.UUID, wm models.Watermark) error {
+	query := "INSERT INTO image_service.watermark(user_account_id, logo, text, color, position, opacity, margin, scale) VALUES($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5, $6, $7, $8) ON CONFLICT (user_account_id) DO UPDATE SET logo = EXCLUDED.logo, text = EXCLUDED.text, color = EXCLUDED.color, position = EXCLUDED.position, opacity = EXCLUDED.opacity, margin = EXCLUDED.margin, scale = EXCLUDED.scale"
+	_, err := i.db.ExecContext(ctx, query, userID, wm.Logo, wm.Text, wm.Color, wm.Position, wm.Opacity, wm.Margin, wm.Scale)
+	if err != nil {
+		return utils.ErrSaveWatermark
+	}
+	return nil
+}
+
+// FindWatermark finds the default watermark of the user.
+func (i *ImageRepository) FindWatermark(ctx context.Context, userID uuid.UUID) (models.Watermark, error) {
+	var wm models.Watermark
+
+	query := "SELECT w.logo, COALESCE(w.text, '') as text, COALESCE(w.color, '') as color, w.position, w.opacity, w.margin, w.scale FROM image_service.watermark w WHERE w.user_account_id=$1"
+	row := i.db.QueryRowContext(ctx, query, userID)
+	err := row.Scan(&wm.Logo, &wm.Text, &wm.Color, &wm.Position, &wm.Opacity, &wm.Margin, &wm.Scale)
+	if err == sql.ErrNoRows {
+		return models.Watermark{}, utils.ErrWatermarkNotFound
+	}
+	if err != nil {
+		return models.Watermark{}, utils.ErrFindWatermark
+	}
+	return wm, nil
+}
+
+// DeleteWatermark deletes the default watermark of the user.
+func (i *ImageRepository) DeleteWatermark(ctx context.Context, userID uuid.UUID) error {
+	query := "DELETE FROM image_service.watermark WHERE user_account_id = $1"
+	result, err := i.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return utils.ErrDeleteWatermark
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return utils.ErrRowsAffected
+	}
+	if rows == 0 {
+		return utils.ErrWatermarkNotFound
+	}
+	return nil
+}
+
+// CreateRenditions replaces the renditions of the request in one transaction, so a retried message does not duplicate them.
+func (i *ImageRepository) CreateRenditions(ctx context.Context, requestID uuid.UUID, renditions []models.Rendition) error {
+	return i.inTx(ctx, utils.ErrCreateRendition, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM image_service.rendition WHERE request_id=$1", requestID); err != nil {
+			return err
+		}
+
+		query := "INSERT INTO image_service.rendition(request_id, width, height, format, resulted_name, resulted_location) VALUES($1, $2, $3, $4, $5, $6)"
+		for _, rendition := range renditions {
+			if _, err := tx.ExecContext(ctx, query, requestID, rendition.Width, rendition.Height, rendition.Format, rendition.ResultedName, rendition.ResultedLocation); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// FindRenditions finds the renditions of the request ordered by format and width.
+func (i *ImageRepository) FindRenditions(ctx context.Context, requestID uuid.UUID) ([]models.Rendition, error) {
+	query := "SELECT rd.id, rd.width, rd.height, rd.format, rd.resulted_name, rd.resulted_location FROM image_service.rendition rd WHERE rd.request_id=$1 ORDER BY rd.format, rd.width"
+	rows, err := i.db.QueryContext(ctx, query, requestID)
+	if err != nil {
+		return nil, utils.ErrCreateQuery
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			return
+		}
+	}(rows)
+
+	renditions := []models.Rendition{}
+
+	for rows.Next() {
+		var rendition models.Rendition
+		if err := rows.Scan(&rendition.ID, &rendition.Width, &rendition.Height, &rendition.Format, &rendition.ResultedName, &rendition.ResultedLocation); err != nil {
+			return nil, utils.ErrFindRendition
+		}
+		renditions = append(renditions, rendition)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, utils.ErrFindRendition
+	}
+	return renditions, nil
+}
+
+// FindRendition finds the rendition of the request by id.
+func (i *ImageRepository) FindRendition(ctx context.Context, requestID, renditionID uuid.UUID) (models.Rendition, error) {
+	var rendition models.Rendition
+
+	query := "SELECT rd.id, rd.width, rd.height, rd.format, rd.resulted_name, rd.resulted_location FROM image_service.rendition rd WHERE rd.request_id=$1 and rd.id=$2"
+	row := i.db.QueryRowContext(ctx, query, requestID, renditionID)
+	if err := row.Scan(&rendition.ID, &rendition.Width, &rendition.Height, &rendition.Format, &rendition.ResultedName, &rendition.ResultedLocation); err != nil {
+		return models.Rendition{}, utils.ErrFindRendition
+	}
+	return rendition, nil
+}
+
+// SaveImageHash saves the perceptual hash of the original image.
+func (i *ImageRepository) SaveImageHash(ctx context.Context, imageID uuid.UUID, hash uint64) error {
+	query := "UPDATE image_service.image SET phash = $1 WHERE id = $2"
+	result, err := i.db.ExecContext(ctx, query, int64(hash), imageID)
+	if err != nil {
+		return utils.ErrSaveImageHash
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return utils.ErrRowsAffected
+	}
+	if rows != 1 {
+		return fmt.Errorf("%s:%d", utils.ErrExpectedAffected, rows)
+	}
+
+	return nil
+}
+
+// FindImage finds the uploaded and the resulted files of the image of the user.
+func (i *ImageRepository) FindImage(ctx context.Context, userID, imageID uuid.UUID) (models.Image, error) {
+	var img models.Image
+
+	query := "SELECT i.id, i.uploaded_name, i.uploaded_location, COALESCE(i.resulted_name, ''), COALESCE(i.resulted_location, ''), COALESCE(i.uploaded_size, 0), COALESCE(i.uploaded_sha256, '') FROM image_service.image i INNER JOIN image_service.request r on i.id = r.image_id WHERE r.user_account_id=$1 and i.id=$2"
+	row := i.db.QueryRowContext(ctx, query, userID, imageID)
+	if err := row.Scan(&img.ID, &img.UploadedName, &img.UploadedLocation, &img.ResultedName, &img.ResultedLocation, &img.UploadedSize, &img.UploadedSHA256); err != nil {
+		return models.Image{}, utils.ErrImageNotFound
+	}
+	return img, nil
+}
+
+// FindImageHash finds the perceptual hash of the image of the user.
+func (i *ImageRepository) FindImageHash(ctx context.Context, userID, imageID uuid.UUID) (uint64, error) {
+	var hash sql.NullInt64
+
+	query := "SELECT i.phash FROM image_service.image i INNER JOIN image_service.request r on i.id = r.image_id WHERE r.user_account_id=$1 and i.id=$2"
+	row := i.db.QueryRowContext(ctx, query, userID, imageID)
+	if err := row.Scan(&hash); err != nil {
+		return 0, utils.ErrImageNotFound
+	}
+	if !hash.Valid {
+		return 0, utils.ErrImageHashPending
+	}
+	return uint64(hash.Int64), nil
+}
+
+// FindSimilarImages finds the other images of the user whose hash is within the Hamming distance, the closest go first.
+// No index can serve the distance, so every image of the user is compared.
+func (i *ImageRepository) FindSimilarImages(ctx context.Context, userID, imageID uuid.UUID, hash uint64, distance int) ([]models.SimilarImage, error) {
+	query := "SELECT i.id, r.id, i.uploaded_name, length(replace((i.phash # $3)::bit(64)::text, '0', '')) as distance FROM image_service.image i INNER JOIN image_service.request r on i.id = r.image_id WHERE r.user_account_id=$1 and i.id<>$2 and i.phash IS NOT NULL and length(replace((i.phash # $3)::bit(64)::text, '0', '')) <= $4 ORDER BY distance, i.uploaded_name"
+	rows, err := i.db.QueryContext(ctx, query, userID, imageID, int64(hash), distance)
+	if err != nil {
+		return nil, utils.ErrCreateQuery
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			return
+		}
+	}(rows)
+
+	images := []models.SimilarImage{}
+
+	for rows.Next() {
+		var img models.SimilarImage
+		if err := rows.Scan(&img.ID, &img.RequestID, &img.UploadedName, &img.Distance); err != nil {
+			return nil, utils.ErrFindSimilarImages
+		}
+		images = append(images, img)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, utils.ErrFindSimilarImages
+	}
+	return images, nil
+}
+
+// CreatePalette replaces the palette of the request in one transaction, the colors keep their order as the position.
+// A retried message replaces the colors saved by the earlier attempt instead of failing on the primary key.
+func (i *ImageRepository) CreatePalette(ctx context.Context, requestID uuid.UUID, palette []models.PaletteColor) error {
+	return i.inTx(ctx, utils.ErrCreatePalette, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM image_service.palette_color WHERE request_id=$1", requestID); err != nil {
+			return err
+		}
+
+		query := "INSERT INTO image_service.palette_color(request_id, position, color, coverage) VALUES($1, $2, $3, $4)"
+		for position, color := range palette {
+			if _, err := tx.ExecContext(ctx, query, requestID, position, color.Color, color.Coverage); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// FindPalette finds the palette of the request, the most common color goes first.
+func (i *ImageRepository) FindPalette(ctx context.Context, requestID uuid.UUID) ([]models.PaletteColor, error) {
+	query := "SELECT pc.color, pc.coverage FROM image_service.palette_color pc WHERE pc.request_id=$1 ORDER BY pc.position"
+	rows, err := i.db.QueryContext(ctx, query, requestID)
+	if err != nil {
+		return nil, utils.ErrCreateQuery
+	}
+
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			return
+		}
+	}(rows)
+
+	palette := []models.PaletteColor{}
+
+	for rows.Next() {
+		var color models.PaletteColor
+		if err := rows.Scan(&color.Color, &color.Coverage); err != nil {
+			return nil, utils.ErrFindPalette
+		}
+		palette = append(palette, color)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, utils.ErrFindPalette
+	}
+	return palette, nil
+}
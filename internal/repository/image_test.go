@@ -11,6 +11,7 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/alisavch/image-service/internal/models"
+	"github.com/alisavch/image-service/internal/utils"
 	"github.com/stretchr/testify/require"
 )
 
@@ -43,7 +44,7 @@ func TestImageRepository_FindUserRequestHistory(t *testing.T) {
 			input: [16]byte{00000000 - 0000 - 0000 - 0000 - 000000000000},
 			mock: func() {
 				asString := "00000000-0000-0000-0000-000000000000"
-				rows := sqlmock.NewRows([]string{"uploaded_name", "resulted_name", "service", "time_start", "end_of_time", "status"}).AddRow("", "", "", "", "", "")
+				rows := sqlmock.NewRows([]string{"id", "uploaded_name", "resulted_name", "service", "time_start", "end_of_time", "status", "pipeline", "blurhash", "placeholder"}).AddRow("", "", "", "", "", "", "", "", "", "")
 				mock.ExpectQuery("SELECT (.+) from image_service.request r INNER JOIN image_service.image i on r.image_id = i.id INNER JOIN image_service.user_account ua on ua.id = r.user_account_id").
 					WithArgs(asString).WillReturnRows(rows)
 			},
@@ -94,12 +95,15 @@ func TestImageRepository_UploadImage(t *testing.T) {
 			input: models.Image{
 				UploadedName:     "filename",
 				UploadedLocation: "location",
+				UploadedSize:     2048,
+				UploadedFormat:   "jpeg",
+				UploadedSHA256:   "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
 			},
 			mock: func() {
 				asString := "00000000-0000-0000-0000-000000000000"
 				rows := sqlmock.NewRows([]string{"id"}).AddRow(asString)
 				mock.ExpectQuery("INSERT INTO image_service.image(.+)").
-					WithArgs("filename", "location").WillReturnRows(rows)
+					WithArgs("filename", "location", 2048, "jpeg", "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08").WillReturnRows(rows)
 			},
 			want: [16]byte{00000000 - 0000 - 0000 - 0000 - 000000000000},
 			isOk: true,
@@ -109,7 +113,7 @@ func TestImageRepository_UploadImage(t *testing.T) {
 			mock: func() {
 				rows := sqlmock.NewRows([]string{"id"})
 				mock.ExpectQuery("INSERT INTO image_service.image(.+)").
-					WithArgs("", "location").WillReturnRows(rows)
+					WithArgs("", "location", 0, "", "").WillReturnRows(rows)
 			},
 			input: models.Image{
 				UploadedName:     "",
@@ -134,6 +138,75 @@ func TestImageRepository_UploadImage(t *testing.T) {
 	}
 }
 
+func TestImageRepository_FindRequest(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected wher opening a stub database connection", err)
+	}
+
+	repo := NewImageRepository(db)
+
+	type args struct {
+		userID    uuid.UUID
+		requestID uuid.UUID
+	}
+
+	type mockBehavior func(args args)
+
+	tests := []struct {
+		name  string
+		mock  mockBehavior
+		input args
+		want  models.Request
+		isOk  bool
+	}{
+		{
+			name: "Test with correct values",
+			input: args{
+				userID:    uuid.MustParse("7fe1c6a2-3c4b-4e2f-9f68-8b7d2b8f4e11"),
+				requestID: uuid.MustParse("2c9d5e0a-6f1b-4c3d-8a7e-1f2b3c4d5e6f"),
+			},
+			mock: func(args args) {
+				rows := sqlmock.NewRows([]string{"service_name", "status"}).AddRow("palette", "done")
+				mock.ExpectQuery("SELECT (.+) FROM image_service.request").
+					WithArgs(args.userID, args.requestID).WillReturnRows(rows)
+			},
+			want: models.Request{
+				ID:            uuid.MustParse("2c9d5e0a-6f1b-4c3d-8a7e-1f2b3c4d5e6f"),
+				UserAccountID: uuid.MustParse("7fe1c6a2-3c4b-4e2f-9f68-8b7d2b8f4e11"),
+				ServiceName:   models.PaletteAnalysis,
+				Status:        models.Done,
+			},
+			isOk: true,
+		},
+		{
+			name: "Test with not found request",
+			mock: func(args args) {
+				rows := sqlmock.NewRows([]string{"service_name", "status"})
+				mock.ExpectQuery("SELECT (.+) FROM image_service.request").
+					WithArgs(args.userID, args.requestID).WillReturnRows(rows)
+			},
+			input: args{},
+			isOk:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock(tt.input)
+
+			got, err := repo.FindRequest(context.TODO(), tt.input.userID, tt.input.requestID)
+			if tt.isOk {
+				require.NoError(t, err)
+				require.Equal(t, tt.want, got)
+			} else {
+				require.Error(t, err)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
 func TestImageRepository_FindResultedImage(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -163,14 +236,18 @@ func TestImageRepository_FindResultedImage(t *testing.T) {
 				service: models.Conversion,
 			},
 			mock: func(args args) {
-				rows := sqlmock.NewRows([]string{"resulted_name", "resulted_location"}).
-					AddRow("filename", "location")
+				rows := sqlmock.NewRows([]string{"resulted_name", "resulted_location", "blurhash", "placeholder", "uploaded_size", "resulted_size"}).
+					AddRow("filename", "location", "LEHV6nWB2yk8pyo0adR*.7kCMdnj", "data:image/jpeg;base64,", 2048, 512)
 				mock.ExpectQuery("SELECT (.+) FROM image_service.image").
 					WithArgs(args.id).WillReturnRows(rows)
 			},
 			want: models.Image{
 				ResultedName:     "filename",
 				ResultedLocation: "location",
+				BlurHash:         "LEHV6nWB2yk8pyo0adR*.7kCMdnj",
+				Placeholder:      "data:image/jpeg;base64,",
+				UploadedSize:     2048,
+				ResultedSize:     512,
 			},
 			isOk: true,
 		},
@@ -337,3 +414,740 @@ func TestImageRepository_UpdateStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestImageRepository_SaveWatermark(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected wher opening a stub database connection", err)
+	}
+
+	repo := NewImageRepository(db)
+
+	type args struct {
+		userID    uuid.UUID
+		watermark models.Watermark
+	}
+
+	type mockBehavior func(args args)
+
+	tests := []struct {
+		name  string
+		mock  mockBehavior
+		input args
+		isOk  bool
+	}{
+		{
+			name: "Test with correct values",
+			mock: func(args2 args) {
+				wm := args2.watermark
+				mock.ExpectExec("INSERT INTO image_service.watermark(.+) ON CONFLICT").
+					WithArgs(args2.userID, wm.Logo, wm.Text, wm.Color, wm.Position, wm.Opacity, wm.Margin, wm.Scale).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			input: args{
+				userID:    [16]byte{00000000 - 0000 - 0000 - 0000 - 000000000000},
+				watermark: models.Watermark{Text: "preview", Color: "ffffff", Position: models.BottomRight, Opacity: 0.5, Margin: 16, Scale: 0.25},
+			},
+			isOk: true,
+		},
+		{
+			name: "Test with incorrect values",
+			mock: func(args2 args) {
+				wm := args2.watermark
+				mock.ExpectExec("INSERT INTO image_service.watermark(.+) ON CONFLICT").
+					WithArgs(args2.userID, wm.Logo, wm.Text, wm.Color, wm.Position, wm.Opacity, wm.Margin, wm.Scale).WillReturnError(fmt.Errorf("cannot save watermark"))
+			},
+			input: args{
+				userID:    [16]byte{},
+				watermark: models.Watermark{Text: "preview", Position: models.Tiled, Opacity: 1, Scale: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock(tt.input)
+
+			err := repo.SaveWatermark(context.TODO(), tt.input.userID, tt.input.watermark)
+			if tt.isOk {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestImageRepository_FindWatermark(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected wher opening a stub database connection", err)
+	}
+
+	repo := NewImageRepository(db)
+
+	columns := []string{"logo", "text", "color", "position", "opacity", "margin", "scale"}
+
+	tests := []struct {
+		name    string
+		mock    func(id uuid.UUID)
+		input   uuid.UUID
+		want    models.Watermark
+		wantErr error
+	}{
+		{
+			name:  "Test with correct values",
+			input: [16]byte{00000000 - 0000 - 0000 - 0000 - 000000000000},
+			mock: func(id uuid.UUID) {
+				rows := sqlmock.NewRows(columns).AddRow(nil, "preview", "ffffff", "tiled", 0.5, 16, 0.25)
+				mock.ExpectQuery("SELECT (.+) FROM image_service.watermark w").
+					WithArgs(id).WillReturnRows(rows)
+			},
+			want: models.Watermark{Text: "preview", Color: "ffffff", Position: models.Tiled, Opacity: 0.5, Margin: 16, Scale: 0.25},
+		},
+		{
+			name:  "Test without saved watermark",
+			input: [16]byte{},
+			mock: func(id uuid.UUID) {
+				rows := sqlmock.NewRows(columns)
+				mock.ExpectQuery("SELECT (.+) FROM image_service.watermark w").
+					WithArgs(id).WillReturnRows(rows)
+			},
+			wantErr: utils.ErrWatermarkNotFound,
+		},
+		{
+			name:  "Test with incorrect values",
+			input: [16]byte{},
+			mock: func(id uuid.UUID) {
+				mock.ExpectQuery("SELECT (.+) FROM image_service.watermark w").
+					WithArgs(id).WillReturnError(fmt.Errorf("cannot find watermark"))
+			},
+			wantErr: utils.ErrFindWatermark,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock(tt.input)
+
+			got, err := repo.FindWatermark(context.TODO(), tt.input)
+			if tt.wantErr == nil {
+				require.NoError(t, err)
+				require.Equal(t, tt.want, got)
+			} else {
+				require.Equal(t, tt.wantErr, err)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestImageRepository_DeleteWatermark(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected wher opening a stub database connection", err)
+	}
+
+	repo := NewImageRepository(db)
+
+	tests := []struct {
+		name    string
+		mock    func(id uuid.UUID)
+		input   uuid.UUID
+		wantErr error
+	}{
+		{
+			name:  "Test with correct values",
+			input: [16]byte{00000000 - 0000 - 0000 - 0000 - 000000000000},
+			mock: func(id uuid.UUID) {
+				mock.ExpectExec("DELETE FROM image_service.watermark").
+					WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name:  "Test without saved watermark",
+			input: [16]byte{},
+			mock: func(id uuid.UUID) {
+				mock.ExpectExec("DELETE FROM image_service.watermark").
+					WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			wantErr: utils.ErrWatermarkNotFound,
+		},
+		{
+			name:  "Test with incorrect values",
+			input: [16]byte{},
+			mock: func(id uuid.UUID) {
+				mock.ExpectExec("DELETE FROM image_service.watermark").
+					WithArgs(id).WillReturnError(fmt.Errorf("cannot delete watermark"))
+			},
+			wantErr: utils.ErrDeleteWatermark,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock(tt.input)
+
+			err := repo.DeleteWatermark(context.TODO(), tt.input)
+			require.Equal(t, tt.wantErr, err)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestImageRepository_CreateRenditions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected wher opening a stub database connection", err)
+	}
+
+	repo := NewImageRepository(db)
+
+	id := uuid.MustParse("00000000-0000-0000-0000-000000000000")
+	renditions := []models.Rendition{
+		{Width: 320, Height: 240, Format: "jpeg", ResultedName: "320w-thb-filename.jpeg", ResultedLocation: "location"},
+		{Width: 640, Height: 480, Format: "jpeg", ResultedName: "640w-thb-filename.jpeg", ResultedLocation: "location"},
+	}
+	expectReplace := func() {
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE FROM image_service.rendition WHERE request_id").
+			WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 2))
+		for _, rendition := range renditions {
+			mock.ExpectExec("INSERT INTO image_service.rendition(.+)").
+				WithArgs(id, rendition.Width, rendition.Height, rendition.Format, rendition.ResultedName, rendition.ResultedLocation).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+		mock.ExpectCommit()
+	}
+
+	tests := []struct {
+		name    string
+		mock    func()
+		runs    int
+		wantErr bool
+	}{
+		{
+			name: "Test with correct values",
+			mock: expectReplace,
+			runs: 1,
+		},
+		{
+			name: "Test with a retried message",
+			mock: func() {
+				expectReplace()
+				expectReplace()
+			},
+			runs: 2,
+		},
+		{
+			name: "Test with incorrect values",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec("DELETE FROM image_service.rendition WHERE request_id").
+					WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("INSERT INTO image_service.rendition(.+)").
+					WillReturnError(fmt.Errorf("cannot save rendition"))
+				mock.ExpectRollback()
+			},
+			runs:    1,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			for i := 0; i < tt.runs; i++ {
+				err := repo.CreateRenditions(context.TODO(), id, renditions)
+				if tt.wantErr {
+					require.Error(t, err)
+					require.Contains(t, err.Error(), utils.ErrCreateRendition.Error())
+				} else {
+					require.NoError(t, err)
+				}
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestImageRepository_FindRenditions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected wher opening a stub database connection", err)
+	}
+
+	repo := NewImageRepository(db)
+
+	columns := []string{"id", "width", "height", "format", "resulted_name", "resulted_location"}
+	asString := "00000000-0000-0000-0000-000000000000"
+	id := uuid.MustParse(asString)
+
+	tests := []struct {
+		name  string
+		mock  func()
+		input uuid.UUID
+		want  []models.Rendition
+		isOk  bool
+	}{
+		{
+			name:  "Test with correct values",
+			input: id,
+			mock: func() {
+				rows := sqlmock.NewRows(columns).
+					AddRow(asString, 320, 240, "jpeg", "320w-thb-filename.jpeg", "location").
+					AddRow(asString, 640, 480, "jpeg", "640w-thb-filename.jpeg", "location")
+				mock.ExpectQuery("SELECT (.+) FROM image_service.rendition rd WHERE rd.request_id=(.+) ORDER BY").
+					WithArgs(asString).WillReturnRows(rows)
+			},
+			want: []models.Rendition{
+				{ID: id, Width: 320, Height: 240, Format: "jpeg", ResultedName: "320w-thb-filename.jpeg", ResultedLocation: "location"},
+				{ID: id, Width: 640, Height: 480, Format: "jpeg", ResultedName: "640w-thb-filename.jpeg", ResultedLocation: "location"},
+			},
+			isOk: true,
+		},
+		{
+			name:  "Test with incorrect values",
+			input: id,
+			mock: func() {
+				mock.ExpectQuery("SELECT (.+) FROM image_service.rendition rd WHERE rd.request_id=(.+) ORDER BY").
+					WithArgs(asString).WillReturnError(fmt.Errorf("cannot create a query"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			got, err := repo.FindRenditions(context.TODO(), tt.input)
+			if tt.isOk {
+				require.NoError(t, err)
+				require.Equal(t, tt.want, got)
+			} else {
+				require.Error(t, err)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestImageRepository_FindRendition(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected wher opening a stub database connection", err)
+	}
+
+	repo := NewImageRepository(db)
+
+	columns := []string{"id", "width", "height", "format", "resulted_name", "resulted_location"}
+	asString := "00000000-0000-0000-0000-000000000000"
+	id := uuid.MustParse(asString)
+
+	tests := []struct {
+		name string
+		mock func()
+		want models.Rendition
+		isOk bool
+	}{
+		{
+			name: "Test with correct values",
+			mock: func() {
+				rows := sqlmock.NewRows(columns).AddRow(asString, 320, 240, "png", "320w-thb-filename.png", "location")
+				mock.ExpectQuery("SELECT (.+) FROM image_service.rendition rd").
+					WithArgs(asString, asString).WillReturnRows(rows)
+			},
+			want: models.Rendition{ID: id, Width: 320, Height: 240, Format: "png", ResultedName: "320w-thb-filename.png", ResultedLocation: "location"},
+			isOk: true,
+		},
+		{
+			name: "Test with incorrect values",
+			mock: func() {
+				rows := sqlmock.NewRows(columns)
+				mock.ExpectQuery("SELECT (.+) FROM image_service.rendition rd").
+					WithArgs(asString, asString).WillReturnRows(rows)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			got, err := repo.FindRendition(context.TODO(), id, id)
+			if tt.isOk {
+				require.NoError(t, err)
+				require.Equal(t, tt.want, got)
+			} else {
+				require.Error(t, err)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestImageRepository_SaveImageHash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected wher opening a stub database connection", err)
+	}
+
+	repo := NewImageRepository(db)
+
+	id := uuid.MustParse("00000000-0000-0000-0000-000000000000")
+	hash := uint64(0xf0e1d2c3b4a59687)
+
+	tests := []struct {
+		name string
+		mock func()
+		isOk bool
+	}{
+		{
+			name: "Test with correct values",
+			mock: func() {
+				mock.ExpectExec("UPDATE image_service.image SET phash").
+					WithArgs(int64(hash), id).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			isOk: true,
+		},
+		{
+			name: "Test without image",
+			mock: func() {
+				mock.ExpectExec("UPDATE image_service.image SET phash").
+					WithArgs(int64(hash), id).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+		},
+		{
+			name: "Test with incorrect values",
+			mock: func() {
+				mock.ExpectExec("UPDATE image_service.image SET phash").
+					WithArgs(int64(hash), id).WillReturnError(fmt.Errorf("cannot save image hash"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			err := repo.SaveImageHash(context.TODO(), id, hash)
+			if tt.isOk {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestImageRepository_FindImage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected wher opening a stub database connection", err)
+	}
+
+	repo := NewImageRepository(db)
+
+	columns := []string{"id", "uploaded_name", "uploaded_location", "resulted_name", "resulted_location", "uploaded_size", "uploaded_sha256"}
+	asString := "00000000-0000-0000-0000-000000000000"
+	id := uuid.MustParse(asString)
+
+	tests := []struct {
+		name    string
+		mock    func()
+		want    models.Image
+		wantErr error
+	}{
+		{
+			name: "Test with correct values",
+			mock: func() {
+				rows := sqlmock.NewRows(columns).AddRow(asString, "filename", "location", "resulted", "results", 2048, "digest")
+				mock.ExpectQuery("SELECT (.+) FROM image_service.image i").
+					WithArgs(id, id).WillReturnRows(rows)
+			},
+			want: models.Image{
+				ID:               id,
+				UploadedName:     "filename",
+				UploadedLocation: "location",
+				ResultedName:     "resulted",
+				ResultedLocation: "results",
+				UploadedSize:     2048,
+				UploadedSHA256:   "digest",
+			},
+		},
+		{
+			name: "Test with image of another user",
+			mock: func() {
+				rows := sqlmock.NewRows(columns)
+				mock.ExpectQuery("SELECT (.+) FROM image_service.image i").
+					WithArgs(id, id).WillReturnRows(rows)
+			},
+			wantErr: utils.ErrImageNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			got, err := repo.FindImage(context.TODO(), id, id)
+			if tt.wantErr == nil {
+				require.NoError(t, err)
+				require.Equal(t, tt.want, got)
+			} else {
+				require.Equal(t, tt.wantErr, err)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestImageRepository_FindImageHash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected wher opening a stub database connection", err)
+	}
+
+	repo := NewImageRepository(db)
+
+	id := uuid.MustParse("00000000-0000-0000-0000-000000000000")
+	hash := uint64(0xf0e1d2c3b4a59687)
+
+	tests := []struct {
+		name    string
+		mock    func()
+		want    uint64
+		wantErr error
+	}{
+		{
+			name: "Test with correct values",
+			mock: func() {
+				rows := sqlmock.NewRows([]string{"phash"}).AddRow(int64(hash))
+				mock.ExpectQuery("SELECT i.phash FROM image_service.image i").
+					WithArgs(id, id).WillReturnRows(rows)
+			},
+			want: hash,
+		},
+		{
+			name: "Test with image that has not been analysed",
+			mock: func() {
+				rows := sqlmock.NewRows([]string{"phash"}).AddRow(nil)
+				mock.ExpectQuery("SELECT i.phash FROM image_service.image i").
+					WithArgs(id, id).WillReturnRows(rows)
+			},
+			wantErr: utils.ErrImageHashPending,
+		},
+		{
+			name: "Test with image of another user",
+			mock: func() {
+				rows := sqlmock.NewRows([]string{"phash"})
+				mock.ExpectQuery("SELECT i.phash FROM image_service.image i").
+					WithArgs(id, id).WillReturnRows(rows)
+			},
+			wantErr: utils.ErrImageNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			got, err := repo.FindImageHash(context.TODO(), id, id)
+			if tt.wantErr == nil {
+				require.NoError(t, err)
+				require.Equal(t, tt.want, got)
+			} else {
+				require.Equal(t, tt.wantErr, err)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestImageRepository_FindSimilarImages(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected wher opening a stub database connection", err)
+	}
+
+	repo := NewImageRepository(db)
+
+	columns := []string{"id", "request_id", "uploaded_name", "distance"}
+	asString := "00000000-0000-0000-0000-000000000000"
+	id := uuid.MustParse(asString)
+	hash := uint64(0xf0e1d2c3b4a59687)
+
+	tests := []struct {
+		name string
+		mock func()
+		want []models.SimilarImage
+		isOk bool
+	}{
+		{
+			name: "Test with correct values",
+			mock: func() {
+				rows := sqlmock.NewRows(columns).
+					AddRow(asString, asString, "copy.jpeg", 0).
+					AddRow(asString, asString, "edited.jpeg", 6)
+				mock.ExpectQuery("SELECT (.+) FROM image_service.image i INNER JOIN image_service.request r (.+) ORDER BY distance").
+					WithArgs(id, id, int64(hash), 10).WillReturnRows(rows)
+			},
+			want: []models.SimilarImage{
+				{ID: id, RequestID: id, UploadedName: "copy.jpeg", Distance: 0},
+				{ID: id, RequestID: id, UploadedName: "edited.jpeg", Distance: 6},
+			},
+			isOk: true,
+		},
+		{
+			name: "Test with incorrect values",
+			mock: func() {
+				mock.ExpectQuery("SELECT (.+) FROM image_service.image i INNER JOIN image_service.request r (.+) ORDER BY distance").
+					WithArgs(id, id, int64(hash), 10).WillReturnError(fmt.Errorf("cannot create a query"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			got, err := repo.FindSimilarImages(context.TODO(), id, id, hash, 10)
+			if tt.isOk {
+				require.NoError(t, err)
+				require.Equal(t, tt.want, got)
+			} else {
+				require.Error(t, err)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestImageRepository_CreatePalette(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected wher opening a stub database connection", err)
+	}
+
+	repo := NewImageRepository(db)
+
+	id := uuid.MustParse("00000000-0000-0000-0000-000000000000")
+	palette := []models.PaletteColor{{Color: "#c81e1e", Coverage: 75}, {Color: "#1e1ec8", Coverage: 25}}
+	expectReplace := func() {
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE FROM image_service.palette_color WHERE request_id").
+			WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 2))
+		for position, color := range palette {
+			mock.ExpectExec("INSERT INTO image_service.palette_color").
+				WithArgs(id, position, color.Color, color.Coverage).WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+		mock.ExpectCommit()
+	}
+
+	tests := []struct {
+		name    string
+		mock    func()
+		runs    int
+		wantErr bool
+	}{
+		{
+			name: "Test with correct values",
+			mock: expectReplace,
+			runs: 1,
+		},
+		{
+			name: "Test with a retried message",
+			mock: func() {
+				expectReplace()
+				expectReplace()
+			},
+			runs: 2,
+		},
+		{
+			name: "Test with incorrect values",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec("DELETE FROM image_service.palette_color WHERE request_id").
+					WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("INSERT INTO image_service.palette_color").
+					WithArgs(id, 0, palette[0].Color, palette[0].Coverage).WillReturnError(fmt.Errorf("duplicate key value"))
+				mock.ExpectRollback()
+			},
+			runs:    1,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			for i := 0; i < tt.runs; i++ {
+				err := repo.CreatePalette(context.TODO(), id, palette)
+				if tt.wantErr {
+					require.Error(t, err)
+					require.Contains(t, err.Error(), utils.ErrCreatePalette.Error())
+				} else {
+					require.NoError(t, err)
+				}
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestImageRepository_FindPalette(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected wher opening a stub database connection", err)
+	}
+
+	repo := NewImageRepository(db)
+
+	asString := "00000000-0000-0000-0000-000000000000"
+	id := uuid.MustParse(asString)
+
+	tests := []struct {
+		name string
+		mock func()
+		want []models.PaletteColor
+		isOk bool
+	}{
+		{
+			name: "Test with correct values",
+			mock: func() {
+				rows := sqlmock.NewRows([]string{"color", "coverage"}).
+					AddRow("#f0f0f0", 62.5).
+					AddRow("#c81e1e", 37.5)
+				mock.ExpectQuery("SELECT (.+) FROM image_service.palette_color pc WHERE pc.request_id=(.+) ORDER BY pc.position").
+					WithArgs(asString).WillReturnRows(rows)
+			},
+			want: []models.PaletteColor{{Color: "#f0f0f0", Coverage: 62.5}, {Color: "#c81e1e", Coverage: 37.5}},
+			isOk: true,
+		},
+		{
+			name: "Test with incorrect values",
+			mock: func() {
+				mock.ExpectQuery("SELECT (.+) FROM image_service.palette_color pc WHERE pc.request_id=(.+) ORDER BY pc.position").
+					WithArgs(asString).WillReturnError(fmt.Errorf("cannot create a query"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			got, err := repo.FindPalette(context.TODO(), id)
+			if tt.isOk {
+				require.NoError(t, err)
+				require.Equal(t, tt.want, got)
+			} else {
+				require.Error(t, err)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"image"
+	"image/draw"
+
+	"github.com/alisavch/image-service/internal/models"
+	"github.com/alisavch/image-service/internal/utils"
+)
+
+// CropImage cuts the rectangle out of the image, the rectangle must lie within the image bounds.
+func CropImage(imgSrc image.Image, opts models.Crop) (image.Image, error) {
+	rect, err := cropRect(imgSrc, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if anim, ok := imgSrc.(*Animation); ok {
+		return cropAnimation(anim, rect), nil
+	}
+
+	dst := image.NewRGBA(image.Rectangle{Max: rect.Size()})
+	draw.Draw(dst, dst.Bounds(), imgSrc, rect.Min, draw.Src)
+
+	return dst, nil
+}
+
+// cropRect finds the rectangle of the image that is kept.
+func cropRect(imgSrc image.Image, opts models.Crop) (image.Rectangle, error) {
+	bounds := imgSrc.Bounds()
+	if opts.IsRect() {
+		rect := image.Rect(opts.X, opts.Y, opts.X+opts.Width, opts.Y+opts.Height).Add(bounds.Min)
+		if opts.X < 0 || opts.Y < 0 || !rect.In(bounds) {
+			return image.Rectangle{}, utils.ErrCropBounds
+		}
+		return rect, nil
+	}
+
+	if opts.AspectWidth <= 0 || opts.AspectHeight <= 0 {
+		return image.Rectangle{}, utils.ErrCropParams
+	}
+
+	size := bounds.Size()
+	width, height := size.X, size.X*opts.AspectHeight/opts.AspectWidth
+	if height > size.Y {
+		width, height = size.Y*opts.AspectWidth/opts.AspectHeight, size.Y
+	}
+	if width == 0 || height == 0 {
+		return image.Rectangle{}, utils.ErrCropBounds
+	}
+
+	window := image.Pt(width, height)
+	if opts.Gravity == models.Smart {
+		return image.Rectangle{Max: window}.Add(bounds.Min).Add(smartOffset(imgSrc, window)), nil
+	}
+
+	offset, err := gravityOffset(size.Sub(window), opts.Gravity)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+
+	return image.Rectangle{Max: window}.Add(bounds.Min).Add(offset), nil
+}
+
+// gravityOffset places the kept part within the free space according to the gravity.
+func gravityOffset(free image.Point, gravity models.Gravity) (image.Point, error) {
+	switch gravity {
+	case models.Center, "":
+		return free.Div(2), nil
+	case models.North:
+		return image.Pt(free.X/2, 0), nil
+	case models.South:
+		return image.Pt(free.X/2, free.Y), nil
+	case models.East:
+		return image.Pt(free.X, free.Y/2), nil
+	case models.West:
+		return image.Pt(0, free.Y/2), nil
+	case models.NorthEast:
+		return image.Pt(free.X, 0), nil
+	case models.NorthWest:
+		return image.Pt(0, 0), nil
+	case models.SouthEast:
+		return free, nil
+	case models.SouthWest:
+		return image.Pt(0, free.Y), nil
+	}
+	return image.Point{}, utils.ErrGravity
+}
+
+// cropAnimation cuts the rectangle out of every frame of the animation.
+func cropAnimation(anim *Animation, rect image.Rectangle) *Animation {
+	cropped := *anim.GIF
+	cropped.Image = make([]*image.Paletted, len(anim.Image))
+	cropped.Config.Width, cropped.Config.Height = rect.Dx(), rect.Dy()
+
+	for i, frame := range anim.Image {
+		visible := frame.Bounds().Intersect(rect)
+		if visible.Empty() {
+			visible = image.Rectangle{Min: rect.Min, Max: rect.Min.Add(image.Pt(1, 1))}
+		}
+		dst := image.NewPaletted(visible.Sub(rect.Min), frame.Palette)
+		draw.Draw(dst, dst.Bounds(), frame, visible.Min, draw.Src)
+		cropped.Image[i] = dst
+	}
+
+	return &Animation{GIF: &cropped}
+}
@@ -0,0 +1,311 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"strings"
+)
+
+const (
+	exifTagMake             = 0x010F
+	exifTagModel            = 0x0110
+	exifTagOrientation      = 0x0112
+	exifTagDateTime         = 0x0132
+	exifTagArtist           = 0x013B
+	exifTagSubIFDs          = 0x014A
+	exifTagCopyright        = 0x8298
+	exifTagExifIFD          = 0x8769
+	exifTagGPSIFD           = 0x8825
+	exifTagDateTimeOriginal = 0x9003
+	exifTagMakerNote        = 0x927C
+	exifTagInteropIFD       = 0xA005
+	exifTagImageUniqueID    = 0xA420
+	exifTagCameraOwnerName  = 0xA430
+	exifTagBodySerialNumber = 0xA431
+	exifTagLensSerialNumber = 0xA435
+	exifTagCameraSerial     = 0xC62F
+)
+
+// exifTypeSizes contains the size in bytes of a single value of every TIFF field type.
+var exifTypeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+var exifHeader = []byte("Exif\x00\x00")
+
+// jpegSegment is an application segment of the JPEG file.
+type jpegSegment struct {
+	marker byte
+	data   []byte
+}
+
+// jpegSegments returns the segments that precede the image data of the JPEG file.
+func jpegSegments(data []byte) []jpegSegment {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil
+	}
+
+	var segments []jpegSegment
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			break
+		}
+		marker := data[i+1]
+		if marker == 0xFF {
+			i++
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 {
+			break
+		}
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			i += 2
+			continue
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		if length < 2 || i+2+length > len(data) {
+			break
+		}
+		segments = append(segments, jpegSegment{marker: marker, data: data[i+4 : i+2+length]})
+		i += 2 + length
+	}
+
+	return segments
+}
+
+// jpegExif returns the TIFF structure stored in the EXIF segment of the JPEG file.
+func jpegExif(data []byte) []byte {
+	for _, segment := range jpegSegments(data) {
+		if segment.marker == 0xE1 && bytes.HasPrefix(segment.data, exifHeader) {
+			return segment.data[len(exifHeader):]
+		}
+	}
+	return nil
+}
+
+// exifEntry is a single entry of the image file directory.
+type exifEntry struct {
+	tag    uint16
+	kind   uint16
+	count  uint32
+	offset int
+}
+
+// exif is the parsed TIFF structure of the EXIF data.
+type exif struct {
+	raw   []byte
+	order binary.ByteOrder
+	ifd0  []exifEntry
+}
+
+// parseExif parses the TIFF header and the first image file directory.
+func parseExif(raw []byte) (*exif, bool) {
+	if len(raw) < 8 {
+		return nil, false
+	}
+
+	var order binary.ByteOrder
+	switch string(raw[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, false
+	}
+	if order.Uint16(raw[2:4]) != 42 {
+		return nil, false
+	}
+
+	e := &exif{raw: raw, order: order}
+	e.ifd0 = e.readIFD(int(order.Uint32(raw[4:8])))
+
+	return e, true
+}
+
+func (e *exif) readIFD(offset int) []exifEntry {
+	if offset < 8 || offset+2 > len(e.raw) {
+		return nil
+	}
+
+	count := int(e.order.Uint16(e.raw[offset : offset+2]))
+	entries := make([]exifEntry, 0, count)
+	for i := 0; i < count; i++ {
+		start := offset + 2 + i*12
+		if start+12 > len(e.raw) {
+			break
+		}
+		entries = append(entries, exifEntry{
+			tag:    e.order.Uint16(e.raw[start : start+2]),
+			kind:   e.order.Uint16(e.raw[start+2 : start+4]),
+			count:  e.order.Uint32(e.raw[start+4 : start+8]),
+			offset: start + 8,
+		})
+	}
+
+	return entries
+}
+
+// value returns the raw bytes of the entry value, nil if they are out of the data.
+func (e *exif) value(entry exifEntry) []byte {
+	size, ok := exifTypeSizes[entry.kind]
+	if !ok || entry.count > uint32(len(e.raw)) {
+		return nil
+	}
+
+	length := size * int(entry.count)
+	start := entry.offset
+	if length > 4 {
+		start = int(e.order.Uint32(e.raw[entry.offset : entry.offset+4]))
+	}
+	if start < 0 || start+length > len(e.raw) {
+		return nil
+	}
+
+	return e.raw[start : start+length]
+}
+
+// exifField is an entry of the image file directory with its value, it is used to write the directory again.
+type exifField struct {
+	entry exifEntry
+	value []byte
+}
+
+// fields returns the entries of the directory that are not dropped with their values.
+// The pointer to the EXIF directory is left out, filter writes it again.
+func (e *exif) fields(entries []exifEntry, drop map[uint16]bool) []exifField {
+	var fields []exifField
+	for _, entry := range entries {
+		if drop[entry.tag] || entry.tag == exifTagExifIFD {
+			continue
+		}
+		if value := e.value(entry); value != nil {
+			fields = append(fields, exifField{entry: entry, value: value})
+		}
+	}
+	return fields
+}
+
+// filter builds the EXIF data without the dropped tags. The first image file directory and the EXIF directory
+// are copied, the other directories like the thumbnail are left out.
+func (e *exif) filter(drop map[uint16]bool) []byte {
+	fields := e.fields(e.ifd0, drop)
+	exifFields := e.fields(e.subIFD(exifTagExifIFD), drop)
+	if len(exifFields) > 0 {
+		fields = append(fields, exifField{entry: exifEntry{tag: exifTagExifIFD, kind: 4, count: 1}, value: make([]byte, 4)})
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	out := make([]byte, 8)
+	if e.order == binary.LittleEndian {
+		copy(out, "II")
+	} else {
+		copy(out, "MM")
+	}
+	e.order.PutUint16(out[2:4], 42)
+	e.order.PutUint32(out[4:8], 8)
+
+	out, inline := e.appendIFD(out, fields)
+	if len(exifFields) > 0 {
+		if len(out)%2 == 1 {
+			out = append(out, 0)
+		}
+		start := len(out)
+		out, _ = e.appendIFD(out, exifFields)
+		e.order.PutUint32(out[inline[exifTagExifIFD]:], uint32(start))
+	}
+
+	return out
+}
+
+// appendIFD writes the directory with the fields sorted by tag at the end of the data, the values that do not fit
+// into the entries follow it. It returns the offsets of the values stored in the entries.
+func (e *exif) appendIFD(out []byte, fields []exifField) ([]byte, map[uint16]int) {
+	sort.Slice(fields, func(i, j int) bool { return fields[i].entry.tag < fields[j].entry.tag })
+
+	offset := len(out)
+	out = append(out, make([]byte, 2+len(fields)*12+4)...)
+	e.order.PutUint16(out[offset:offset+2], uint16(len(fields)))
+
+	inline := make(map[uint16]int, len(fields))
+	for i, f := range fields {
+		start := offset + 2 + i*12
+		e.order.PutUint16(out[start:start+2], f.entry.tag)
+		e.order.PutUint16(out[start+2:start+4], f.entry.kind)
+		e.order.PutUint32(out[start+4:start+8], f.entry.count)
+		if len(f.value) <= 4 {
+			copy(out[start+8:start+12], f.value)
+			inline[f.entry.tag] = start + 8
+			continue
+		}
+		if len(out)%2 == 1 {
+			out = append(out, 0)
+		}
+		e.order.PutUint32(out[start+8:start+12], uint32(len(out)))
+		out = append(out, f.value...)
+	}
+
+	return out, inline
+}
+
+// withOrientation returns a copy of the EXIF data with the Orientation tag set to the value.
+func (e *exif) withOrientation(orientation uint16) []byte {
+	out := append([]byte{}, e.raw...)
+	entry, ok := findExifEntry(e.ifd0, exifTagOrientation)
+	if ok && entry.kind == 3 && entry.count == 1 {
+		e.order.PutUint16(out[entry.offset:entry.offset+2], orientation)
+	}
+	return out
+}
+
+func findExifEntry(entries []exifEntry, tag uint16) (exifEntry, bool) {
+	for _, entry := range entries {
+		if entry.tag == tag {
+			return entry, true
+		}
+	}
+	return exifEntry{}, false
+}
+
+// orientation returns the value of the Orientation tag, 1 if it is missing.
+func (e *exif) orientation() int {
+	entry, ok := findExifEntry(e.ifd0, exifTagOrientation)
+	if !ok || entry.kind != 3 || entry.count != 1 {
+		return 1
+	}
+
+	value := int(e.order.Uint16(e.raw[entry.offset : entry.offset+2]))
+	if value < 1 || value > 8 {
+		return 1
+	}
+	return value
+}
+
+// ascii returns the value of the text tag of the directory without the trailing NUL and spaces.
+func (e *exif) ascii(entries []exifEntry, tag uint16) string {
+	entry, ok := findExifEntry(entries, tag)
+	if !ok || entry.kind != 2 {
+		return ""
+	}
+	return strings.TrimRight(string(e.value(entry)), "\x00 ")
+}
+
+// subIFD reads the image file directory the pointer tag of the first directory refers to.
+func (e *exif) subIFD(tag uint16) []exifEntry {
+	entry, ok := findExifEntry(e.ifd0, tag)
+	if !ok || entry.kind != 4 || entry.count != 1 {
+		return nil
+	}
+	return e.readIFD(int(e.order.Uint32(e.raw[entry.offset : entry.offset+4])))
+}
+
+// ReadOrientation reads the EXIF orientation of the JPEG file, 1 is returned when it is not set.
+func ReadOrientation(data []byte) int {
+	e, ok := parseExif(jpegExif(data))
+	if !ok {
+		return 1
+	}
+	return e.orientation()
+}
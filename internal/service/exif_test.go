@@ -0,0 +1,162 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testExifEntry is an entry of the first image file directory written by newExif.
+type testExifEntry struct {
+	tag   uint16
+	kind  uint16
+	count uint32
+	value []byte
+}
+
+// newExif builds the TIFF structure with a single image file directory, the values longer than 4 bytes follow it.
+func newExif(order binary.ByteOrder, entries ...testExifEntry) []byte {
+	out := make([]byte, 8)
+	if order == binary.LittleEndian {
+		copy(out, "II")
+	} else {
+		copy(out, "MM")
+	}
+	order.PutUint16(out[2:4], 42)
+	order.PutUint32(out[4:8], 8)
+	return appendTestIFD(order, out, entries...)
+}
+
+// appendTestIFD writes the image file directory at the end of the data, the values longer than 4 bytes follow it.
+func appendTestIFD(order binary.ByteOrder, out []byte, entries ...testExifEntry) []byte {
+	offset := len(out)
+	out = append(out, make([]byte, 2+len(entries)*12+4)...)
+	order.PutUint16(out[offset:offset+2], uint16(len(entries)))
+
+	for i, entry := range entries {
+		start := offset + 2 + i*12
+		order.PutUint16(out[start:start+2], entry.tag)
+		order.PutUint16(out[start+2:start+4], entry.kind)
+		order.PutUint32(out[start+4:start+8], entry.count)
+		if len(entry.value) <= 4 {
+			copy(out[start+8:start+12], entry.value)
+			continue
+		}
+		order.PutUint32(out[start+8:start+12], uint32(len(out)))
+		out = append(out, entry.value...)
+	}
+	return out
+}
+
+// orientationEntry is the Orientation tag stored as a single SHORT.
+func orientationEntry(order binary.ByteOrder, value uint16) testExifEntry {
+	v := make([]byte, 2)
+	order.PutUint16(v, value)
+	return testExifEntry{tag: exifTagOrientation, kind: 3, count: 1, value: v}
+}
+
+// asciiEntry is the text tag stored with the trailing NUL.
+func asciiEntry(tag uint16, value string) testExifEntry {
+	return testExifEntry{tag: tag, kind: 2, count: uint32(len(value) + 1), value: append([]byte(value), 0)}
+}
+
+// newJPEG encodes the image as JPEG with the EXIF data in an APP1 segment right after the start of image.
+func newJPEG(t *testing.T, img image.Image, exif []byte) []byte {
+	t.Helper()
+
+	var encoded bytes.Buffer
+	require.NoError(t, jpeg.Encode(&encoded, img, nil))
+	if exif == nil {
+		return encoded.Bytes()
+	}
+
+	var segment bytes.Buffer
+	writeJPEGSegment(&segment, 0xE1, exifHeader, exif)
+	return append(append(append([]byte{}, encoded.Bytes()[:2]...), segment.Bytes()...), encoded.Bytes()[2:]...)
+}
+
+func TestReadOrientation(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 8, 8))
+
+	tests := []struct {
+		name string
+		exif []byte
+		want int
+	}{
+		{
+			name: "Little endian",
+			exif: newExif(binary.LittleEndian, asciiEntry(exifTagMake, "Camera"), orientationEntry(binary.LittleEndian, 6)),
+			want: 6,
+		},
+		{
+			name: "Big endian",
+			exif: newExif(binary.BigEndian, orientationEntry(binary.BigEndian, 8)),
+			want: 8,
+		},
+		{
+			name: "Missing tag",
+			exif: newExif(binary.LittleEndian, asciiEntry(exifTagMake, "Camera")),
+			want: 1,
+		},
+		{
+			name: "Value out of range",
+			exif: newExif(binary.LittleEndian, orientationEntry(binary.LittleEndian, 9)),
+			want: 1,
+		},
+		{
+			name: "Value of the wrong type",
+			exif: newExif(binary.LittleEndian, testExifEntry{tag: exifTagOrientation, kind: 4, count: 1, value: []byte{6, 0, 0, 0}}),
+			want: 1,
+		},
+		{
+			name: "Broken TIFF header",
+			exif: []byte("II\x2b\x00\x08\x00\x00\x00"),
+			want: 1,
+		},
+		{
+			name: "Directory out of the data",
+			exif: []byte("II\x2a\x00\xff\x00\x00\x00"),
+			want: 1,
+		},
+		{
+			name: "No EXIF",
+			want: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, ReadOrientation(newJPEG(t, img, tt.exif)))
+		})
+	}
+}
+
+func TestDecodeImageWithMetadata_AutoOrient(t *testing.T) {
+	data := newJPEG(t, image.NewGray(image.Rect(0, 0, 40, 20)), newExif(binary.BigEndian, orientationEntry(binary.BigEndian, 6)))
+
+	tests := []struct {
+		name            string
+		autoOrient      bool
+		size            image.Point
+		wantOrientation int
+	}{
+		{name: "Rotated and marked upright", autoOrient: true, size: image.Pt(20, 40), wantOrientation: 1},
+		{name: "Left as it is stored", autoOrient: false, size: image.Pt(40, 20), wantOrientation: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, _, metadata, err := DecodeImageWithMetadata(bytes.NewReader(data), WithAutoOrient(tt.autoOrient))
+			require.NoError(t, err)
+			require.Equal(t, tt.size, img.Bounds().Size())
+
+			e, ok := parseExif(metadata.Exif)
+			require.True(t, ok)
+			require.Equal(t, tt.wantOrientation, e.orientation())
+		})
+	}
+}
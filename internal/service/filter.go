@@ -0,0 +1,285 @@
+package service
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/alisavch/image-service/internal/models"
+	"github.com/alisavch/image-service/internal/utils"
+)
+
+const (
+	// DefaultSharpenRadius is the radius of the unsharp mask when it is not set.
+	DefaultSharpenRadius = 1.0
+	// MaxFilterRadius is the largest radius of the blur and the unsharp mask.
+	MaxFilterRadius = 50.0
+)
+
+// ApplyFilters applies the adjustments to the image in order.
+// Animations keep their palettes, the colors of the palettes are adjusted and the frames are blurred one by one.
+func ApplyFilters(imgSrc image.Image, adjustments []models.Adjustment) (image.Image, error) {
+	m := imgSrc
+	for _, adj := range adjustments {
+		if err := ValidateAdjustment(adj); err != nil {
+			return nil, err
+		}
+
+		switch adj.Filter {
+		case models.GrayscaleFilter:
+			m = Grayscale(m)
+		case models.SepiaFilter:
+			m = mapColors(m, sepia)
+		case models.BrightnessFilter:
+			m = mapColors(m, brightness(adj.Value))
+		case models.ContrastFilter:
+			m = mapColors(m, contrast(adj.Value))
+		case models.GammaFilter:
+			m = mapColors(m, gamma(adj.Value))
+		case models.SaturationFilter:
+			m = mapColors(m, saturation(adj.Value))
+		case models.BlurFilter:
+			m = mapFrames(m, func(img *image.RGBA) *image.RGBA { return gaussianBlur(img, adj.Radius) })
+		case models.SharpenFilter:
+			radius := adj.Radius
+			if radius == 0 {
+				radius = DefaultSharpenRadius
+			}
+			m = mapFrames(m, func(img *image.RGBA) *image.RGBA { return unsharpMask(img, adj.Value, radius) })
+		}
+	}
+
+	return m, nil
+}
+
+// ValidateAdjustment checks the name and the value of the adjustment.
+func ValidateAdjustment(adj models.Adjustment) error {
+	var ok bool
+	switch adj.Filter {
+	case models.GrayscaleFilter, models.SepiaFilter:
+		ok = true
+	case models.BrightnessFilter, models.ContrastFilter, models.SaturationFilter:
+		ok = adj.Value >= -100 && adj.Value <= 100
+	case models.GammaFilter:
+		ok = adj.Value > 0 && adj.Value <= 10
+	case models.BlurFilter:
+		ok = adj.Radius > 0 && adj.Radius <= MaxFilterRadius
+	case models.SharpenFilter:
+		ok = adj.Value > 0 && adj.Value <= 10 && adj.Radius >= 0 && adj.Radius <= MaxFilterRadius
+	default:
+		return utils.ErrFilter
+	}
+
+	if !ok {
+		return fmt.Errorf("%s: %w", adj.Filter, utils.ErrFilterValue)
+	}
+	return nil
+}
+
+// Grayscale removes the colors of the image and keeps its transparency,
+// animations keep their palettes with the colors replaced by grays.
+func Grayscale(imgSrc image.Image) image.Image {
+	return mapColors(imgSrc, func(c color.NRGBA) color.NRGBA {
+		y := color.GrayModel.Convert(color.NRGBA{R: c.R, G: c.G, B: c.B, A: 0xff}).(color.Gray).Y
+		return color.NRGBA{R: y, G: y, B: y, A: c.A}
+	})
+}
+
+// mapColors changes every color of the image with the function.
+func mapColors(imgSrc image.Image, fn func(color.NRGBA) color.NRGBA) image.Image {
+	if anim, ok := imgSrc.(*Animation); ok {
+		mapped := *anim.GIF
+		mapped.Image = make([]*image.Paletted, len(anim.Image))
+		for i, frame := range anim.Image {
+			palette := make(color.Palette, len(frame.Palette))
+			for j, c := range frame.Palette {
+				palette[j] = fn(color.NRGBAModel.Convert(c).(color.NRGBA))
+			}
+			m := *frame
+			m.Palette = palette
+			mapped.Image[i] = &m
+		}
+		return &Animation{GIF: &mapped}
+	}
+
+	bounds := imgSrc.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), imgSrc, bounds.Min, draw.Src)
+
+	for i := 0; i+4 <= len(dst.Pix); i += 4 {
+		c := fn(color.NRGBA{R: dst.Pix[i], G: dst.Pix[i+1], B: dst.Pix[i+2], A: dst.Pix[i+3]})
+		dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+
+	return dst
+}
+
+// mapFrames changes the pixels of the image with the function, the frames of animations are mapped back to their palettes.
+func mapFrames(imgSrc image.Image, fn func(*image.RGBA) *image.RGBA) image.Image {
+	if anim, ok := imgSrc.(*Animation); ok {
+		mapped := *anim.GIF
+		mapped.Image = make([]*image.Paletted, len(anim.Image))
+		for i, frame := range anim.Image {
+			m := fn(toRGBA(frame))
+			dst := image.NewPaletted(frame.Bounds(), frame.Palette)
+			draw.Draw(dst, dst.Bounds(), m, image.Point{}, draw.Src)
+			mapped.Image[i] = dst
+		}
+		return &Animation{GIF: &mapped}
+	}
+
+	return fn(toRGBA(imgSrc))
+}
+
+func toRGBA(imgSrc image.Image) *image.RGBA {
+	bounds := imgSrc.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), imgSrc, bounds.Min, draw.Src)
+	return dst
+}
+
+func clamp(v float64) uint8 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 255:
+		return 255
+	}
+	return uint8(math.Round(v))
+}
+
+func sepia(c color.NRGBA) color.NRGBA {
+	r, g, b := float64(c.R), float64(c.G), float64(c.B)
+	return color.NRGBA{
+		R: clamp(0.393*r + 0.769*g + 0.189*b),
+		G: clamp(0.349*r + 0.686*g + 0.168*b),
+		B: clamp(0.272*r + 0.534*g + 0.131*b),
+		A: c.A,
+	}
+}
+
+// lookup builds the function that changes every channel with the table.
+func lookup(fn func(v float64) float64) func(color.NRGBA) color.NRGBA {
+	var table [256]uint8
+	for i := range table {
+		table[i] = clamp(fn(float64(i)))
+	}
+	return func(c color.NRGBA) color.NRGBA {
+		return color.NRGBA{R: table[c.R], G: table[c.G], B: table[c.B], A: c.A}
+	}
+}
+
+func brightness(percent float64) func(color.NRGBA) color.NRGBA {
+	shift := 255 * percent / 100
+	return lookup(func(v float64) float64 { return v + shift })
+}
+
+func contrast(percent float64) func(color.NRGBA) color.NRGBA {
+	factor := 1 + percent/100
+	return lookup(func(v float64) float64 { return (v-127.5)*factor + 127.5 })
+}
+
+func gamma(value float64) func(color.NRGBA) color.NRGBA {
+	return lookup(func(v float64) float64 { return 255 * math.Pow(v/255, 1/value) })
+}
+
+func saturation(percent float64) func(color.NRGBA) color.NRGBA {
+	factor := 1 + percent/100
+	return func(c color.NRGBA) color.NRGBA {
+		r, g, b := float64(c.R), float64(c.G), float64(c.B)
+		l := 0.299*r + 0.587*g + 0.114*b
+		return color.NRGBA{
+			R: clamp(l + (r-l)*factor),
+			G: clamp(l + (g-l)*factor),
+			B: clamp(l + (b-l)*factor),
+			A: c.A,
+		}
+	}
+}
+
+// gaussianKernel builds the normalized kernel with the standard deviation equal to the radius.
+func gaussianKernel(radius float64) []float64 {
+	size := int(math.Ceil(radius * 3))
+	kernel := make([]float64, 2*size+1)
+
+	var sum float64
+	for i := range kernel {
+		x := float64(i - size)
+		kernel[i] = math.Exp(-x * x / (2 * radius * radius))
+		sum += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+
+	return kernel
+}
+
+// gaussianBlur blurs the premultiplied image with two passes of the one-dimensional kernel.
+func gaussianBlur(img *image.RGBA, radius float64) *image.RGBA {
+	kernel := gaussianKernel(radius)
+	return convolve(convolve(img, kernel, true), kernel, false)
+}
+
+func convolve(img *image.RGBA, kernel []float64, horizontal bool) *image.RGBA {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	dst := image.NewRGBA(img.Rect)
+	half := len(kernel) / 2
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var sum [4]float64
+			for k, weight := range kernel {
+				sx, sy := x, y
+				if horizontal {
+					sx = clampInt(x+k-half, 0, w-1)
+				} else {
+					sy = clampInt(y+k-half, 0, h-1)
+				}
+				i := img.PixOffset(sx, sy)
+				for c := 0; c < 4; c++ {
+					sum[c] += float64(img.Pix[i+c]) * weight
+				}
+			}
+			i := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				dst.Pix[i+c] = clamp(sum[c])
+			}
+		}
+	}
+
+	return dst
+}
+
+// unsharpMask adds the difference between the image and its blurred copy multiplied by the amount.
+func unsharpMask(img *image.RGBA, amount, radius float64) *image.RGBA {
+	blurred := gaussianBlur(img, radius)
+	dst := image.NewRGBA(img.Rect)
+
+	for i := 0; i+4 <= len(img.Pix); i += 4 {
+		alpha := img.Pix[i+3]
+		for c := 0; c < 3; c++ {
+			v := float64(img.Pix[i+c])
+			sharpened := clamp(v + amount*(v-float64(blurred.Pix[i+c])))
+			if sharpened > alpha {
+				sharpened = alpha
+			}
+			dst.Pix[i+c] = sharpened
+		}
+		dst.Pix[i+3] = alpha
+	}
+
+	return dst
+}
+
+func clampInt(v, low, high int) int {
+	switch {
+	case v < low:
+		return low
+	case v > high:
+		return high
+	}
+	return v
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+
+	"github.com/alisavch/image-service/internal/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGrayscale(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{R: 255, A: 255})
+	img.SetNRGBA(1, 0, color.NRGBA{R: 255, G: 255, B: 255, A: 0})
+
+	frame := image.NewPaletted(image.Rect(0, 0, 2, 1), color.Palette{color.NRGBA{R: 255, A: 255}, color.NRGBA{}})
+	frame.SetColorIndex(1, 0, 1)
+	anim := &Animation{GIF: &gif.GIF{Image: []*image.Paletted{frame}, Delay: []int{0}}}
+
+	tests := []struct {
+		name string
+		img  image.Image
+	}{
+		{name: "Still image", img: img},
+		{name: "Animation", img: anim},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, gray := range []func(image.Image) image.Image{
+				Grayscale,
+				func(m image.Image) image.Image {
+					filtered, err := ApplyFilters(m, []models.Adjustment{{Filter: models.GrayscaleFilter}})
+					require.NoError(t, err)
+					return filtered
+				},
+			} {
+				m := gray(tt.img)
+				if anim, ok := m.(*Animation); ok {
+					m = anim.Image[0]
+				}
+
+				opaque := color.NRGBAModel.Convert(m.At(0, 0)).(color.NRGBA)
+				require.Equal(t, uint8(255), opaque.A)
+				require.Equal(t, opaque.R, opaque.G)
+				require.Equal(t, opaque.G, opaque.B)
+				require.Less(t, opaque.R, uint8(255))
+
+				_, _, _, a := m.At(1, 0).RGBA()
+				require.Zero(t, a, "transparent pixel became opaque")
+			}
+		})
+	}
+}
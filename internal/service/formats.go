@@ -0,0 +1,265 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/alisavch/image-service/internal/utils"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+	"golang.org/x/image/webp"
+)
+
+// Format describes an image format known to the service.
+type Format struct {
+	// Name is the name used in requests and returned by image.Decode.
+	Name string
+	// Extensions lists the file extensions of the format, the first one is used for the resulting images.
+	Extensions []string
+	// ContentType is the MIME type of the format.
+	ContentType string
+	// Encode writes the image in the format, it is nil if the format can only be read.
+	Encode func(w io.Writer, imgSrc image.Image, opts ...EncodeOption) error
+	// Decode reads the image in the format.
+	Decode func(r io.Reader) (image.Image, error)
+	// Magic lists the signatures the files of the format start with, '?' matches any byte.
+	Magic []string
+	// End reads the image structure up to its end, it is nil if the format does not define it.
+	// It reports false when the structure is not recognized.
+	// TIFF has none: its directories and strips are found by offsets that can point anywhere in the file,
+	// so the end of the image is not known while the file is streamed, and data after it is not detected.
+	End func(r *bufio.Reader) bool
+}
+
+// CanEncode reports whether the format can be used as a target.
+func (f Format) CanEncode() bool {
+	return f.Encode != nil
+}
+
+// Extension returns the extension used for the resulting images.
+func (f Format) Extension() string {
+	return f.Extensions[0]
+}
+
+var formats = map[string]Format{}
+
+func init() {
+	RegisterFormat(Format{
+		Name:        "jpeg",
+		Extensions:  []string{"jpeg", "jpg"},
+		ContentType: "image/jpeg",
+		Encode:      ConvertToJPEG,
+		Decode:      jpeg.Decode,
+		Magic:       []string{"\xff\xd8\xff"},
+		End:         jpegEnd,
+	})
+	RegisterFormat(Format{
+		Name:        "png",
+		Extensions:  []string{"png"},
+		ContentType: "image/png",
+		Encode:      ConvertToPNG,
+		Decode:      png.Decode,
+		Magic:       []string{"\x89PNG\r\n\x1a\n"},
+		End:         pngEnd,
+	})
+	RegisterFormat(Format{
+		Name:        "gif",
+		Extensions:  []string{"gif"},
+		ContentType: "image/gif",
+		Encode:      ConvertToGIF,
+		Decode:      DecodeGIF,
+		Magic:       []string{"GIF87a", "GIF89a"},
+		End:         gifEnd,
+	})
+	RegisterFormat(Format{
+		Name:        "bmp",
+		Extensions:  []string{"bmp"},
+		ContentType: "image/bmp",
+		Encode:      ConvertToBMP,
+		Decode:      bmp.Decode,
+		Magic:       []string{"BM"},
+		End:         bmpEnd,
+	})
+	RegisterFormat(Format{
+		Name:        "tiff",
+		Extensions:  []string{"tiff", "tif"},
+		ContentType: "image/tiff",
+		Encode:      ConvertToTIFF,
+		Decode:      tiff.Decode,
+		Magic:       []string{"II*\x00", "MM\x00*"},
+	})
+	RegisterFormat(Format{
+		Name:        "webp",
+		Extensions:  []string{"webp"},
+		ContentType: "image/webp",
+		Decode:      webp.Decode,
+		Magic:       []string{"RIFF????WEBP"},
+		End:         riffEnd,
+	})
+}
+
+// FallbackFormat is the format of the resulting images when the source format can only be read.
+const FallbackFormat = "png"
+
+// RegisterFormat adds the format to the registry, it can be found by its name and any of its extensions.
+func RegisterFormat(f Format) {
+	formats[f.Name] = f
+	for _, ext := range f.Extensions {
+		formats[ext] = f
+	}
+}
+
+// LookupFormat finds the format by its name or extension.
+func LookupFormat(name string) (Format, error) {
+	f, ok := formats[strings.ToLower(strings.TrimPrefix(name, "."))]
+	if !ok {
+		return Format{}, utils.ErrUnsupportedFormat
+	}
+	return f, nil
+}
+
+// LookupContentType finds the format by its MIME type.
+func LookupContentType(contentType string) (Format, error) {
+	for _, f := range formats {
+		if f.ContentType == contentType {
+			return f, nil
+		}
+	}
+	return Format{}, utils.ErrUnsupportedFormat
+}
+
+// LookupTargetFormat finds the format that images can be converted to.
+func LookupTargetFormat(name string) (Format, error) {
+	f, err := LookupFormat(name)
+	if err != nil || !f.CanEncode() {
+		return Format{}, utils.ErrUnsupportedTargetFormat
+	}
+	return f, nil
+}
+
+// TargetFormats lists the names of the formats that images can be converted to.
+func TargetFormats() []string {
+	var names []string
+	for key, f := range formats {
+		if key == f.Name && f.CanEncode() {
+			names = append(names, f.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OutputFormat returns the format the image is written in when its source format should be kept.
+func OutputFormat(source string) string {
+	if f, err := LookupFormat(source); err == nil && f.CanEncode() {
+		return f.Name
+	}
+	return FallbackFormat
+}
+
+// EncodeImage writes the image in the format.
+func EncodeImage(w io.Writer, imgSrc image.Image, format string, opts ...EncodeOption) error {
+	f, err := LookupTargetFormat(format)
+	if err != nil {
+		return err
+	}
+	return f.Encode(w, imgSrc, opts...)
+}
+
+// DecodeConfig contains optional decoding parameters.
+type DecodeConfig struct {
+	autoOrient bool
+	limits     utils.LimitsConfig
+}
+
+// DecodeOption sets an optional parameter for the Decode functions.
+type DecodeOption func(config *DecodeConfig)
+
+// WithAutoOrient rotates and flips the decoded image according to its EXIF orientation.
+func WithAutoOrient(enabled bool) DecodeOption {
+	return func(config *DecodeConfig) {
+		config.autoOrient = enabled
+	}
+}
+
+// WithLimits rejects the images that exceed the limits before their pixels are decoded.
+func WithLimits(limits utils.LimitsConfig) DecodeOption {
+	return func(config *DecodeConfig) {
+		config.limits = limits
+	}
+}
+
+// DecodeImage reads the image with the decoder of its format, so animations keep all their frames.
+func DecodeImage(r io.Reader, opts ...DecodeOption) (image.Image, string, error) {
+	img, name, _, err := DecodeImageWithMetadata(r, opts...)
+	return img, name, err
+}
+
+// DecodeImageWithMetadata reads the image together with its EXIF, ICC and XMP metadata.
+func DecodeImageWithMetadata(r io.Reader, opts ...DecodeOption) (image.Image, string, Metadata, error) {
+	var cfg DecodeConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	// Only one byte over the size limit is read into memory, the rest of a larger file is just counted.
+	src := r
+	if cfg.limits.MaxFileSize > 0 {
+		src = io.LimitReader(r, cfg.limits.MaxFileSize+1)
+	}
+	data, err := ioutil.ReadAll(src)
+	if err != nil {
+		return nil, "", Metadata{}, err
+	}
+
+	size := int64(len(data))
+	if cfg.limits.MaxFileSize > 0 && size > cfg.limits.MaxFileSize {
+		rest, err := io.Copy(ioutil.Discard, r)
+		if err != nil {
+			return nil, "", Metadata{}, err
+		}
+		size += rest
+	}
+	if err := CheckFileSize(size, cfg.limits); err != nil {
+		return nil, "", Metadata{}, err
+	}
+
+	config, name, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", Metadata{}, err
+	}
+	if err := checkDimensions(config, cfg.limits); err != nil {
+		return nil, "", Metadata{}, err
+	}
+	if name == "gif" {
+		if err := checkFrames(config, countGIFFrames(data), cfg.limits); err != nil {
+			return nil, "", Metadata{}, err
+		}
+	}
+
+	f, err := LookupFormat(name)
+	if err != nil {
+		return nil, "", Metadata{}, err
+	}
+
+	img, err := f.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", Metadata{}, err
+	}
+
+	metadata := ReadMetadata(data)
+	if cfg.autoOrient && f.Name == "jpeg" {
+		img = ApplyOrientation(img, ReadOrientation(data))
+		metadata = metadata.ResetOrientation()
+	}
+
+	return img, f.Name, metadata, nil
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"runtime"
+	"testing"
+
+	"github.com/alisavch/image-service/internal/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+// zeroReader streams zeros.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func TestDecodeImageWithMetadata_FileSizeLimit(t *testing.T) {
+	const size = 256 << 20
+	limits := utils.LimitsConfig{MaxFileSize: 1 << 20}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	_, _, _, err := DecodeImageWithMetadata(io.LimitReader(zeroReader{}, size), WithLimits(limits))
+
+	runtime.ReadMemStats(&after)
+	require.True(t, errors.Is(err, utils.ErrImageTooLarge), err)
+	require.EqualError(t, err, "image is too large: file size 268435456 exceeds the limit of 1048576")
+	require.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(16<<20))
+}
+
+func TestDecodeImageWithMetadata_WithinLimits(t *testing.T) {
+	content := &bytes.Buffer{}
+	require.NoError(t, png.Encode(content, image.NewGray(image.Rect(0, 0, 8, 4))))
+
+	img, format, _, err := DecodeImageWithMetadata(bytes.NewReader(content.Bytes()), WithLimits(utils.LimitsConfig{MaxFileSize: int64(content.Len())}))
+	require.NoError(t, err)
+	require.Equal(t, "png", format)
+	require.Equal(t, image.Pt(8, 4), img.Bounds().Size())
+
+	_, _, _, err = DecodeImageWithMetadata(bytes.NewReader(content.Bytes()), WithLimits(utils.LimitsConfig{MaxWidth: 4}))
+	require.True(t, errors.Is(err, utils.ErrImageTooLarge), err)
+}
+
+func TestDecodeImageWithMetadata_AnimationFrames(t *testing.T) {
+	data := newAnimation(t)
+	require.Equal(t, 2, countGIFFrames(data))
+
+	// Every frame is within the limit, the two frames together are not.
+	_, _, _, err := DecodeImageWithMetadata(bytes.NewReader(data), WithLimits(utils.LimitsConfig{MaxMegapixels: 0.001}))
+	require.True(t, errors.Is(err, utils.ErrImageTooLarge), err)
+	require.EqualError(t, err, "image is too large: megapixels of all frames 0.0016 exceeds the limit of 0.001")
+
+	img, format, _, err := DecodeImageWithMetadata(bytes.NewReader(data), WithLimits(utils.LimitsConfig{MaxMegapixels: 0.002}))
+	require.NoError(t, err)
+	require.Equal(t, "gif", format)
+	require.IsType(t, &Animation{}, img)
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"math"
+
+	"github.com/alisavch/image-service/internal/models"
+
+	"github.com/nfnt/resize"
+)
+
+// Animation is an animated GIF that can be passed around as a single image.
+//
+// It looks like its first frame to the code that does not know about animations.
+type Animation struct {
+	*gif.GIF
+}
+
+// ColorModel returns the color model of the first frame.
+func (a *Animation) ColorModel() color.Model {
+	return a.Image[0].ColorModel()
+}
+
+// Bounds returns the logical screen of the animation.
+func (a *Animation) Bounds() image.Rectangle {
+	return image.Rect(0, 0, a.Config.Width, a.Config.Height)
+}
+
+// At returns the color of the pixel of the first frame.
+func (a *Animation) At(x, y int) color.Color {
+	return a.Image[0].At(x, y)
+}
+
+// DecodeGIF reads a GIF image, an animation is returned when it has more than one frame.
+func DecodeGIF(r io.Reader) (image.Image, error) {
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(g.Image) == 1 && g.Config.Width == g.Image[0].Bounds().Dx() && g.Config.Height == g.Image[0].Bounds().Dy() {
+		return g.Image[0], nil
+	}
+
+	return &Animation{GIF: g}, nil
+}
+
+// ConvertToGIF converts to GIF keeping every frame of an animation.
+func ConvertToGIF(w io.Writer, imgSrc image.Image, _ ...EncodeOption) error {
+	if anim, ok := imgSrc.(*Animation); ok {
+		return gif.EncodeAll(w, anim.GIF)
+	}
+
+	return gif.Encode(w, imgSrc, nil)
+}
+
+// resizeAnimation resizes every frame of the animation keeping delays, disposal methods and loop count.
+func resizeAnimation(anim *Animation, geom geometry, opts models.Resize) (*Animation, error) {
+	src := anim.Bounds().Size()
+	scaleX := float64(geom.scaled.X) / float64(src.X)
+	scaleY := float64(geom.scaled.Y) / float64(src.Y)
+	canvas := image.Rectangle{Max: geom.canvas}
+
+	resized := *anim.GIF
+	resized.Image = make([]*image.Paletted, len(anim.Image))
+	resized.Config.Width, resized.Config.Height = geom.canvas.X, geom.canvas.Y
+
+	for i, frame := range anim.Image {
+		bounds := frame.Bounds()
+		rect := image.Rect(
+			int(math.Round(float64(bounds.Min.X)*scaleX)), int(math.Round(float64(bounds.Min.Y)*scaleY)),
+			int(math.Round(float64(bounds.Max.X)*scaleX)), int(math.Round(float64(bounds.Max.Y)*scaleY)),
+		).Add(geom.offset)
+		if rect.Dx() == 0 {
+			rect.Max.X++
+		}
+		if rect.Dy() == 0 {
+			rect.Max.Y++
+		}
+		m := resize.Resize(uint(rect.Dx()), uint(rect.Dy()), frame, resize.Bilinear)
+
+		if i == 0 && opts.Mode == models.Pad {
+			padded, err := padFrame(frame.Palette, canvas, opts.Background)
+			if err != nil {
+				return nil, err
+			}
+			draw.Draw(padded, rect, m, m.Bounds().Min, draw.Over)
+			resized.Image[i] = padded
+			continue
+		}
+
+		visible := rect.Intersect(canvas)
+		if visible.Empty() {
+			visible = image.Rect(0, 0, 1, 1)
+		}
+		dst := image.NewPaletted(visible, frame.Palette)
+		draw.Draw(dst, rect, m, m.Bounds().Min, draw.Src)
+		resized.Image[i] = dst
+	}
+
+	return &Animation{GIF: &resized}, nil
+}
+
+// padFrame creates a frame of the whole canvas filled with the background color.
+func padFrame(p color.Palette, canvas image.Rectangle, background string) (*image.Paletted, error) {
+	bg, err := ParseHexColor(background)
+	if err != nil {
+		return nil, err
+	}
+
+	palette := append(color.Palette{}, p...)
+	if len(palette) < 256 {
+		palette = append(palette, bg)
+	}
+
+	frame := image.NewPaletted(canvas, palette)
+	index := uint8(palette.Index(bg))
+	for i := range frame.Pix {
+		frame.Pix[i] = index
+	}
+
+	return frame, nil
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+
+	"github.com/alisavch/image-service/internal/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newAnimation encodes a 40x20 animation: a red first frame over the whole screen and a blue second frame
+// that only covers the bottom right quarter.
+func newAnimation(t *testing.T) []byte {
+	t.Helper()
+
+	p := color.Palette{color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}, color.RGBA{R: 255, G: 255, B: 255, A: 255}}
+	first := image.NewPaletted(image.Rect(0, 0, 40, 20), p)
+	second := image.NewPaletted(image.Rect(20, 10, 40, 20), p)
+	for i := range second.Pix {
+		second.Pix[i] = 1
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, gif.EncodeAll(&buf, &gif.GIF{
+		Image:     []*image.Paletted{first, second},
+		Delay:     []int{10, 20},
+		Disposal:  []byte{gif.DisposalNone, gif.DisposalBackground},
+		LoopCount: 3,
+		Config:    image.Config{ColorModel: p, Width: 40, Height: 20},
+	}))
+	return buf.Bytes()
+}
+
+func TestDecodeGIF(t *testing.T) {
+	img, err := DecodeGIF(bytes.NewReader(newAnimation(t)))
+	require.NoError(t, err)
+	anim, ok := img.(*Animation)
+	require.True(t, ok)
+	require.Len(t, anim.Image, 2)
+	require.Equal(t, image.Rect(0, 0, 40, 20), anim.Bounds())
+
+	var still bytes.Buffer
+	require.NoError(t, gif.Encode(&still, image.NewPaletted(image.Rect(0, 0, 4, 4), color.Palette{color.Black}), nil))
+	img, err = DecodeGIF(&still)
+	require.NoError(t, err)
+	require.IsType(t, &image.Paletted{}, img)
+}
+
+func TestResizeImage_Animation(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         models.Resize
+		screen       image.Rectangle
+		firstBounds  image.Rectangle
+		secondBounds image.Rectangle
+	}{
+		{
+			name:         "Fit",
+			opts:         models.Resize{Width: 20, Mode: models.Fit},
+			screen:       image.Rect(0, 0, 20, 10),
+			firstBounds:  image.Rect(0, 0, 20, 10),
+			secondBounds: image.Rect(10, 5, 20, 10),
+		},
+		{
+			name:         "Pad",
+			opts:         models.Resize{Width: 20, Height: 20, Mode: models.Pad, Background: "ffffff"},
+			screen:       image.Rect(0, 0, 20, 20),
+			firstBounds:  image.Rect(0, 0, 20, 20),
+			secondBounds: image.Rect(10, 10, 20, 15),
+		},
+		{
+			name:         "Fill",
+			opts:         models.Resize{Width: 10, Height: 10, Mode: models.Fill, Gravity: models.Center},
+			screen:       image.Rect(0, 0, 10, 10),
+			firstBounds:  image.Rect(0, 0, 10, 10),
+			secondBounds: image.Rect(5, 5, 10, 10),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := DecodeGIF(bytes.NewReader(newAnimation(t)))
+			require.NoError(t, err)
+
+			resized, err := ResizeImage(img, tt.opts)
+			require.NoError(t, err)
+			anim, ok := resized.(*Animation)
+			require.True(t, ok)
+			require.Equal(t, tt.screen, anim.Bounds())
+			require.Equal(t, tt.firstBounds, anim.Image[0].Bounds())
+			require.Equal(t, tt.secondBounds, anim.Image[1].Bounds())
+
+			// The frames are encoded again with their timing and the loop count.
+			var buf bytes.Buffer
+			require.NoError(t, ConvertToGIF(&buf, anim))
+			decoded, err := gif.DecodeAll(&buf)
+			require.NoError(t, err)
+			require.Len(t, decoded.Image, 2)
+			require.Equal(t, []int{10, 20}, decoded.Delay)
+			require.Equal(t, []byte{gif.DisposalNone, gif.DisposalBackground}, decoded.Disposal)
+			require.Equal(t, 3, decoded.LoopCount)
+			require.Equal(t, color.RGBA{B: 255, A: 255}, color.RGBAModel.Convert(decoded.Image[1].At(tt.secondBounds.Max.X-1, tt.secondBounds.Max.Y-1)))
+		})
+	}
+}
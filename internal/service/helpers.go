@@ -1,26 +1,33 @@
 package service
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
 	"image"
+	"image/color"
 	"image/jpeg"
 	"image/png"
 	"io"
 	"net/http"
-	"os"
-	"path"
+	"strconv"
+	"strings"
 
 	"github.com/alisavch/image-service/internal/models"
 
 	"github.com/alisavch/image-service/internal/utils"
 
-	"github.com/nfnt/resize"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
 )
 
 // EncodeConfig contains image constants.
 type EncodeConfig struct {
 	jpegQuality         int
 	pngCompressionLevel int
+	pngColors           int
+	pngDither           bool
+	metadata            Metadata
 }
 
 var defaultEncodeConfig = EncodeConfig{
@@ -31,83 +38,126 @@ var defaultEncodeConfig = EncodeConfig{
 // EncodeOption sets an optional parameter for to Encode and Save functions.
 type EncodeOption func(config *EncodeConfig)
 
-// ConvertToPNG converts from JPEG to PNG.
-func ConvertToPNG(w io.Writer, imgSrc image.Image) error {
-	var enc png.Encoder
+// WithJPEGQuality sets the JPEG quality from 1 to 100.
+func WithJPEGQuality(quality int) EncodeOption {
+	return func(config *EncodeConfig) {
+		config.jpegQuality = quality
+	}
+}
 
-	return enc.Encode(w, imgSrc)
+// WithPNGCompressionLevel sets the PNG compression level from 0 (no compression) to 9 (best compression).
+func WithPNGCompressionLevel(level int) EncodeOption {
+	return func(config *EncodeConfig) {
+		config.pngCompressionLevel = level
+	}
 }
 
-// ConvertToJPEG convert from PNG to JPEG.
-func ConvertToJPEG(w io.Writer, imgSrc image.Image) error {
-	cfg := defaultEncodeConfig
+// WithPNGColors quantizes the PNG images to a palette of the number of colors, zero keeps the truecolor.
+func WithPNGColors(colors int, dither bool) EncodeOption {
+	return func(config *EncodeConfig) {
+		config.pngColors = colors
+		config.pngDither = dither
+	}
+}
 
-	return jpeg.Encode(w, imgSrc, &jpeg.Options{Quality: cfg.pngCompressionLevel})
+// WithMetadata writes the metadata into the JPEG and PNG images.
+func WithMetadata(metadata Metadata) EncodeOption {
+	return func(config *EncodeConfig) {
+		config.metadata = metadata
+	}
 }
 
-// CompressJPEG allows you to compress the JPEG image in width while maintaining the aspect ratio.
-func CompressJPEG(imgSrc image.Image, width int, newImgFile *os.File) error {
+func newEncodeConfig(opts ...EncodeOption) EncodeConfig {
 	cfg := defaultEncodeConfig
-	if width < 0 || width > imgSrc.Bounds().Max.X {
-		return utils.ErrIncorrectRatio
+	for _, opt := range opts {
+		opt(&cfg)
 	}
-
-	m := resize.Resize(uint(width), 0, imgSrc, resize.Lanczos3)
-
-	return jpeg.Encode(newImgFile, m, &jpeg.Options{Quality: cfg.jpegQuality})
+	return cfg
 }
 
-// CompressPNG allows you to compress the PNG image in width while maintaining the aspect ratio.
-func CompressPNG(imgSrc image.Image, width int, newImgFile *os.File) error {
-	if width < 0 || width > imgSrc.Bounds().Max.X {
-		return utils.ErrIncorrectRatio
+// pngCompression maps the zlib-like level to the levels supported by the png encoder.
+func (cfg EncodeConfig) pngCompression() png.CompressionLevel {
+	switch {
+	case cfg.pngCompressionLevel <= 0:
+		return png.NoCompression
+	case cfg.pngCompressionLevel <= 3:
+		return png.BestSpeed
+	case cfg.pngCompressionLevel >= 9:
+		return png.BestCompression
+	default:
+		return png.DefaultCompression
 	}
-
-	m := resize.Resize(uint(width), 0, imgSrc, resize.Lanczos3)
-
-	return png.Encode(newImgFile, m)
 }
 
-// EnsureBaseDir checks if a directory exists.
-func EnsureBaseDir(filepath string) error {
-	baseDir := path.Dir(filepath)
+// ConvertToPNG converts from JPEG to PNG.
+func ConvertToPNG(w io.Writer, imgSrc image.Image, opts ...EncodeOption) error {
+	cfg := newEncodeConfig(opts...)
+	enc := png.Encoder{CompressionLevel: cfg.pngCompression()}
+	if cfg.pngColors > 0 {
+		paletted, err := QuantizeImage(imgSrc, cfg.pngColors, cfg.pngDither)
+		if err != nil {
+			return err
+		}
+		imgSrc = paletted
+	}
+	if cfg.metadata.IsEmpty() {
+		return enc.Encode(w, imgSrc)
+	}
 
-	info, err := os.Stat(baseDir)
-	if err == nil && info.IsDir() {
-		return nil
+	var buf bytes.Buffer
+	if err := enc.Encode(&buf, imgSrc); err != nil {
+		return err
 	}
 
-	return os.MkdirAll(baseDir, 0755)
+	return writePNGMetadata(w, buf.Bytes(), cfg.metadata)
 }
 
-// GetFileContentType gets the content type of the file.
-func GetFileContentType(out *os.File) (string, error) {
-	buffer := make([]byte, 512)
+// ConvertToJPEG convert from PNG to JPEG.
+func ConvertToJPEG(w io.Writer, imgSrc image.Image, opts ...EncodeOption) error {
+	cfg := newEncodeConfig(opts...)
 
-	_, err := out.Read(buffer)
-	if err != nil {
-		return "", err
+	options := &jpeg.Options{Quality: cfg.jpegQuality}
+	if cfg.metadata.IsEmpty() {
+		return jpeg.Encode(w, imgSrc, options)
 	}
 
-	contentType := http.DetectContentType(buffer)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, imgSrc, options); err != nil {
+		return err
+	}
 
-	return contentType, nil
+	return writeJPEGMetadata(w, buf.Bytes(), cfg.metadata)
 }
 
-// GetFileSize gets the filesize of the file.
-func GetFileSize(file *os.File) (int64, error) {
-	fileInfo, err := file.Stat()
-	if err != nil {
-		return 0, utils.ErrFileStat
-	}
+// ConvertToBMP converts to BMP.
+func ConvertToBMP(w io.Writer, imgSrc image.Image, _ ...EncodeOption) error {
+	return bmp.Encode(w, imgSrc)
+}
 
-	size := fileInfo.Size()
+// ConvertToTIFF converts to TIFF compressed with deflate.
+func ConvertToTIFF(w io.Writer, imgSrc image.Image, _ ...EncodeOption) error {
+	return tiff.Encode(w, imgSrc, &tiff.Options{Compression: tiff.Deflate, Predictor: true})
+}
+
+// ParseHexColor parses colors in RGB, RRGGBB and RRGGBBAA hex notation with an optional leading '#'.
+func ParseHexColor(s string) (color.NRGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+	if s == "" {
+		s = DefaultBackground
+	}
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) == 6 {
+		s += "ff"
+	}
 
-	_, err = file.Seek(0, 0)
-	if err != nil {
-		return 0, err
+	value, err := strconv.ParseUint(s, 16, 32)
+	if err != nil || len(s) != 8 {
+		return color.NRGBA{}, utils.ErrBackgroundColor
 	}
-	return size, nil
+
+	return color.NRGBA{R: uint8(value >> 24), G: uint8(value >> 16), B: uint8(value >> 8), A: uint8(value)}, nil
 }
 
 // FillInTheReceivedNameAndLocation fills name and location
@@ -120,37 +170,31 @@ func FillInTheReceivedNameAndLocation(name, location string) models.Image {
 	return result
 }
 
-// FillInTheImage fills models.SavedImage.
-func FillInTheImage(img models.SavedImage, file *os.File) (models.SavedImage, error) {
-	var err error
-	img.File = file
-
-	img.ContentType, err = GetFileContentType(file)
-	if err != nil {
+// FillInTheImage fills models.SavedImage, the content type is detected from the beginning of the file.
+func FillInTheImage(img models.SavedImage, file io.ReadCloser) (models.SavedImage, error) {
+	r := bufio.NewReaderSize(file, 512)
+	head, err := r.Peek(512)
+	if err != nil && err != io.EOF {
 		return models.SavedImage{}, fmt.Errorf("%s:%s", utils.ErrGetContentType, err)
 	}
 
-	img.Filesize, err = GetFileSize(file)
-	if err != nil {
-		return models.SavedImage{}, err
-	}
+	img.ContentType = http.DetectContentType(head)
+	img.File = bufferedFile{Reader: r, Closer: file}
 
 	return img, nil
 }
 
-// FillInTheResultingImageLocally fills the resultedImage with information for local storage.
-func FillInTheResultingImageLocally(resultedName string, newImg *os.File) (models.Image, error) {
-	currentDir, err := os.Getwd()
-	if err != nil {
-		return models.Image{}, utils.ErrGetDir
-	}
+// bufferedFile reads the file through the reader its beginning was peeked with.
+type bufferedFile struct {
+	*bufio.Reader
+	io.Closer
+}
 
-	err = newImg.Close()
-	if err != nil {
-		return models.Image{}, err
+// EncodeResult encodes the result in memory, FillInTheResultingImage puts it into the storage.
+func EncodeResult(img image.Image, format string, opts ...EncodeOption) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+	if err := EncodeImage(&buf, img, format, opts...); err != nil {
+		return nil, err
 	}
-
-	result := FillInTheReceivedNameAndLocation(resultedName, currentDir+"/results/")
-
-	return result, nil
+	return &buf, nil
 }
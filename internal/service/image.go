@@ -1,47 +1,36 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"image"
-	"os"
+	"path"
 	"strings"
 
 	"github.com/sirupsen/logrus"
 
 	"github.com/alisavch/image-service/internal/log"
 	"github.com/alisavch/image-service/internal/models"
+	"github.com/alisavch/image-service/internal/storage"
 	"github.com/alisavch/image-service/internal/utils"
 
 	"github.com/google/uuid"
 )
 
-const (
-	aws   = "AWS"
-	local = "local"
-)
-
-var (
-	convertedType = map[string]string{
-		"jpeg": "png",
-		"jpg":  "png",
-		"png":  "jpeg",
-	}
-)
-
 // ImageService provides access to repository.
 type ImageService struct {
-	repo   ImageRepo
-	bucket S3Bucket
-	logger FormattingOutput
+	repo    ImageRepo
+	storage storage.Storage
+	logger  FormattingOutput
 }
 
 // NewImageService configures ImageService.
-func NewImageService(repo ImageRepo, bucket S3Bucket) *ImageService {
+func NewImageService(repo ImageRepo, store storage.Storage) *ImageService {
 	return &ImageService{
-		repo:   repo,
-		bucket: bucket,
-		logger: log.NewCustomLogger(logrus.New()),
+		repo:    repo,
+		storage: store,
+		logger:  log.NewCustomLogger(logrus.New()),
 	}
 }
 
@@ -60,46 +49,66 @@ func (s *ImageService) UploadResultedImage(ctx context.Context, img models.Image
 	return s.repo.UploadResultedImage(ctx, img)
 }
 
-// CompressImage compress image.
-func (s *ImageService) CompressImage(width int, format, resultedName string, img image.Image, newImg *os.File, storage string) (models.Image, error) {
-	switch format {
-	case "jpeg":
-		if err := CompressJPEG(img, width, newImg); err != nil {
-			return models.Image{}, fmt.Errorf("%s:%s", utils.ErrCompress, err)
-		}
-	case "png":
-		if err := CompressPNG(img, width, newImg); err != nil {
-			return models.Image{}, fmt.Errorf("%s:%s", utils.ErrCompress, err)
-		}
-	}
+// CompressImage resizes the image into the box according to the resize mode and keeps its format when it can be written.
+func (s *ImageService) CompressImage(resize models.Resize, format, resultedName string, img image.Image, opts ...EncodeOption) (models.Image, error) {
+	return s.processImage(utils.ErrCompress, OutputFormat(format), resultedName, img, func(img image.Image) (image.Image, error) {
+		return ResizeImage(img, resize)
+	}, opts...)
+}
 
-	result, err := s.FillInTheResultingImage(storage, resultedName, newImg)
-	if err != nil {
-		return models.Image{}, err
+// CropImage cuts the rectangle out of the image and keeps its format when it can be written.
+func (s *ImageService) CropImage(crop models.Crop, format, resultedName string, img image.Image, opts ...EncodeOption) (models.Image, error) {
+	return s.processImage(utils.ErrCrop, OutputFormat(format), resultedName, img, func(img image.Image) (image.Image, error) {
+		return CropImage(img, crop)
+	}, opts...)
+}
+
+// TransformImage rotates and flips the image and keeps its format when it can be written.
+func (s *ImageService) TransformImage(transform models.Transform, format, resultedName string, img image.Image, opts ...EncodeOption) (models.Image, error) {
+	return s.processImage(utils.ErrTransform, OutputFormat(format), resultedName, img, func(img image.Image) (image.Image, error) {
+		return TransformImage(img, transform)
+	}, opts...)
+}
+
+// PipelineImage runs the steps of the pipeline and writes only the final image.
+// The source format is kept unless the pipeline ends with a convert step.
+func (s *ImageService) PipelineImage(steps []models.Step, format, resultedName string, img image.Image, opts ...EncodeOption) (models.Image, error) {
+	target := OutputFormat(format)
+	if converted, ok := PipelineFormat(steps); ok {
+		target = converted
 	}
 
-	return result, nil
+	return s.processImage(utils.ErrPipeline, target, resultedName, img, func(img image.Image) (image.Image, error) {
+		return RunPipeline(img, steps)
+	}, opts...)
+}
+
+// ConvertToType converts the image to the target format.
+func (s *ImageService) ConvertToType(format, resultedName string, img image.Image, opts ...EncodeOption) (models.Image, error) {
+	return s.processImage(utils.ErrConvert, format, resultedName, img, func(img image.Image) (image.Image, error) {
+		return img, nil
+	}, opts...)
 }
 
-// ConvertToType converts from png to jpeg and vice versa.
-func (s *ImageService) ConvertToType(format, resultedName string, img image.Image, newImg *os.File, storage string) (models.Image, error) {
-	switch format {
-	case "jpeg":
-		if err := ConvertToPNG(newImg, img); err != nil {
-			return models.Image{}, fmt.Errorf("%s:%s", utils.ErrCompress, err)
-		}
-	case "png":
-		if err := ConvertToJPEG(newImg, img); err != nil {
-			return models.Image{}, fmt.Errorf("%s:%s", utils.ErrCompress, err)
-		}
+// processImage applies the operation to the image, writes the result in the target format and puts it into the storage.
+// The failures of the operation and of the encoding are reported as a ProcessingError of failErr, they are not retried.
+func (s *ImageService) processImage(failErr error, target, resultedName string, img image.Image, op func(image.Image) (image.Image, error), opts ...EncodeOption) (models.Image, error) {
+	m, err := op(img)
+	if err != nil {
+		return models.Image{}, &utils.ProcessingError{Op: failErr, Err: err}
+	}
+
+	newImg, err := EncodeResult(m, target, opts...)
+	if err != nil {
+		return models.Image{}, &utils.ProcessingError{Op: failErr, Err: err}
 	}
 
-	result, err := s.FillInTheResultingImage(storage, resultedName, newImg)
+	result, err := s.FillInTheResultingImage(resultedName, newImg)
 	if err != nil {
 		return models.Image{}, err
 	}
 
-	return result, nil
+	return s.fillInThePlaceholders(result, m), nil
 }
 
 // FindRequestStatus checks request status.
@@ -107,6 +116,11 @@ func (s *ImageService) FindRequestStatus(ctx context.Context, userID, requestID
 	return s.repo.FindRequestStatus(ctx, userID, requestID)
 }
 
+// FindRequest finds the service and status of the request.
+func (s *ImageService) FindRequest(ctx context.Context, userID, requestID uuid.UUID) (models.Request, error) {
+	return s.repo.FindRequest(ctx, userID, requestID)
+}
+
 // CreateRequest creates request.
 func (s *ImageService) CreateRequest(ctx context.Context, user models.User, img models.Image, req models.Request) (uuid.UUID, error) {
 	return s.repo.CreateRequest(ctx, user, img, req)
@@ -122,32 +136,24 @@ func (s *ImageService) FindOriginalImage(ctx context.Context, id uuid.UUID) (mod
 	return s.repo.FindOriginalImage(ctx, id)
 }
 
-// SaveImage saves image to users machine.
-func (s *ImageService) SaveImage(filename, location, storage string) (*models.SavedImage, error) {
-	var file *os.File
-	img := models.SavedImage{Filename: filename}
-
-	switch storage {
-	case aws:
-		f, err := s.bucket.DownloadFromS3Bucket(filename)
-		if err != nil {
-			return nil, fmt.Errorf("%s:%s", utils.ErrRemoteDownload, err)
-		}
-		file = f
-
-	case local:
-		f, err := os.Open(location + filename)
-		if err != nil {
-			return nil, utils.ErrOpen
-		}
-		file = f
+// SaveImage opens the stored image for sending it to the user, the caller closes its file.
+func (s *ImageService) SaveImage(key string) (*models.SavedImage, error) {
+	object, err := s.storage.Stat(key)
+	if err != nil {
+		return nil, err
 	}
 
-	img, err := FillInTheImage(img, file)
+	file, err := s.storage.Get(key)
 	if err != nil {
 		return nil, err
 	}
 
+	img, err := FillInTheImage(models.SavedImage{Filename: path.Base(key), Filesize: object.Size}, file)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
 	return &img, nil
 }
 
@@ -156,62 +162,27 @@ func (s *ImageService) UpdateStatus(ctx context.Context, id uuid.UUID, status mo
 	return s.repo.UpdateStatus(ctx, id, status)
 }
 
-// ChangeFormat changes image format.
-func (s *ImageService) ChangeFormat(filename string) (string, error) {
-	imgNames := strings.Split(strings.ToLower(filename), ".")
-	extension := imgNames[len(imgNames)-1]
-
-	if format, ok := convertedType[extension]; ok {
-		imgNames[len(imgNames)-1] = format
-		convertedName := strings.Join(imgNames, ".")
-		return convertedName, nil
+// ChangeFormat replaces the extension of the file with the extension of the target format.
+func (s *ImageService) ChangeFormat(filename, format string) (string, error) {
+	f, err := LookupTargetFormat(format)
+	if err != nil {
+		return "", err
 	}
 
-	return "", utils.ErrUnsupportedFormat
+	return strings.TrimSuffix(filename, path.Ext(filename)) + "." + f.Extension(), nil
 }
 
-// FillInTheResultingImageForAWS fills the resultedImage with information for the aws storage.
-func (s *ImageService) FillInTheResultingImageForAWS(resultedName string) (models.Image, error) {
-	fileReader, err := os.Open(resultedName)
-	if err != nil {
-		return models.Image{}, fmt.Errorf("%s:%s", utils.ErrOpenFile, err)
-	}
-	defer func(fileReader *os.File) {
-		err := fileReader.Close()
-		if err != nil {
-			s.logger.Fatalf("%s:%s", "failed fileReader.Close", err)
-		}
-	}(fileReader)
-
-	imageLocation, err := s.bucket.UploadToS3Bucket(fileReader, resultedName)
+// FillInTheResultingImage puts the encoded result into the storage.
+func (s *ImageService) FillInTheResultingImage(resultedName string, newImg *bytes.Buffer) (models.Image, error) {
+	size := int64(newImg.Len())
+
+	location, err := s.storage.Put(storage.ResultKey(resultedName), newImg)
 	if err != nil {
-		return models.Image{}, fmt.Errorf("%s:%s", utils.ErrRemoteUpload, err)
+		return models.Image{}, fmt.Errorf("%s:%s", utils.ErrSaveImage, err)
 	}
 
-	result := FillInTheReceivedNameAndLocation(resultedName, imageLocation)
-
-	return result, nil
-}
-
-// FillInTheResultingImage fills the resultedImage with information.
-func (s *ImageService) FillInTheResultingImage(storage, resultedName string, newImg *os.File) (models.Image, error) {
-	var result models.Image
-
-	switch storage {
-	case aws:
-		resultedImage, err := s.FillInTheResultingImageForAWS(resultedName)
-		if err != nil {
-			return models.Image{}, err
-		}
-		result = resultedImage
-
-	case local:
-		resultedImage, err := FillInTheResultingImageLocally(resultedName, newImg)
-		if err != nil {
-			return models.Image{}, err
-		}
-		result = resultedImage
-	}
+	result := FillInTheReceivedNameAndLocation(resultedName, location)
+	result.ResultedSize = size
 
 	return result, nil
 }
@@ -225,3 +196,73 @@ func (s *ImageService) CompleteRequest(ctx context.Context, id uuid.UUID, status
 func (s *ImageService) IsAuthenticated(ctx context.Context, userID, requestID uuid.UUID) error {
 	return s.repo.IsAuthenticated(ctx, userID, requestID)
 }
+
+// SaveWatermark saves the default watermark of the user.
+func (s *ImageService) SaveWatermark(ctx context.Context, userID uuid.UUID, wm models.Watermark) error {
+	return s.repo.SaveWatermark(ctx, userID, wm)
+}
+
+// FindWatermark finds the default watermark of the user.
+func (s *ImageService) FindWatermark(ctx context.Context, userID uuid.UUID) (models.Watermark, error) {
+	return s.repo.FindWatermark(ctx, userID)
+}
+
+// DeleteWatermark deletes the default watermark of the user.
+func (s *ImageService) DeleteWatermark(ctx context.Context, userID uuid.UUID) error {
+	return s.repo.DeleteWatermark(ctx, userID)
+}
+
+// CreateRenditions saves the renditions produced for the request, the renditions of an earlier attempt are replaced.
+func (s *ImageService) CreateRenditions(ctx context.Context, requestID uuid.UUID, renditions []models.Rendition) error {
+	return s.repo.CreateRenditions(ctx, requestID, renditions)
+}
+
+// CreatePalette saves the palette of the request in its order, the palette of an earlier attempt is replaced.
+func (s *ImageService) CreatePalette(ctx context.Context, requestID uuid.UUID, palette []models.PaletteColor) error {
+	return s.repo.CreatePalette(ctx, requestID, palette)
+}
+
+// FindPalette finds the palette of the request.
+func (s *ImageService) FindPalette(ctx context.Context, requestID uuid.UUID) ([]models.PaletteColor, error) {
+	return s.repo.FindPalette(ctx, requestID)
+}
+
+// FindRenditions finds the renditions of the request.
+func (s *ImageService) FindRenditions(ctx context.Context, requestID uuid.UUID) ([]models.Rendition, error) {
+	return s.repo.FindRenditions(ctx, requestID)
+}
+
+// FindRendition finds the rendition of the request by id.
+func (s *ImageService) FindRendition(ctx context.Context, requestID, renditionID uuid.UUID) (models.Rendition, error) {
+	return s.repo.FindRendition(ctx, requestID, renditionID)
+}
+
+// SaveImageHash saves the perceptual hash of the original image.
+func (s *ImageService) SaveImageHash(ctx context.Context, imageID uuid.UUID, hash uint64) error {
+	return s.repo.SaveImageHash(ctx, imageID, hash)
+}
+
+// FindImage finds the image of the user by id.
+func (s *ImageService) FindImage(ctx context.Context, userID, imageID uuid.UUID) (models.Image, error) {
+	return s.repo.FindImage(ctx, userID, imageID)
+}
+
+// FindImageHash finds the perceptual hash of the image of the user.
+func (s *ImageService) FindImageHash(ctx context.Context, userID, imageID uuid.UUID) (uint64, error) {
+	return s.repo.FindImageHash(ctx, userID, imageID)
+}
+
+// FindSimilarImages finds the other images of the user within the Hamming distance of the hash.
+func (s *ImageService) FindSimilarImages(ctx context.Context, userID, imageID uuid.UUID, hash uint64, distance int) ([]models.SimilarImage, error) {
+	return s.repo.FindSimilarImages(ctx, userID, imageID, hash, distance)
+}
+
+// fillInThePlaceholders adds the placeholders to the result, the image is still usable without them, so a failure is only logged.
+func (s *ImageService) fillInThePlaceholders(result models.Image, img image.Image) models.Image {
+	withPlaceholders, err := FillInThePlaceholders(result, img)
+	if err != nil {
+		s.logger.Printf("%s:%s", utils.ErrPlaceholder, err)
+		return result
+	}
+	return withPlaceholders
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math/bits"
+	"time"
+
+	"github.com/alisavch/image-service/internal/models"
+	"github.com/alisavch/image-service/internal/utils"
+)
+
+const (
+	exifDateLayout = "2006:01:02 15:04:05"
+	infoDateLayout = "2006-01-02T15:04:05"
+)
+
+// InspectImage describes the stored image from the first SniffSize bytes without decoding its pixels.
+// The size and the SHA-256 recorded at the upload are used when the digest is set,
+// otherwise the rest of the object is streamed through the hash and never held in memory.
+func (s *ImageService) InspectImage(key string, size int64, digest string) (models.ImageInfo, error) {
+	file, err := s.storage.Get(key)
+	if err != nil {
+		return models.ImageInfo{}, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			s.logger.Printf("%s:%s", "failed file.Close", err)
+		}
+	}()
+
+	head := make([]byte, SniffSize)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return models.ImageInfo{}, fmt.Errorf("%s:%s", utils.ErrInspectImage, err)
+	}
+	head = head[:n]
+
+	info, err := Inspect(head)
+	if err != nil {
+		return models.ImageInfo{}, fmt.Errorf("%s:%s", utils.ErrInspectImage, err)
+	}
+
+	if digest != "" {
+		info.Size, info.SHA256 = size, digest
+		return info, nil
+	}
+
+	hash := sha256.New()
+	hash.Write(head)
+	rest, err := io.Copy(hash, file)
+	if err != nil {
+		return models.ImageInfo{}, fmt.Errorf("%s:%s", utils.ErrInspectImage, err)
+	}
+	info.Size = int64(n) + rest
+	info.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	return info, nil
+}
+
+// Inspect describes the image file from its header with image.DecodeConfig, so only the metadata is parsed.
+// The header is the beginning of the file, the size and the SHA-256 are left to the caller.
+func Inspect(header []byte) (models.ImageInfo, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(header))
+	if err != nil {
+		return models.ImageInfo{}, err
+	}
+
+	info := models.ImageInfo{
+		Format: format,
+		Width:  cfg.Width,
+		Height: cfg.Height,
+		Exif:   readExifInfo(ReadMetadata(header).Exif),
+	}
+	info.ColorModel, info.BitDepth, info.HasAlpha = describeColorModel(cfg.ColorModel)
+
+	return info, nil
+}
+
+// describeColorModel names the color model and reports its depth and whether it can carry transparency.
+func describeColorModel(model color.Model) (string, int, bool) {
+	if palette, ok := model.(color.Palette); ok {
+		alpha := false
+		for _, c := range palette {
+			if _, _, _, a := c.RGBA(); a != 0xffff {
+				alpha = true
+				break
+			}
+		}
+		return "paletted", maxInt(bits.Len(uint(len(palette)-1)), 1), alpha
+	}
+
+	switch model {
+	case color.RGBAModel:
+		return "rgb", 8, false
+	case color.RGBA64Model:
+		return "rgb", 16, false
+	case color.NRGBAModel:
+		return "rgba", 8, true
+	case color.NRGBA64Model:
+		return "rgba", 16, true
+	case color.GrayModel:
+		return "gray", 8, false
+	case color.Gray16Model:
+		return "gray", 16, false
+	case color.AlphaModel:
+		return "alpha", 8, true
+	case color.Alpha16Model:
+		return "alpha", 16, true
+	case color.YCbCrModel:
+		return "ycbcr", 8, false
+	case color.NYCbCrAModel:
+		return "ycbcra", 8, true
+	case color.CMYKModel:
+		return "cmyk", 8, false
+	}
+	return "unknown", 0, false
+}
+
+// readExifInfo picks the camera, the date taken, the orientation and the presence of the location from the EXIF data.
+func readExifInfo(raw []byte) *models.ExifInfo {
+	e, ok := parseExif(raw)
+	if !ok {
+		return nil
+	}
+
+	info := &models.ExifInfo{
+		Make:        e.ascii(e.ifd0, exifTagMake),
+		Model:       e.ascii(e.ifd0, exifTagModel),
+		Orientation: e.orientation(),
+		HasGPS:      len(e.subIFD(exifTagGPSIFD)) > 0,
+	}
+
+	taken := e.ascii(e.subIFD(exifTagExifIFD), exifTagDateTimeOriginal)
+	if taken == "" {
+		taken = e.ascii(e.ifd0, exifTagDateTime)
+	}
+	if t, err := time.Parse(exifDateLayout, taken); err == nil {
+		taken = t.Format(infoDateLayout)
+	}
+	info.DateTaken = taken
+
+	return info
+}
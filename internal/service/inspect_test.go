@@ -0,0 +1,61 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/alisavch/image-service/internal/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageService_InspectImage(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 24, 16))))
+	// The file is longer than the head, so the digest covers the part that is streamed.
+	data := append(buf.Bytes(), make([]byte, SniffSize)...)
+	sum := sha256.Sum256(data)
+
+	store := storage.NewFileSystem(t.TempDir())
+	_, err := store.Put("uploads/image.png", bytes.NewReader(data))
+	require.NoError(t, err)
+	s := NewImageService(nil, store)
+
+	tests := []struct {
+		name   string
+		size   int64
+		digest string
+		want   string
+		wantN  int64
+	}{
+		{
+			name:  "Digest computed from the stored file",
+			want:  hex.EncodeToString(sum[:]),
+			wantN: int64(len(data)),
+		},
+		{
+			name:   "Digest recorded at the upload",
+			size:   42,
+			digest: "recorded",
+			want:   "recorded",
+			wantN:  42,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := s.InspectImage("uploads/image.png", tt.size, tt.digest)
+			require.NoError(t, err)
+			require.Equal(t, "png", info.Format)
+			require.Equal(t, 24, info.Width)
+			require.Equal(t, 16, info.Height)
+			require.True(t, info.HasAlpha)
+			require.Equal(t, tt.want, info.SHA256)
+			require.Equal(t, tt.wantN, info.Size)
+		})
+	}
+}
@@ -2,8 +2,6 @@ package service
 
 import (
 	"context"
-	"io"
-	"os"
 
 	"github.com/alisavch/image-service/internal/models"
 	"github.com/google/uuid"
@@ -19,6 +17,7 @@ type AuthorizationRepo interface {
 type ImageRepo interface {
 	FindUserRequestHistory(ctx context.Context, id uuid.UUID) ([]models.History, error)
 	FindRequestStatus(ctx context.Context, userID, requestID uuid.UUID) (models.Status, error)
+	FindRequest(ctx context.Context, userID, requestID uuid.UUID) (models.Request, error)
 	UploadImage(ctx context.Context, img models.Image) (uuid.UUID, error)
 	UploadResultedImage(ctx context.Context, img models.Image) error
 	CreateRequest(ctx context.Context, user models.User, img models.Image, req models.Request) (uuid.UUID, error)
@@ -27,15 +26,22 @@ type ImageRepo interface {
 	UpdateStatus(ctx context.Context, id uuid.UUID, status models.Status) error
 	CompleteRequest(ctx context.Context, id uuid.UUID, status models.Status) error
 	IsAuthenticated(ctx context.Context, userID, requestID uuid.UUID) error
-}
-
-// S3Bucket contains the basic functions for interacting with the bucket.
-type S3Bucket interface {
-	UploadToS3Bucket(file io.Reader, filename string) (string, error)
-	DownloadFromS3Bucket(filename string) (*os.File, error)
+	SaveWatermark(ctx context.Context, userID uuid.UUID, wm models.Watermark) error
+	FindWatermark(ctx context.Context, userID uuid.UUID) (models.Watermark, error)
+	DeleteWatermark(ctx context.Context, userID uuid.UUID) error
+	CreateRenditions(ctx context.Context, requestID uuid.UUID, renditions []models.Rendition) error
+	FindRenditions(ctx context.Context, requestID uuid.UUID) ([]models.Rendition, error)
+	FindRendition(ctx context.Context, requestID, renditionID uuid.UUID) (models.Rendition, error)
+	SaveImageHash(ctx context.Context, imageID uuid.UUID, hash uint64) error
+	FindImage(ctx context.Context, userID, imageID uuid.UUID) (models.Image, error)
+	FindImageHash(ctx context.Context, userID, imageID uuid.UUID) (uint64, error)
+	FindSimilarImages(ctx context.Context, userID, imageID uuid.UUID, hash uint64, distance int) ([]models.SimilarImage, error)
+	CreatePalette(ctx context.Context, requestID uuid.UUID, palette []models.PaletteColor) error
+	FindPalette(ctx context.Context, requestID uuid.UUID) ([]models.PaletteColor, error)
 }
 
 // FormattingOutput contains methods for formatting log output.
 type FormattingOutput interface {
+	Printf(format string, args ...interface{})
 	Fatalf(format string, args ...interface{})
 }
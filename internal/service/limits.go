@@ -0,0 +1,39 @@
+package service
+
+import (
+	"image"
+
+	"github.com/alisavch/image-service/internal/utils"
+)
+
+// CheckFileSize rejects the files larger than the limit.
+func CheckFileSize(size int64, limits utils.LimitsConfig) error {
+	if limits.MaxFileSize > 0 && size > limits.MaxFileSize {
+		return &utils.LimitError{Limit: "file size", Value: float64(size), Max: float64(limits.MaxFileSize)}
+	}
+	return nil
+}
+
+func checkDimensions(cfg image.Config, limits utils.LimitsConfig) error {
+	if limits.MaxWidth > 0 && cfg.Width > limits.MaxWidth {
+		return &utils.LimitError{Limit: "width", Value: float64(cfg.Width), Max: float64(limits.MaxWidth)}
+	}
+	if limits.MaxHeight > 0 && cfg.Height > limits.MaxHeight {
+		return &utils.LimitError{Limit: "height", Value: float64(cfg.Height), Max: float64(limits.MaxHeight)}
+	}
+
+	megapixels := float64(cfg.Width) * float64(cfg.Height) / 1e6
+	if limits.MaxMegapixels > 0 && megapixels > limits.MaxMegapixels {
+		return &utils.LimitError{Limit: "megapixels", Value: megapixels, Max: limits.MaxMegapixels}
+	}
+	return nil
+}
+
+// checkFrames applies the megapixel limit to all the frames of an animation, every frame is held in memory once it is decoded.
+func checkFrames(cfg image.Config, frames int, limits utils.LimitsConfig) error {
+	megapixels := float64(cfg.Width) * float64(cfg.Height) * float64(frames) / 1e6
+	if limits.MaxMegapixels > 0 && megapixels > limits.MaxMegapixels {
+		return &utils.LimitError{Limit: "megapixels of all frames", Value: megapixels, Max: limits.MaxMegapixels}
+	}
+	return nil
+}
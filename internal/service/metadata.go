@@ -0,0 +1,332 @@
+package service
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+
+	"github.com/alisavch/image-service/internal/models"
+)
+
+const (
+	// maxJPEGSegment is the largest payload of a single JPEG segment.
+	maxJPEGSegment = 65533
+	// maxICCChunk is the largest part of the ICC profile stored in a single APP2 segment.
+	maxICCChunk = maxJPEGSegment - len("ICC_PROFILE\x00") - 2
+	// maxICCSize is the largest color profile inflated from a PNG file.
+	maxICCSize = 4 << 20
+	// maxXMPSize is the largest XMP packet inflated from a PNG file.
+	maxXMPSize = 1 << 20
+)
+
+var (
+	xmpHeader  = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	iccHeader  = []byte("ICC_PROFILE\x00")
+	pngHeader  = []byte("\x89PNG\r\n\x1a\n")
+	xmpKeyword = "XML:com.adobe.xmp"
+)
+
+// unsafeExifTags are the tags removed by the keep-safe policy: the location, the serial numbers and the owner,
+// the maker notes that can hold them too, and the pointers to the directories that are not copied.
+var unsafeExifTags = map[uint16]bool{
+	exifTagGPSIFD:           true,
+	exifTagInteropIFD:       true,
+	exifTagSubIFDs:          true,
+	exifTagMakerNote:        true,
+	exifTagImageUniqueID:    true,
+	exifTagCameraOwnerName:  true,
+	exifTagBodySerialNumber: true,
+	exifTagLensSerialNumber: true,
+	exifTagCameraSerial:     true,
+}
+
+// unsafeXMPProperty matches the XMP properties removed by the keep-safe policy under their usual prefixes:
+// the GPS fields of the EXIF schema, the serial numbers and the owner.
+var unsafeXMPProperty = regexp.MustCompile(`(exif:GPS[A-Za-z]*|exif:ImageUniqueID|exifEX:(BodySerialNumber|LensSerialNumber|CameraOwnerName|ImageUniqueID)|aux:(SerialNumber|LensSerialNumber|OwnerName))`)
+
+var (
+	unsafeXMPAttribute = regexp.MustCompile(`\s+` + unsafeXMPProperty.String() + `\s*=\s*("[^"]*"|'[^']*')`)
+	unsafeXMPElement   = regexp.MustCompile(`<` + unsafeXMPProperty.String() + `[\s/>]`)
+)
+
+// Metadata contains the metadata blocks of the image.
+type Metadata struct {
+	// Exif is the TIFF structure of the EXIF data.
+	Exif []byte
+	// ICC is the color profile.
+	ICC []byte
+	// XMP is the XMP packet.
+	XMP []byte
+}
+
+// IsEmpty reports whether there is no metadata.
+func (m Metadata) IsEmpty() bool {
+	return len(m.Exif) == 0 && len(m.ICC) == 0 && len(m.XMP) == 0
+}
+
+// ReadMetadata reads the metadata blocks of the JPEG or PNG file.
+func ReadMetadata(data []byte) Metadata {
+	if bytes.HasPrefix(data, pngHeader) {
+		return readPNGMetadata(data)
+	}
+	return readJPEGMetadata(data)
+}
+
+// Filter applies the metadata policy.
+func (m Metadata) Filter(policy models.MetadataPolicy) Metadata {
+	switch policy {
+	case models.Keep:
+		return m
+	case models.KeepSafe:
+		safe := Metadata{ICC: m.ICC, XMP: filterXMP(m.XMP)}
+		if e, ok := parseExif(m.Exif); ok {
+			safe.Exif = e.filter(unsafeExifTags)
+		}
+		return safe
+	}
+	return Metadata{}
+}
+
+// filterXMP removes the location, the serial numbers and the owner from the XMP packet,
+// the properties are written either as attributes of the description or as elements.
+func filterXMP(packet []byte) []byte {
+	if len(packet) == 0 {
+		return nil
+	}
+
+	out := unsafeXMPAttribute.ReplaceAll(packet, nil)
+	for {
+		loc := unsafeXMPElement.FindIndex(out)
+		if loc == nil {
+			return out
+		}
+
+		name := out[loc[0]+1 : loc[1]-1]
+		end := bytes.IndexByte(out[loc[0]:], '>')
+		if end < 0 {
+			return nil
+		}
+		end += loc[0] + 1
+		if out[end-2] != '/' {
+			closing := bytes.Index(out[end:], []byte("</"+string(name)+">"))
+			if closing < 0 {
+				return nil
+			}
+			end += closing + len(name) + 3
+		}
+		out = append(out[:loc[0]:loc[0]], out[end:]...)
+	}
+}
+
+// ResetOrientation marks the image as upright, it is used once the pixels are rotated.
+func (m Metadata) ResetOrientation() Metadata {
+	if e, ok := parseExif(m.Exif); ok {
+		m.Exif = e.withOrientation(1)
+	}
+	return m
+}
+
+func readJPEGMetadata(data []byte) Metadata {
+	var (
+		m      Metadata
+		chunks = map[byte][]byte{}
+	)
+
+	for _, segment := range jpegSegments(data) {
+		switch {
+		case segment.marker == 0xE1 && bytes.HasPrefix(segment.data, exifHeader) && m.Exif == nil:
+			m.Exif = segment.data[len(exifHeader):]
+		case segment.marker == 0xE1 && bytes.HasPrefix(segment.data, xmpHeader) && m.XMP == nil:
+			m.XMP = segment.data[len(xmpHeader):]
+		case segment.marker == 0xE2 && bytes.HasPrefix(segment.data, iccHeader) && len(segment.data) > len(iccHeader)+2:
+			chunks[segment.data[len(iccHeader)]] = segment.data[len(iccHeader)+2:]
+		}
+	}
+
+	if len(chunks) > 0 {
+		keys := make([]int, 0, len(chunks))
+		for seq := range chunks {
+			keys = append(keys, int(seq))
+		}
+		sort.Ints(keys)
+		for _, seq := range keys {
+			m.ICC = append(m.ICC, chunks[byte(seq)]...)
+		}
+	}
+
+	return m
+}
+
+// pngChunks calls fn for every chunk of the PNG file until it returns false.
+func pngChunks(data []byte, fn func(kind string, body []byte) bool) {
+	for i := len(pngHeader); i+12 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[i : i+4]))
+		if length < 0 || i+12+length > len(data) {
+			return
+		}
+		if !fn(string(data[i+4:i+8]), data[i+8:i+8+length]) {
+			return
+		}
+		i += 12 + length
+	}
+}
+
+func readPNGMetadata(data []byte) Metadata {
+	var m Metadata
+
+	pngChunks(data, func(kind string, body []byte) bool {
+		switch kind {
+		case "eXIf":
+			m.Exif = body
+		case "iCCP":
+			// The profile name is followed by the compression method and the zlib stream.
+			if name := bytes.IndexByte(body, 0); name >= 0 && name+2 <= len(body) {
+				m.ICC = inflate(body[name+2:], maxICCSize)
+			}
+		case "iTXt":
+			m.XMP = readPNGXMP(body, m.XMP)
+		case "IDAT", "IEND":
+			return false
+		}
+		return true
+	})
+
+	return m
+}
+
+// readPNGXMP returns the XMP packet if the iTXt chunk contains it.
+func readPNGXMP(body []byte, current []byte) []byte {
+	fields := bytes.SplitN(body, []byte{0}, 2)
+	if len(fields) != 2 || string(fields[0]) != xmpKeyword || len(fields[1]) < 2 {
+		return current
+	}
+
+	compressed := fields[1][0] == 1
+	rest := bytes.SplitN(fields[1][2:], []byte{0}, 3)
+	if len(rest) != 3 {
+		return current
+	}
+
+	if compressed {
+		return inflate(rest[2], maxXMPSize)
+	}
+	return rest[2]
+}
+
+// inflate decompresses the zlib stream, a stream longer than the limit is dropped,
+// so a small chunk cannot expand into gigabytes before the limits of the image are checked.
+func inflate(data []byte, limit int64) []byte {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	defer r.Close()
+
+	out, err := ioutil.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil || int64(len(out)) > limit {
+		return nil
+	}
+	return out
+}
+
+func deflate(data []byte) []byte {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	_, _ = w.Write(data)
+	_ = w.Close()
+	return buf.Bytes()
+}
+
+// writeJPEGMetadata writes the encoded JPEG file with the metadata segments inserted after the start of image.
+func writeJPEGMetadata(w io.Writer, encoded []byte, m Metadata) error {
+	var segments bytes.Buffer
+
+	if len(m.Exif) > 0 && len(exifHeader)+len(m.Exif) <= maxJPEGSegment {
+		writeJPEGSegment(&segments, 0xE1, exifHeader, m.Exif)
+	}
+	if len(m.XMP) > 0 && len(xmpHeader)+len(m.XMP) <= maxJPEGSegment {
+		writeJPEGSegment(&segments, 0xE1, xmpHeader, m.XMP)
+	}
+	if len(m.ICC) > 0 {
+		count := (len(m.ICC) + maxICCChunk - 1) / maxICCChunk
+		if count <= 255 {
+			for i := 0; i < count; i++ {
+				end := (i + 1) * maxICCChunk
+				if end > len(m.ICC) {
+					end = len(m.ICC)
+				}
+				header := append(append([]byte{}, iccHeader...), byte(i+1), byte(count))
+				writeJPEGSegment(&segments, 0xE2, header, m.ICC[i*maxICCChunk:end])
+			}
+		}
+	}
+
+	if _, err := w.Write(encoded[:2]); err != nil {
+		return err
+	}
+	if _, err := w.Write(segments.Bytes()); err != nil {
+		return err
+	}
+	_, err := w.Write(encoded[2:])
+	return err
+}
+
+func writeJPEGSegment(buf *bytes.Buffer, marker byte, header, body []byte) {
+	length := 2 + len(header) + len(body)
+	buf.Write([]byte{0xFF, marker, byte(length >> 8), byte(length)})
+	buf.Write(header)
+	buf.Write(body)
+}
+
+// writePNGMetadata writes the encoded PNG file with the metadata chunks inserted after the image header.
+func writePNGMetadata(w io.Writer, encoded []byte, m Metadata) error {
+	var chunks bytes.Buffer
+
+	if len(m.ICC) > 0 {
+		writePNGChunk(&chunks, "iCCP", append([]byte("ICC Profile\x00\x00"), deflate(m.ICC)...))
+	}
+	if len(m.Exif) > 0 {
+		writePNGChunk(&chunks, "eXIf", m.Exif)
+	}
+	if len(m.XMP) > 0 {
+		writePNGChunk(&chunks, "iTXt", append([]byte(xmpKeyword+"\x00\x00\x00\x00\x00"), m.XMP...))
+	}
+
+	// The signature is followed by the IHDR chunk of 13 bytes.
+	headerEnd := len(pngHeader) + 12 + 13
+	if len(encoded) < headerEnd {
+		_, err := w.Write(encoded)
+		return err
+	}
+
+	if _, err := w.Write(encoded[:headerEnd]); err != nil {
+		return err
+	}
+	if _, err := w.Write(chunks.Bytes()); err != nil {
+		return err
+	}
+	_, err := w.Write(encoded[headerEnd:])
+	return err
+}
+
+func writePNGChunk(buf *bytes.Buffer, kind string, body []byte) {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(body)))
+	buf.Write(length[:])
+
+	crc := crc32.NewIEEE()
+	_, _ = crc.Write([]byte(kind))
+	_, _ = crc.Write(body)
+
+	buf.WriteString(kind)
+	buf.Write(body)
+
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	buf.Write(sum[:])
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/alisavch/image-service/internal/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newICC returns a fake color profile of the size, its bytes follow a pattern so a misplaced chunk changes it.
+func newICC(size int) []byte {
+	icc := make([]byte, size)
+	for i := range icc {
+		icc[i] = byte(i % 251)
+	}
+	return icc
+}
+
+func TestWriteJPEGMetadata_ICCChunks(t *testing.T) {
+	var encoded bytes.Buffer
+	require.NoError(t, jpeg.Encode(&encoded, image.NewGray(image.Rect(0, 0, 8, 8)), nil))
+
+	m := Metadata{
+		Exif: newExif(binary.LittleEndian, orientationEntry(binary.LittleEndian, 1)),
+		ICC:  newICC(2*maxICCChunk + 100),
+		XMP:  []byte("<x:xmpmeta/>"),
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, writeJPEGMetadata(&out, encoded.Bytes(), m))
+
+	var chunks [][2]byte
+	for _, segment := range jpegSegments(out.Bytes()) {
+		if segment.marker == 0xE2 && bytes.HasPrefix(segment.data, iccHeader) {
+			require.LessOrEqual(t, len(segment.data), maxJPEGSegment)
+			chunks = append(chunks, [2]byte{segment.data[len(iccHeader)], segment.data[len(iccHeader)+1]})
+		}
+	}
+	require.Equal(t, [][2]byte{{1, 3}, {2, 3}, {3, 3}}, chunks)
+
+	require.Equal(t, m, ReadMetadata(out.Bytes()))
+	_, err := jpeg.Decode(bytes.NewReader(out.Bytes()))
+	require.NoError(t, err)
+}
+
+func TestReadJPEGMetadata_ICCChunksOutOfOrder(t *testing.T) {
+	var encoded bytes.Buffer
+	require.NoError(t, jpeg.Encode(&encoded, image.NewGray(image.Rect(0, 0, 8, 8)), nil))
+
+	icc := newICC(300)
+	var segments bytes.Buffer
+	writeJPEGSegment(&segments, 0xE2, append(append([]byte{}, iccHeader...), 2, 2), icc[200:])
+	writeJPEGSegment(&segments, 0xE2, append(append([]byte{}, iccHeader...), 1, 2), icc[:200])
+	data := append(append(append([]byte{}, encoded.Bytes()[:2]...), segments.Bytes()...), encoded.Bytes()[2:]...)
+
+	require.Equal(t, icc, ReadMetadata(data).ICC)
+}
+
+func TestWritePNGMetadata(t *testing.T) {
+	var encoded bytes.Buffer
+	require.NoError(t, png.Encode(&encoded, image.NewGray(image.Rect(0, 0, 8, 8))))
+
+	m := Metadata{
+		Exif: newExif(binary.BigEndian, orientationEntry(binary.BigEndian, 1)),
+		ICC:  newICC(4096),
+		XMP:  []byte("<x:xmpmeta/>"),
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, writePNGMetadata(&out, encoded.Bytes(), m))
+
+	// The color profile must come before the image data, the decoders ignore it after IDAT.
+	var kinds []string
+	pngChunks(out.Bytes(), func(kind string, _ []byte) bool {
+		kinds = append(kinds, kind)
+		return true
+	})
+	require.Equal(t, []string{"IHDR", "iCCP", "eXIf", "iTXt", "IDAT", "IEND"}, kinds)
+
+	require.Equal(t, m, ReadMetadata(out.Bytes()))
+	_, err := png.Decode(bytes.NewReader(out.Bytes()))
+	require.NoError(t, err)
+}
+
+func TestMetadata_Filter(t *testing.T) {
+	order := binary.LittleEndian
+	exif := newExif(order,
+		asciiEntry(exifTagMake, "Camera"),
+		orientationEntry(order, 6),
+		asciiEntry(exifTagArtist, "Jane Doe"),
+		asciiEntry(exifTagCopyright, "(c) Jane Doe"),
+		testExifEntry{tag: exifTagGPSIFD, kind: 4, count: 1, value: []byte{0x40, 0, 0, 0}},
+		testExifEntry{tag: exifTagExifIFD, kind: 4, count: 1, value: make([]byte, 4)},
+	)
+	// The sixth entry of the first directory points to the EXIF directory written after it.
+	order.PutUint32(exif[8+2+5*12+8:], uint32(len(exif)))
+	exif = appendTestIFD(order, exif,
+		asciiEntry(exifTagDateTimeOriginal, "2021:06:01 10:20:30"),
+		testExifEntry{tag: exifTagMakerNote, kind: 7, count: 8, value: []byte("SN123456")},
+		asciiEntry(exifTagBodySerialNumber, "123456"),
+	)
+
+	xmp := `<x:xmpmeta><rdf:RDF><rdf:Description rdf:about="" dc:format="image/jpeg" exif:GPSLatitude="51,30.5N" aux:SerialNumber='123456'>` +
+		`<exif:GPSLongitude>0,7.6W</exif:GPSLongitude><dc:rights><rdf:Alt><rdf:li xml:lang="x-default">(c) Jane Doe</rdf:li></rdf:Alt></dc:rights>` +
+		`<exifEX:BodySerialNumber/></rdf:Description></rdf:RDF></x:xmpmeta>`
+	m := Metadata{Exif: exif, ICC: newICC(64), XMP: []byte(xmp)}
+
+	t.Run("Keep", func(t *testing.T) {
+		require.Equal(t, m, m.Filter(models.Keep))
+	})
+
+	t.Run("Strip", func(t *testing.T) {
+		require.True(t, m.Filter(models.Strip).IsEmpty())
+	})
+
+	t.Run("Keep safe", func(t *testing.T) {
+		safe := m.Filter(models.KeepSafe)
+		require.Equal(t, m.ICC, safe.ICC)
+
+		// The rights are kept, the location and the serial number are removed.
+		require.Equal(t, `<x:xmpmeta><rdf:RDF><rdf:Description rdf:about="" dc:format="image/jpeg">`+
+			`<dc:rights><rdf:Alt><rdf:li xml:lang="x-default">(c) Jane Doe</rdf:li></rdf:Alt></dc:rights>`+
+			`</rdf:Description></rdf:RDF></x:xmpmeta>`, string(safe.XMP))
+
+		e, ok := parseExif(safe.Exif)
+		require.True(t, ok)
+		tagsOf := func(entries []exifEntry) []uint16 {
+			var tags []uint16
+			for _, entry := range entries {
+				tags = append(tags, entry.tag)
+			}
+			return tags
+		}
+		require.Equal(t, []uint16{exifTagMake, exifTagOrientation, exifTagArtist, exifTagCopyright, exifTagExifIFD}, tagsOf(e.ifd0))
+		require.Equal(t, []uint16{exifTagDateTimeOriginal}, tagsOf(e.subIFD(exifTagExifIFD)))
+		require.Equal(t, 6, e.orientation())
+		require.Equal(t, "Camera", e.ascii(e.ifd0, exifTagMake))
+		require.Equal(t, "(c) Jane Doe", e.ascii(e.ifd0, exifTagCopyright))
+		require.Equal(t, &models.ExifInfo{Make: "Camera", DateTaken: "2021-06-01T10:20:30", Orientation: 6}, readExifInfo(safe.Exif))
+	})
+}
+
+func TestFilterXMP(t *testing.T) {
+	tests := []struct {
+		name   string
+		packet string
+		want   string
+	}{
+		{
+			name:   "Packet without unsafe properties",
+			packet: `<rdf:Description dc:creator="Jane Doe"/>`,
+			want:   `<rdf:Description dc:creator="Jane Doe"/>`,
+		},
+		{
+			name:   "Nested location element",
+			packet: `<rdf:Description><exif:GPSAltitude><rdf:Seq><rdf:li>10</rdf:li></rdf:Seq></exif:GPSAltitude><aux:Lens>50mm</aux:Lens></rdf:Description>`,
+			want:   `<rdf:Description><aux:Lens>50mm</aux:Lens></rdf:Description>`,
+		},
+		{
+			name:   "Unclosed element drops the packet",
+			packet: `<rdf:Description><aux:SerialNumber>123456</rdf:Description>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := filterXMP([]byte(tt.packet))
+			if tt.want == "" {
+				require.Nil(t, got)
+				return
+			}
+			require.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestReadPNGMetadata_InflateLimit(t *testing.T) {
+	var encoded bytes.Buffer
+	require.NoError(t, png.Encode(&encoded, image.NewGray(image.Rect(0, 0, 8, 8))))
+
+	tests := []struct {
+		name string
+		icc  []byte
+		xmp  []byte
+		want Metadata
+	}{
+		{
+			name: "Blocks within the limits",
+			icc:  newICC(maxICCSize),
+			xmp:  bytes.Repeat([]byte(" "), maxXMPSize),
+			want: Metadata{ICC: newICC(maxICCSize), XMP: bytes.Repeat([]byte(" "), maxXMPSize)},
+		},
+		{
+			name: "Blocks over the limits are dropped",
+			icc:  newICC(maxICCSize + 1),
+			xmp:  bytes.Repeat([]byte(" "), maxXMPSize+1),
+			want: Metadata{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The XMP packet is written compressed, as the encoders of large packets do.
+			var chunks bytes.Buffer
+			writePNGChunk(&chunks, "iCCP", append([]byte("icc\x00\x00"), deflate(tt.icc)...))
+			writePNGChunk(&chunks, "iTXt", append([]byte(xmpKeyword+"\x00\x01\x00\x00\x00"), deflate(tt.xmp)...))
+
+			headerEnd := len(pngHeader) + 12 + 13
+			data := append(append(append([]byte{}, encoded.Bytes()[:headerEnd]...), chunks.Bytes()...), encoded.Bytes()[headerEnd:]...)
+
+			require.Equal(t, tt.want, ReadMetadata(data))
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"image"
+	"image/draw"
+)
+
+// ApplyOrientation rotates and flips the image so that it is displayed upright for the EXIF orientation.
+func ApplyOrientation(imgSrc image.Image, orientation int) image.Image {
+	switch orientation {
+	case 2:
+		return FlipHorizontal(imgSrc)
+	case 3:
+		return Rotate180(imgSrc)
+	case 4:
+		return FlipVertical(imgSrc)
+	case 5:
+		return Transpose(imgSrc)
+	case 6:
+		return Rotate90(imgSrc)
+	case 7:
+		return Transverse(imgSrc)
+	case 8:
+		return Rotate270(imgSrc)
+	}
+	return imgSrc
+}
+
+// FlipHorizontal mirrors the image from left to right.
+func FlipHorizontal(imgSrc image.Image) image.Image {
+	return remap(imgSrc, false, func(x, y, w, h int) (int, int) { return w - 1 - x, y })
+}
+
+// FlipVertical mirrors the image from top to bottom.
+func FlipVertical(imgSrc image.Image) image.Image {
+	return remap(imgSrc, false, func(x, y, w, h int) (int, int) { return x, h - 1 - y })
+}
+
+// Rotate90 rotates the image 90 degrees clockwise.
+func Rotate90(imgSrc image.Image) image.Image {
+	return remap(imgSrc, true, func(x, y, w, h int) (int, int) { return y, h - 1 - x })
+}
+
+// Rotate180 rotates the image 180 degrees.
+func Rotate180(imgSrc image.Image) image.Image {
+	return remap(imgSrc, false, func(x, y, w, h int) (int, int) { return w - 1 - x, h - 1 - y })
+}
+
+// Rotate270 rotates the image 270 degrees clockwise.
+func Rotate270(imgSrc image.Image) image.Image {
+	return remap(imgSrc, true, func(x, y, w, h int) (int, int) { return w - 1 - y, x })
+}
+
+// Transpose mirrors the image over its main diagonal.
+func Transpose(imgSrc image.Image) image.Image {
+	return remap(imgSrc, true, func(x, y, w, h int) (int, int) { return y, x })
+}
+
+// Transverse mirrors the image over its secondary diagonal.
+func Transverse(imgSrc image.Image) image.Image {
+	return remap(imgSrc, true, func(x, y, w, h int) (int, int) { return w - 1 - y, h - 1 - x })
+}
+
+// remap builds the image whose pixel (x, y) is taken from the source pixel returned by src.
+// The sides of the result are swapped when swap is set.
+func remap(imgSrc image.Image, swap bool, src func(x, y, w, h int) (int, int)) image.Image {
+	if anim, ok := imgSrc.(*Animation); ok {
+		return remapAnimation(anim, swap, src)
+	}
+
+	bounds := imgSrc.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	from := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(from, from.Bounds(), imgSrc, bounds.Min, draw.Src)
+
+	dw, dh := w, h
+	if swap {
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			sx, sy := src(x, y, w, h)
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], from.Pix[from.PixOffset(sx, sy):from.PixOffset(sx, sy)+4])
+		}
+	}
+
+	return dst
+}
+
+// remapAnimation moves the pixels of every frame keeping their palettes, the frame rectangles are moved with them.
+func remapAnimation(anim *Animation, swap bool, src func(x, y, w, h int) (int, int)) *Animation {
+	w, h := anim.Config.Width, anim.Config.Height
+	dw, dh := w, h
+	if swap {
+		dw, dh = h, w
+	}
+
+	remapped := *anim.GIF
+	remapped.Image = make([]*image.Paletted, len(anim.Image))
+	remapped.Config.Width, remapped.Config.Height = dw, dh
+
+	for i, frame := range anim.Image {
+		bounds := frame.Bounds()
+
+		var rect image.Rectangle
+		for y := 0; y < dh; y++ {
+			for x := 0; x < dw; x++ {
+				if sx, sy := src(x, y, w, h); image.Pt(sx, sy).In(bounds) {
+					rect = rect.Union(image.Rect(x, y, x+1, y+1))
+				}
+			}
+		}
+		if rect.Empty() {
+			rect = image.Rect(0, 0, 1, 1)
+		}
+
+		dst := image.NewPaletted(rect, frame.Palette)
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				if sx, sy := src(x, y, w, h); image.Pt(sx, sy).In(bounds) {
+					dst.SetColorIndex(x, y, frame.ColorIndexAt(sx, sy))
+				}
+			}
+		}
+		remapped.Image[i] = dst
+	}
+
+	return &Animation{GIF: &remapped}
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"image"
+	"image/color"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyOrientation(t *testing.T) {
+	// The source is 3x2:
+	//   0 1 2
+	//   3 4 5
+	src := image.NewGray(image.Rect(0, 0, 3, 2))
+	copy(src.Pix, []byte{0, 1, 2, 3, 4, 5})
+
+	tests := []struct {
+		orientation int
+		size        image.Point
+		want        []byte
+	}{
+		{orientation: 1, size: image.Pt(3, 2), want: []byte{0, 1, 2, 3, 4, 5}},
+		{orientation: 2, size: image.Pt(3, 2), want: []byte{2, 1, 0, 5, 4, 3}},
+		{orientation: 3, size: image.Pt(3, 2), want: []byte{5, 4, 3, 2, 1, 0}},
+		{orientation: 4, size: image.Pt(3, 2), want: []byte{3, 4, 5, 0, 1, 2}},
+		{orientation: 5, size: image.Pt(2, 3), want: []byte{0, 3, 1, 4, 2, 5}},
+		{orientation: 6, size: image.Pt(2, 3), want: []byte{3, 0, 4, 1, 5, 2}},
+		{orientation: 7, size: image.Pt(2, 3), want: []byte{5, 2, 4, 1, 3, 0}},
+		{orientation: 8, size: image.Pt(2, 3), want: []byte{2, 5, 1, 4, 0, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.orientation), func(t *testing.T) {
+			got := ApplyOrientation(src, tt.orientation)
+			require.Equal(t, tt.size, got.Bounds().Size())
+
+			var pix []byte
+			for y := got.Bounds().Min.Y; y < got.Bounds().Max.Y; y++ {
+				for x := got.Bounds().Min.X; x < got.Bounds().Max.X; x++ {
+					pix = append(pix, color.GrayModel.Convert(got.At(x, y)).(color.Gray).Y)
+				}
+			}
+			require.Equal(t, tt.want, pix)
+		})
+	}
+}
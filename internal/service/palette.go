@@ -0,0 +1,224 @@
+package service
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/alisavch/image-service/internal/models"
+	"github.com/alisavch/image-service/internal/utils"
+
+	"github.com/nfnt/resize"
+)
+
+const (
+	// paletteSampleSize is the longest side of the copy of the image the colors are collected from.
+	paletteSampleSize = 128
+	// paletteIterations is the largest number of k-means rounds that refine the median cut.
+	paletteIterations = 8
+	// paletteMinAlpha is the alpha below which pixels are treated as background and skipped.
+	paletteMinAlpha = 128
+)
+
+// PaletteImage finds the dominant colors of the image, the most common color goes first.
+func (s *ImageService) PaletteImage(palette models.Palette, img image.Image) ([]models.PaletteColor, error) {
+	colors, err := ExtractPalette(img, palette.Colors)
+	if err != nil {
+		return nil, &utils.ProcessingError{Op: utils.ErrPalette, Err: err}
+	}
+	return colors, nil
+}
+
+// ExtractPalette quantizes the image to at most n colors and reports the share of the pixels each of them covers in percent.
+func ExtractPalette(img image.Image, n int) ([]models.PaletteColor, error) {
+	if n < 1 {
+		return nil, utils.ErrPaletteColors
+	}
+
+	pixels := samplePixels(img, paletteSampleSize)
+	if len(pixels) == 0 {
+		return []models.PaletteColor{}, nil
+	}
+
+	centers, counts := quantize(pixels, n)
+
+	coverage := map[string]int{}
+	var order []string
+	for i, center := range centers {
+		hex := fmt.Sprintf("#%02x%02x%02x", center.R, center.G, center.B)
+		if _, ok := coverage[hex]; !ok {
+			order = append(order, hex)
+		}
+		coverage[hex] += counts[i]
+	}
+
+	palette := make([]models.PaletteColor, 0, len(order))
+	for _, hex := range order {
+		if coverage[hex] == 0 {
+			continue
+		}
+		share := float64(coverage[hex]) * 100 / float64(len(pixels))
+		palette = append(palette, models.PaletteColor{Color: hex, Coverage: math.Round(share*100) / 100})
+	}
+	sort.SliceStable(palette, func(i, j int) bool {
+		return palette[i].Coverage > palette[j].Coverage
+	})
+
+	return palette, nil
+}
+
+// samplePixels collects the opaque pixels of a copy of the image no larger than the size.
+// The nearest neighbour keeps the real colors of the image instead of blending them.
+func samplePixels(img image.Image, size int) []color.RGBA {
+	bounds := img.Bounds()
+	if bounds.Dx() > size || bounds.Dy() > size {
+		width, height := uint(size), uint(0)
+		if bounds.Dy() > bounds.Dx() {
+			width, height = 0, uint(size)
+		}
+		img = resize.Resize(width, height, img, resize.NearestNeighbor)
+	}
+
+	rgba := toRGBA(img)
+	pixels := make([]color.RGBA, 0, len(rgba.Pix)/4)
+	for i := 0; i < len(rgba.Pix); i += 4 {
+		if rgba.Pix[i+3] < paletteMinAlpha {
+			continue
+		}
+		c := color.NRGBAModel.Convert(color.RGBA{R: rgba.Pix[i], G: rgba.Pix[i+1], B: rgba.Pix[i+2], A: rgba.Pix[i+3]}).(color.NRGBA)
+		pixels = append(pixels, color.RGBA{R: c.R, G: c.G, B: c.B, A: 255})
+	}
+	return pixels
+}
+
+// quantize splits the pixels into at most n clusters with the median cut and refines them with k-means.
+// It returns the centers of the clusters together with the number of pixels in each of them.
+func quantize(pixels []color.RGBA, n int) ([]color.RGBA, []int) {
+	centers := medianCut(pixels, n)
+	assignment := make([]int, len(pixels))
+
+	for round := 0; round < paletteIterations; round++ {
+		changed := false
+		for i, p := range pixels {
+			if nearest := nearestColor(centers, p); nearest != assignment[i] {
+				assignment[i], changed = nearest, true
+			}
+		}
+		if round > 0 && !changed {
+			break
+		}
+
+		sums := make([][3]int, len(centers))
+		counts := make([]int, len(centers))
+		for i, p := range pixels {
+			c := assignment[i]
+			sums[c][0] += int(p.R)
+			sums[c][1] += int(p.G)
+			sums[c][2] += int(p.B)
+			counts[c]++
+		}
+		for c := range centers {
+			if counts[c] == 0 {
+				continue
+			}
+			centers[c] = color.RGBA{
+				R: uint8((sums[c][0] + counts[c]/2) / counts[c]),
+				G: uint8((sums[c][1] + counts[c]/2) / counts[c]),
+				B: uint8((sums[c][2] + counts[c]/2) / counts[c]),
+				A: 255,
+			}
+		}
+	}
+
+	counts := make([]int, len(centers))
+	for i, p := range pixels {
+		assignment[i] = nearestColor(centers, p)
+		counts[assignment[i]]++
+	}
+	return centers, counts
+}
+
+// medianCut splits the box with the widest channel range at the median until there are n boxes and returns their average colors.
+func medianCut(pixels []color.RGBA, n int) []color.RGBA {
+	boxes := [][]color.RGBA{append([]color.RGBA(nil), pixels...)}
+
+	for len(boxes) < n {
+		widest, channel, span := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			c, r := widestChannel(box)
+			if r > span {
+				widest, channel, span = i, c, r
+			}
+		}
+		if widest < 0 {
+			break
+		}
+
+		box := boxes[widest]
+		sort.SliceStable(box, func(i, j int) bool {
+			return channelValue(box[i], channel) < channelValue(box[j], channel)
+		})
+		median := len(box) / 2
+		boxes[widest] = box[:median]
+		boxes = append(boxes, box[median:])
+	}
+
+	centers := make([]color.RGBA, len(boxes))
+	for i, box := range boxes {
+		var r, g, b int
+		for _, p := range box {
+			r, g, b = r+int(p.R), g+int(p.G), b+int(p.B)
+		}
+		count := len(box)
+		centers[i] = color.RGBA{R: uint8((r + count/2) / count), G: uint8((g + count/2) / count), B: uint8((b + count/2) / count), A: 255}
+	}
+	return centers
+}
+
+func widestChannel(box []color.RGBA) (int, int) {
+	channel, span := 0, 0
+	for c := 0; c < 3; c++ {
+		low, high := 255, 0
+		for _, p := range box {
+			v := channelValue(p, c)
+			low, high = minInt(low, v), maxInt(high, v)
+		}
+		if high-low > span {
+			channel, span = c, high-low
+		}
+	}
+	return channel, span
+}
+
+func channelValue(c color.RGBA, channel int) int {
+	switch channel {
+	case 0:
+		return int(c.R)
+	case 1:
+		return int(c.G)
+	}
+	return int(c.B)
+}
+
+func nearestColor(centers []color.RGBA, p color.RGBA) int {
+	nearest, best := 0, math.MaxInt32
+	for i, c := range centers {
+		dr, dg, db := int(p.R)-int(c.R), int(p.G)-int(c.G), int(p.B)-int(c.B)
+		if distance := dr*dr + dg*dg + db*db; distance < best {
+			nearest, best = i, distance
+		}
+	}
+	return nearest
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
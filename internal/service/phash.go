@@ -0,0 +1,32 @@
+package service
+
+import (
+	"image"
+	"image/draw"
+
+	"github.com/nfnt/resize"
+)
+
+// MaxHashDistance is the largest Hamming distance between two perceptual hashes.
+const MaxHashDistance = 64
+
+// DifferenceHash computes the 64 bit dHash of the image.
+//
+// The image is reduced to 9x8 gray pixels and every bit tells whether a pixel is brighter than its right neighbour,
+// so the hash survives resizing, recompression and small color changes.
+func DifferenceHash(imgSrc image.Image) uint64 {
+	small := resize.Resize(9, 8, imgSrc, resize.Bilinear)
+	gray := image.NewGray(image.Rect(0, 0, 9, 8))
+	draw.Draw(gray, gray.Bounds(), small, small.Bounds().Min, draw.Src)
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if gray.GrayAt(x, y).Y > gray.GrayAt(x+1, y).Y {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
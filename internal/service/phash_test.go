@@ -0,0 +1,71 @@
+package service
+
+import (
+	"image"
+	"image/color"
+	"math/bits"
+	"testing"
+
+	"github.com/nfnt/resize"
+	"github.com/stretchr/testify/require"
+)
+
+// hashDistance is the number of bits two hashes differ in, the repository computes it the same way.
+func hashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// newCheckerboard draws a checkerboard with squares of the size.
+func newCheckerboard(width, height, square int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if (x/square+y/square)%2 == 0 {
+				img.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+	return img
+}
+
+func TestDifferenceHash(t *testing.T) {
+	gradient := newGradient(320, 240, 255, 0)
+	hash := DifferenceHash(gradient)
+
+	tests := []struct {
+		name        string
+		img         image.Image
+		maxDistance int
+		minDistance int
+	}{
+		{
+			name:        "Same image",
+			img:         gradient,
+			maxDistance: 0,
+		},
+		{
+			name:        "Resized copy",
+			img:         resize.Resize(160, 120, gradient, resize.Bilinear),
+			maxDistance: 4,
+		},
+		{
+			name:        "Brighter copy",
+			img:         newGradient(320, 240, 255, 40),
+			maxDistance: 4,
+		},
+		{
+			name:        "Another image",
+			img:         newCheckerboard(320, 240, 40),
+			maxDistance: MaxHashDistance,
+			minDistance: 16,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			distance := hashDistance(hash, DifferenceHash(tt.img))
+			require.LessOrEqual(t, distance, tt.maxDistance)
+			require.GreaterOrEqual(t, distance, tt.minDistance)
+		})
+	}
+}
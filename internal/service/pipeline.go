@@ -0,0 +1,47 @@
+package service
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/alisavch/image-service/internal/models"
+	"github.com/alisavch/image-service/internal/utils"
+)
+
+// RunPipeline applies the steps to the image one after another in memory.
+func RunPipeline(imgSrc image.Image, steps []models.Step) (image.Image, error) {
+	m := imgSrc
+	for i, step := range steps {
+		var err error
+		switch step.Operation {
+		case models.ResizeStep:
+			m, err = ResizeImage(m, step.Resize)
+		case models.CropStep:
+			m, err = CropImage(m, step.Crop)
+		case models.TransformStep:
+			m, err = TransformImage(m, step.Transform)
+		case models.GrayscaleStep:
+			m = Grayscale(m)
+		case models.FilterStep:
+			m, err = ApplyFilters(m, step.Filters)
+		case models.WatermarkStep:
+			m, err = ApplyWatermark(m, step.Watermark)
+		case models.ConvertStep:
+		default:
+			err = utils.ErrPipelineStep
+		}
+		if err != nil {
+			return nil, fmt.Errorf("step %d %s: %w", i+1, step.Operation, err)
+		}
+	}
+
+	return m, nil
+}
+
+// PipelineFormat returns the format set by the convert step at the end of the pipeline.
+func PipelineFormat(steps []models.Step) (string, bool) {
+	if len(steps) == 0 || steps[len(steps)-1].Operation != models.ConvertStep {
+		return "", false
+	}
+	return steps[len(steps)-1].Encoding.Format, true
+}
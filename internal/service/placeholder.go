@@ -0,0 +1,192 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"strings"
+
+	"github.com/alisavch/image-service/internal/models"
+	"github.com/alisavch/image-service/internal/utils"
+
+	"github.com/nfnt/resize"
+)
+
+const (
+	// PlaceholderWidth is the width of the low quality placeholder.
+	PlaceholderWidth = 16
+	// BlurHashComponentsX and BlurHashComponentsY are the numbers of the horizontal and vertical components of the BlurHash.
+	BlurHashComponentsX = 4
+	BlurHashComponentsY = 3
+	// blurHashSampleSize is the longest side of the copy of the image the BlurHash is computed on.
+	blurHashSampleSize = 64
+	// placeholderQuality is the JPEG quality of the placeholder of an opaque image.
+	placeholderQuality = 50
+)
+
+const base83 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// FillInThePlaceholders fills the BlurHash and the low quality placeholder of the resulting image.
+func FillInThePlaceholders(result models.Image, img image.Image) (models.Image, error) {
+	hash, err := BlurHash(img, BlurHashComponentsX, BlurHashComponentsY)
+	if err != nil {
+		return models.Image{}, err
+	}
+	placeholder, err := Placeholder(img)
+	if err != nil {
+		return models.Image{}, err
+	}
+
+	result.BlurHash = hash
+	result.Placeholder = placeholder
+	return result, nil
+}
+
+// Placeholder scales the image down to the placeholder width and returns it as a data URI.
+// Opaque images are written as JPEG, the others as PNG to keep the transparency.
+func Placeholder(img image.Image) (string, error) {
+	width := PlaceholderWidth
+	if size := img.Bounds().Dx(); size < width {
+		width = maxInt(size, 1)
+	}
+	small := toRGBA(resize.Resize(uint(width), 0, img, resize.Bilinear))
+
+	var buf bytes.Buffer
+	mime := "image/jpeg"
+	if small.Opaque() {
+		if err := jpeg.Encode(&buf, small, &jpeg.Options{Quality: placeholderQuality}); err != nil {
+			return "", err
+		}
+	} else {
+		mime = "image/png"
+		if err := (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(&buf, small); err != nil {
+			return "", err
+		}
+	}
+
+	return "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// BlurHash encodes the image into a BlurHash string with the number of components along each axis.
+//
+// The components are computed on a downscaled copy, it gives the same hash at a fraction of the cost.
+func BlurHash(img image.Image, componentsX, componentsY int) (string, error) {
+	if componentsX < 1 || componentsX > 9 || componentsY < 1 || componentsY > 9 {
+		return "", utils.ErrPlaceholder
+	}
+
+	size := img.Bounds().Size()
+	if size.X == 0 || size.Y == 0 {
+		return "", utils.ErrPlaceholder
+	}
+	scale := math.Min(1, float64(blurHashSampleSize)/float64(maxInt(size.X, size.Y)))
+	sample := toRGBA(img)
+	if scale < 1 {
+		sample = toRGBA(resize.Resize(uint(math.Max(1, math.Round(float64(size.X)*scale))), uint(math.Max(1, math.Round(float64(size.Y)*scale))), img, resize.Bilinear))
+	}
+
+	factors := blurHashFactors(sample, componentsX, componentsY)
+	dc, ac := factors[0], factors[1:]
+
+	var hash strings.Builder
+	hash.WriteString(encode83((componentsX-1)+(componentsY-1)*9, 1))
+
+	maxValue := 1.0
+	if len(ac) > 0 {
+		var actual float64
+		for _, factor := range ac {
+			actual = math.Max(actual, math.Max(math.Abs(factor[0]), math.Max(math.Abs(factor[1]), math.Abs(factor[2]))))
+		}
+		quantised := int(math.Max(0, math.Min(82, math.Floor(actual*166-0.5))))
+		maxValue = float64(quantised+1) / 166
+		hash.WriteString(encode83(quantised, 1))
+	} else {
+		hash.WriteString(encode83(0, 1))
+	}
+
+	hash.WriteString(encode83(linearToSRGB(dc[0])<<16+linearToSRGB(dc[1])<<8+linearToSRGB(dc[2]), 4))
+	for _, factor := range ac {
+		hash.WriteString(encode83(encodeAC(factor, maxValue), 2))
+	}
+
+	return hash.String(), nil
+}
+
+// blurHashFactors projects the linear colors of the image on the cosine basis, the factor of the average color goes first.
+func blurHashFactors(img *image.RGBA, componentsX, componentsY int) [][3]float64 {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+
+	linear := make([][3]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := img.PixOffset(x, y)
+			linear[y*width+x] = [3]float64{sRGBToLinear(img.Pix[i]), sRGBToLinear(img.Pix[i+1]), sRGBToLinear(img.Pix[i+2])}
+		}
+	}
+
+	factors := make([][3]float64, 0, componentsX*componentsY)
+	for j := 0; j < componentsY; j++ {
+		for i := 0; i < componentsX; i++ {
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1
+			}
+
+			var factor [3]float64
+			for y := 0; y < height; y++ {
+				basisY := math.Cos(math.Pi * float64(j) * float64(y) / float64(height))
+				for x := 0; x < width; x++ {
+					basis := normalisation * basisY * math.Cos(math.Pi*float64(i)*float64(x)/float64(width))
+					color := linear[y*width+x]
+					factor[0] += basis * color[0]
+					factor[1] += basis * color[1]
+					factor[2] += basis * color[2]
+				}
+			}
+
+			scale := 1 / float64(width*height)
+			factors = append(factors, [3]float64{factor[0] * scale, factor[1] * scale, factor[2] * scale})
+		}
+	}
+
+	return factors
+}
+
+func encodeAC(factor [3]float64, maxValue float64) int {
+	quantise := func(v float64) int {
+		return int(math.Max(0, math.Min(18, math.Floor(signPow(v/maxValue, 0.5)*9+9.5))))
+	}
+	return quantise(factor[0])*19*19 + quantise(factor[1])*19 + quantise(factor[2])
+}
+
+func encode83(value, length int) string {
+	digits := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		digits[i] = base83[value%83]
+		value /= 83
+	}
+	return string(digits)
+}
+
+func sRGBToLinear(value uint8) float64 {
+	v := float64(value) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(value float64) int {
+	v := math.Max(0, math.Min(1, value))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(value, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(value), exp), value)
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/alisavch/image-service/internal/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+// decode83 is the reverse of encode83.
+func decode83(value string) int {
+	var n int
+	for _, c := range value {
+		n = n*83 + strings.IndexRune(base83, c)
+	}
+	return n
+}
+
+// newGradient draws a horizontal gradient from the left color to the right one.
+func newGradient(width, height int, left, right uint8) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		v := uint8(int(left) + (int(right)-int(left))*x/(width-1))
+		for y := 0; y < height; y++ {
+			img.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+	return img
+}
+
+func TestBlurHash(t *testing.T) {
+	t.Run("Solid color", func(t *testing.T) {
+		img := image.NewRGBA(image.Rect(0, 0, 32, 24))
+		draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{R: 255, A: 255}), image.Point{}, draw.Src)
+
+		hash, err := BlurHash(img, 4, 3)
+		require.NoError(t, err)
+		require.Len(t, hash, 4+2*4*3)
+		// The size flag of 4x3 components goes first, the average color follows the maximum of the components.
+		require.Equal(t, "L", hash[:1])
+		require.Equal(t, 0xff0000, decode83(hash[2:6]))
+	})
+
+	tests := []struct {
+		name        string
+		left, right uint8
+		brightLeft  bool
+	}{
+		{name: "Bright on the left", left: 255, right: 0, brightLeft: true},
+		{name: "Bright on the right", left: 0, right: 255, brightLeft: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The image is larger than the sample, the hash is computed on the downscaled copy.
+			hash, err := BlurHash(newGradient(200, 100, tt.left, tt.right), 4, 3)
+			require.NoError(t, err)
+			require.Len(t, hash, 4+2*4*3)
+			require.NotEqual(t, '0', rune(hash[1]))
+
+			// The first horizontal component is positive when the left half is brighter.
+			first := decode83(hash[6:8])
+			for _, channel := range []int{first / (19 * 19), first / 19 % 19, first % 19} {
+				require.Equal(t, tt.brightLeft, channel > 9, "component %d", channel)
+			}
+		})
+	}
+
+	t.Run("Invalid components", func(t *testing.T) {
+		_, err := BlurHash(image.NewGray(image.Rect(0, 0, 8, 8)), 0, 3)
+		require.Equal(t, utils.ErrPlaceholder, err)
+		_, err = BlurHash(image.NewGray(image.Rect(0, 0, 8, 8)), 4, 10)
+		require.Equal(t, utils.ErrPlaceholder, err)
+	})
+
+	t.Run("Empty image", func(t *testing.T) {
+		_, err := BlurHash(image.NewGray(image.Rect(0, 0, 0, 0)), 4, 3)
+		require.Equal(t, utils.ErrPlaceholder, err)
+	})
+}
+
+func TestPlaceholder(t *testing.T) {
+	tests := []struct {
+		name   string
+		img    image.Image
+		mime   string
+		decode func(r *bytes.Reader) (image.Image, error)
+		width  int
+	}{
+		{
+			name:   "Opaque image as JPEG",
+			img:    newGradient(200, 100, 0, 255),
+			mime:   "image/jpeg",
+			decode: func(r *bytes.Reader) (image.Image, error) { return jpeg.Decode(r) },
+			width:  PlaceholderWidth,
+		},
+		{
+			name:   "Transparent image as PNG",
+			img:    image.NewNRGBA(image.Rect(0, 0, 200, 100)),
+			mime:   "image/png",
+			decode: func(r *bytes.Reader) (image.Image, error) { return png.Decode(r) },
+			width:  PlaceholderWidth,
+		},
+		{
+			name:   "Image narrower than the placeholder",
+			img:    newGradient(4, 2, 0, 255),
+			mime:   "image/jpeg",
+			decode: func(r *bytes.Reader) (image.Image, error) { return jpeg.Decode(r) },
+			width:  4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			placeholder, err := Placeholder(tt.img)
+			require.NoError(t, err)
+
+			prefix := "data:" + tt.mime + ";base64,"
+			require.True(t, strings.HasPrefix(placeholder, prefix), placeholder)
+			data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(placeholder, prefix))
+			require.NoError(t, err)
+			img, err := tt.decode(bytes.NewReader(data))
+			require.NoError(t, err)
+			require.Equal(t, tt.width, img.Bounds().Dx())
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/alisavch/image-service/internal/utils"
+)
+
+const (
+	// MinPNGColors and MaxPNGColors are the limits of the palette of a quantized PNG.
+	MinPNGColors = 2
+	MaxPNGColors = 256
+	// quantizeSampleSize is the longest side of the copy of the image the palette is built from.
+	quantizeSampleSize = 256
+)
+
+// QuantizeImage reduces the image to a palette of at most n colors, so the PNG encoder writes it with one byte per pixel.
+//
+// The palette is built with the median cut refined by k-means, the same way the dominant colors are found.
+// Images with transparent pixels spend one entry of the palette on the fully transparent color.
+// The dithering spreads the quantization error with Floyd–Steinberg, it hides the banding of gradients at the cost of a larger file.
+func QuantizeImage(imgSrc image.Image, n int, dither bool) (*image.Paletted, error) {
+	if n < MinPNGColors || n > MaxPNGColors {
+		return nil, utils.ErrPNGColors
+	}
+
+	bounds := imgSrc.Bounds()
+	opaque := isOpaque(imgSrc)
+
+	var palette color.Palette
+	if !opaque {
+		palette = append(palette, color.NRGBA{})
+		n--
+	}
+	if pixels := samplePixels(imgSrc, quantizeSampleSize); len(pixels) > 0 {
+		centers, counts := quantize(pixels, n)
+		for i, center := range centers {
+			if counts[i] > 0 {
+				palette = append(palette, center)
+			}
+		}
+	}
+	if len(palette) == 0 {
+		palette = append(palette, color.Black)
+	}
+
+	dst := image.NewPaletted(bounds, palette)
+	if dither {
+		draw.FloydSteinberg.Draw(dst, bounds, imgSrc, bounds.Min)
+	} else {
+		draw.Draw(dst, bounds, imgSrc, bounds.Min, draw.Src)
+	}
+	return dst, nil
+}
+
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return toRGBA(img).Opaque()
+}
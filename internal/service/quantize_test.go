@@ -0,0 +1,76 @@
+package service
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"testing"
+
+	"github.com/alisavch/image-service/internal/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuantizeImage(t *testing.T) {
+	t.Run("Image with fewer colors than the palette keeps them exactly", func(t *testing.T) {
+		colors := []color.RGBA{{R: 200, G: 30, B: 30, A: 255}, {R: 30, G: 160, B: 40, A: 255}, {R: 20, G: 40, B: 210, A: 255}}
+		img := image.NewRGBA(image.Rect(0, 0, 60, 40))
+		for i, c := range colors {
+			draw.Draw(img, image.Rect(i*20, 0, (i+1)*20, 40), image.NewUniform(c), image.Point{}, draw.Src)
+		}
+
+		quantized, err := QuantizeImage(img, 8, false)
+		require.NoError(t, err)
+		require.Len(t, quantized.Palette, 3)
+		for y := 0; y < 40; y++ {
+			for x := 0; x < 60; x++ {
+				require.Equal(t, img.At(x, y), color.RGBAModel.Convert(quantized.At(x, y)))
+			}
+		}
+	})
+
+	t.Run("Gradient is reduced to the number of colors", func(t *testing.T) {
+		img := newGradient(256, 8, 0, 255)
+
+		for _, dither := range []bool{false, true} {
+			quantized, err := QuantizeImage(img, 4, dither)
+			require.NoError(t, err)
+			require.LessOrEqual(t, len(quantized.Palette), 4)
+			if dither {
+				continue
+			}
+			for x := 0; x < 256; x++ {
+				got := color.GrayModel.Convert(quantized.At(x, 0)).(color.Gray).Y
+				require.InDelta(t, img.GrayAt(x, 0).Y, got, 48, "pixel %d", x)
+			}
+		}
+	})
+
+	t.Run("Transparent pixels keep an entry of the palette", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 20, 20))
+		draw.Draw(img, image.Rect(10, 0, 20, 20), image.NewUniform(color.NRGBA{R: 255, A: 255}), image.Point{}, draw.Src)
+
+		quantized, err := QuantizeImage(img, 2, false)
+		require.NoError(t, err)
+		require.Equal(t, color.Palette{color.NRGBA{}, color.RGBA{R: 255, A: 255}}, quantized.Palette)
+		require.Equal(t, uint8(0), quantized.ColorIndexAt(0, 0))
+		require.Equal(t, uint8(1), quantized.ColorIndexAt(15, 0))
+
+		// The PNG encoder writes the paletted image with one byte per pixel.
+		var buf bytes.Buffer
+		require.NoError(t, png.Encode(&buf, quantized))
+		decoded, err := png.Decode(&buf)
+		require.NoError(t, err)
+		require.IsType(t, &image.Paletted{}, decoded)
+	})
+
+	t.Run("Number of colors out of range", func(t *testing.T) {
+		img := image.NewGray(image.Rect(0, 0, 4, 4))
+		for _, n := range []int{MinPNGColors - 1, MaxPNGColors + 1} {
+			_, err := QuantizeImage(img, n, false)
+			require.Equal(t, utils.ErrPNGColors, err)
+		}
+	})
+}
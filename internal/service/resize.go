@@ -0,0 +1,151 @@
+package service
+
+import (
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/alisavch/image-service/internal/models"
+	"github.com/alisavch/image-service/internal/utils"
+
+	"github.com/nfnt/resize"
+)
+
+// DefaultBackground is the color used to pad the image when no background is set.
+const DefaultBackground = "ffffff"
+
+// geometry describes where the scaled image is placed on the resulting canvas.
+type geometry struct {
+	scaled image.Point
+	canvas image.Point
+	offset image.Point
+}
+
+// ResizeImage places the image into the box according to the resize mode.
+func ResizeImage(imgSrc image.Image, opts models.Resize) (image.Image, error) {
+	geom, err := newGeometry(imgSrc.Bounds().Size(), opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Mode == models.Fill && opts.Gravity == models.Smart {
+		geom.offset = smartFillOffset(imgSrc, geom)
+	}
+
+	if anim, ok := imgSrc.(*Animation); ok {
+		return resizeAnimation(anim, geom, opts)
+	}
+
+	m := resize.Resize(uint(geom.scaled.X), uint(geom.scaled.Y), imgSrc, resize.Lanczos3)
+	if geom.canvas == geom.scaled {
+		return m, nil
+	}
+
+	dst := image.NewRGBA(image.Rectangle{Max: geom.canvas})
+	op := draw.Src
+	if opts.Mode == models.Pad {
+		background, err := ParseHexColor(opts.Background)
+		if err != nil {
+			return nil, err
+		}
+		draw.Draw(dst, dst.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+		op = draw.Over
+	}
+	draw.Draw(dst, image.Rectangle{Min: geom.offset, Max: geom.offset.Add(geom.scaled)}, m, m.Bounds().Min, op)
+
+	return dst, nil
+}
+
+func newGeometry(src image.Point, opts models.Resize) (geometry, error) {
+	width, height := opts.Width, opts.Height
+	if width < 0 || height < 0 || src.X == 0 || src.Y == 0 {
+		return geometry{}, utils.ErrIncorrectRatio
+	}
+
+	scaleX := float64(width) / float64(src.X)
+	scaleY := float64(height) / float64(src.Y)
+
+	switch opts.Mode {
+	case models.Fit, "":
+		var scale float64
+		switch {
+		case width == 0 && height == 0:
+			return geometry{}, utils.ErrIncorrectRatio
+		case height == 0:
+			scale = scaleX
+		case width == 0:
+			scale = scaleY
+		default:
+			scale = math.Min(scaleX, scaleY)
+		}
+		// Fit keeps the rule of the compression, the image is never enlarged.
+		// The other modes return the box itself, so they scale the image up when it is smaller.
+		if scale > 1 {
+			return geometry{}, utils.ErrIncorrectRatio
+		}
+		scaled := scaleSize(src, scale)
+		return geometry{scaled: scaled, canvas: scaled}, nil
+
+	case models.Exact:
+		if width == 0 || height == 0 {
+			return geometry{}, utils.ErrMissingSize
+		}
+		box := image.Pt(width, height)
+		return geometry{scaled: box, canvas: box}, nil
+
+	case models.Fill, models.Pad:
+		if width == 0 || height == 0 {
+			return geometry{}, utils.ErrMissingSize
+		}
+		scale := math.Min(scaleX, scaleY)
+		if opts.Mode == models.Fill {
+			scale = math.Max(scaleX, scaleY)
+		}
+		box := image.Pt(width, height)
+		scaled := scaleSize(src, scale)
+		if opts.Mode == models.Fill {
+			scaled.X, scaled.Y = maxInt(scaled.X, width), maxInt(scaled.Y, height)
+		}
+		offset := box.Sub(scaled).Div(2)
+		if opts.Mode == models.Fill && opts.Gravity != models.Smart {
+			kept, err := gravityOffset(scaled.Sub(box), opts.Gravity)
+			if err != nil {
+				return geometry{}, err
+			}
+			offset = image.Point{}.Sub(kept)
+		}
+		return geometry{scaled: scaled, canvas: box, offset: offset}, nil
+	}
+
+	return geometry{}, utils.ErrResizeMode
+}
+
+// smartFillOffset searches the box on the source image, so the analysis does not depend on the scaled size.
+func smartFillOffset(imgSrc image.Image, geom geometry) image.Point {
+	src := imgSrc.Bounds().Size()
+	scaleX := float64(src.X) / float64(geom.scaled.X)
+	scaleY := float64(src.Y) / float64(geom.scaled.Y)
+	window := image.Pt(
+		clampInt(int(math.Round(float64(geom.canvas.X)*scaleX)), 1, src.X),
+		clampInt(int(math.Round(float64(geom.canvas.Y)*scaleY)), 1, src.Y),
+	)
+
+	kept := smartOffset(imgSrc, window)
+	return image.Pt(
+		-int(math.Round(float64(kept.X)/scaleX)),
+		-int(math.Round(float64(kept.Y)/scaleY)),
+	)
+}
+
+func scaleSize(src image.Point, scale float64) image.Point {
+	return image.Pt(
+		maxInt(int(math.Round(float64(src.X)*scale)), 1),
+		maxInt(int(math.Round(float64(src.Y)*scale)), 1),
+	)
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
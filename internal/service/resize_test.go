@@ -0,0 +1,75 @@
+package service
+
+import (
+	"image"
+	"testing"
+
+	"github.com/alisavch/image-service/internal/models"
+	"github.com/alisavch/image-service/internal/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewGeometry(t *testing.T) {
+	src := image.Pt(200, 100)
+
+	tests := []struct {
+		name string
+		opts models.Resize
+		want geometry
+		err  error
+	}{
+		{
+			name: "Fit scales down by the width",
+			opts: models.Resize{Width: 100, Mode: models.Fit},
+			want: geometry{scaled: image.Pt(100, 50), canvas: image.Pt(100, 50)},
+		},
+		{
+			name: "Fit does not enlarge the image",
+			opts: models.Resize{Width: 400, Height: 400, Mode: models.Fit},
+			err:  utils.ErrIncorrectRatio,
+		},
+		{
+			name: "Fit without a box",
+			opts: models.Resize{Mode: models.Fit},
+			err:  utils.ErrIncorrectRatio,
+		},
+		{
+			name: "Pad enlarges the image to the box",
+			opts: models.Resize{Width: 400, Height: 400, Mode: models.Pad},
+			want: geometry{scaled: image.Pt(400, 200), canvas: image.Pt(400, 400), offset: image.Pt(0, 100)},
+		},
+		{
+			name: "Fill enlarges the image to cover the box",
+			opts: models.Resize{Width: 400, Height: 400, Mode: models.Fill},
+			want: geometry{scaled: image.Pt(800, 400), canvas: image.Pt(400, 400), offset: image.Pt(-200, 0)},
+		},
+		{
+			name: "Exact stretches the image to the box",
+			opts: models.Resize{Width: 300, Height: 300, Mode: models.Exact},
+			want: geometry{scaled: image.Pt(300, 300), canvas: image.Pt(300, 300)},
+		},
+		{
+			name: "Pad without height",
+			opts: models.Resize{Width: 100, Mode: models.Pad},
+			err:  utils.ErrMissingSize,
+		},
+		{
+			name: "Unsupported mode",
+			opts: models.Resize{Width: 100, Height: 100, Mode: "cover"},
+			err:  utils.ErrResizeMode,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newGeometry(src, tt.opts)
+			if tt.err != nil {
+				require.Equal(t, tt.err, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"github.com/alisavch/image-service/internal/repository"
+	"github.com/alisavch/image-service/internal/storage"
 )
 
 // Service contains interfaces.
@@ -11,9 +12,9 @@ type Service struct {
 }
 
 // NewService configures Service.
-func NewService(repo *repository.Repository, bucket S3Bucket) *Service {
+func NewService(repo *repository.Repository, store storage.Storage) *Service {
 	return &Service{
 		AuthService:  NewAuthService(repo.AuthRepository),
-		ImageService: NewImageService(repo.ImageRepository, bucket),
+		ImageService: NewImageService(repo.ImageRepository, store),
 	}
 }
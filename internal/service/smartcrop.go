@@ -0,0 +1,147 @@
+package service
+
+import (
+	"image"
+	"math"
+
+	"github.com/nfnt/resize"
+)
+
+const (
+	// smartAnalysisSize is the longest side of the copy of the image the crop window is searched on.
+	smartAnalysisSize = 256
+	// smartSkinWeight, smartSaturationWeight and smartEdgeWeight balance the parts of the energy of a pixel.
+	smartSkinWeight       = 1.8
+	smartSaturationWeight = 0.3
+	smartEdgeWeight       = 1.0
+	// smartSkinThreshold is the largest distance of the normalized color to the skin color that still counts as skin.
+	smartSkinThreshold = 0.2
+	// smartSaturationThreshold is the saturation below which colors are treated as background.
+	smartSaturationThreshold = 0.4
+	// smartTolerance is the difference of the energy of two windows that is treated as rounding.
+	smartTolerance = 1e-6
+)
+
+// skinColor is the normalized color of the skin the skin tone heuristic looks for.
+var skinColor = [3]float64{0.78, 0.57, 0.44}
+
+// smartOffset finds the top left corner of the window of the image with the largest energy.
+//
+// The energy of a pixel is the sum of its edges, skin tone and saturation, it is computed on a downscaled copy of the image.
+// Windows with the same energy are resolved towards the center, so a featureless image is cropped like the center gravity does.
+func smartOffset(imgSrc image.Image, window image.Point) image.Point {
+	size := imgSrc.Bounds().Size()
+	free := size.Sub(window)
+	if free.X <= 0 && free.Y <= 0 {
+		return image.Point{}
+	}
+
+	scale := math.Min(1, float64(smartAnalysisSize)/float64(maxInt(size.X, size.Y)))
+	small := image.Pt(maxInt(int(math.Round(float64(size.X)*scale)), 1), maxInt(int(math.Round(float64(size.Y)*scale)), 1))
+	sample := imgSrc
+	if small != size {
+		sample = resize.Resize(uint(small.X), uint(small.Y), imgSrc, resize.Bilinear)
+	}
+
+	table := summedArea(energyMap(toRGBA(sample)), small)
+	win := image.Pt(
+		clampInt(int(math.Round(float64(window.X)*scale)), 1, small.X),
+		clampInt(int(math.Round(float64(window.Y)*scale)), 1, small.Y),
+	)
+
+	center := small.Sub(win)
+	best, bestScore, bestDistance := image.Point{}, math.Inf(-1), math.Inf(1)
+	for y := 0; y <= small.Y-win.Y; y++ {
+		for x := 0; x <= small.X-win.X; x++ {
+			score := windowSum(table, small.X+1, image.Rect(x, y, x+win.X, y+win.Y))
+			distance := math.Abs(float64(2*x-center.X)) + math.Abs(float64(2*y-center.Y))
+			if score > bestScore+smartTolerance || (math.Abs(score-bestScore) <= smartTolerance && distance < bestDistance) {
+				best, bestScore, bestDistance = image.Pt(x, y), score, distance
+			}
+		}
+	}
+
+	return image.Pt(
+		clampInt(int(math.Round(float64(best.X)/scale)), 0, maxInt(free.X, 0)),
+		clampInt(int(math.Round(float64(best.Y)/scale)), 0, maxInt(free.Y, 0)),
+	)
+}
+
+// energyMap scores every pixel by how likely it belongs to the subject of the image.
+func energyMap(img *image.RGBA) []float64 {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	lum := make([]float64, width*height)
+	energy := make([]float64, width*height)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := img.PixOffset(x, y)
+			r, g, b := float64(img.Pix[i])/255, float64(img.Pix[i+1])/255, float64(img.Pix[i+2])/255
+			l := 0.299*r + 0.587*g + 0.114*b
+			lum[y*width+x] = l
+			energy[y*width+x] = smartSkinWeight*skinScore(r, g, b, l) + smartSaturationWeight*saturationScore(r, g, b, l)
+		}
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			center := lum[y*width+x]
+			edge := 4 * center
+			edge -= lum[y*width+clampInt(x-1, 0, width-1)]
+			edge -= lum[y*width+clampInt(x+1, 0, width-1)]
+			edge -= lum[clampInt(y-1, 0, height-1)*width+x]
+			edge -= lum[clampInt(y+1, 0, height-1)*width+x]
+			energy[y*width+x] += smartEdgeWeight * math.Abs(edge)
+		}
+	}
+
+	return energy
+}
+
+// skinScore is close to one for colors near the skin tone with a moderate brightness.
+func skinScore(r, g, b, l float64) float64 {
+	magnitude := math.Sqrt(r*r + g*g + b*b)
+	if magnitude == 0 || l < 0.2 || l > 0.95 {
+		return 0
+	}
+
+	dr, dg, db := r/magnitude-skinColor[0], g/magnitude-skinColor[1], b/magnitude-skinColor[2]
+	distance := math.Sqrt(dr*dr + dg*dg + db*db)
+	if distance >= smartSkinThreshold {
+		return 0
+	}
+	return 1 - distance/smartSkinThreshold
+}
+
+// saturationScore grows with the saturation of vivid colors that are neither too dark nor too bright.
+func saturationScore(r, g, b, l float64) float64 {
+	high, low := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	if high == 0 || l < 0.05 || l > 0.9 {
+		return 0
+	}
+
+	saturation := (high - low) / high
+	if saturation <= smartSaturationThreshold {
+		return 0
+	}
+	return (saturation - smartSaturationThreshold) / (1 - smartSaturationThreshold)
+}
+
+// summedArea builds the table where every cell is the sum of the energy above and to the left of it.
+func summedArea(energy []float64, size image.Point) []float64 {
+	stride := size.X + 1
+	table := make([]float64, stride*(size.Y+1))
+	for y := 0; y < size.Y; y++ {
+		var row float64
+		for x := 0; x < size.X; x++ {
+			row += energy[y*size.X+x]
+			table[(y+1)*stride+x+1] = table[y*stride+x+1] + row
+		}
+	}
+	return table
+}
+
+func windowSum(table []float64, stride int, rect image.Rectangle) float64 {
+	return table[rect.Max.Y*stride+rect.Max.X] - table[rect.Min.Y*stride+rect.Max.X] -
+		table[rect.Max.Y*stride+rect.Min.X] + table[rect.Min.Y*stride+rect.Min.X]
+}
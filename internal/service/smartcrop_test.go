@@ -0,0 +1,77 @@
+package service
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/alisavch/image-service/internal/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newSubject draws a saturated red square on a white image of the size.
+func newSubject(size image.Point, subject image.Rectangle) image.Image {
+	img := image.NewRGBA(image.Rectangle{Max: size})
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(img, subject, image.NewUniform(color.RGBA{R: 220, G: 20, B: 20, A: 255}), image.Point{}, draw.Src)
+	return img
+}
+
+func TestSmartOffset(t *testing.T) {
+	t.Run("Featureless image is cropped in the center", func(t *testing.T) {
+		img := image.NewRGBA(image.Rect(0, 0, 100, 50))
+		draw.Draw(img, img.Bounds(), image.NewUniform(color.Gray{Y: 128}), image.Point{}, draw.Src)
+
+		require.Equal(t, image.Pt(25, 0), smartOffset(img, image.Pt(50, 50)))
+	})
+
+	tests := []struct {
+		name    string
+		size    image.Point
+		window  image.Point
+		subject image.Rectangle
+	}{
+		{
+			name:    "Subject on the right",
+			size:    image.Pt(200, 100),
+			window:  image.Pt(100, 100),
+			subject: image.Rect(140, 30, 180, 70),
+		},
+		{
+			name:    "Subject at the top left",
+			size:    image.Pt(200, 100),
+			window:  image.Pt(80, 80),
+			subject: image.Rect(5, 5, 35, 35),
+		},
+		{
+			name:    "Image larger than the analysis size",
+			size:    image.Pt(1000, 400),
+			window:  image.Pt(300, 300),
+			subject: image.Rect(700, 100, 800, 200),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := smartOffset(newSubject(tt.size, tt.subject), tt.window)
+
+			window := image.Rectangle{Min: got, Max: got.Add(tt.window)}
+			require.True(t, tt.subject.In(window), "subject %v is out of the window %v", tt.subject, window)
+		})
+	}
+}
+
+func TestResizeImage_SmartFill(t *testing.T) {
+	img := newSubject(image.Pt(200, 100), image.Rect(150, 40, 190, 60))
+
+	resized, err := ResizeImage(img, models.Resize{Width: 50, Height: 50, Mode: models.Fill, Gravity: models.Smart})
+	require.NoError(t, err)
+	require.Equal(t, image.Pt(50, 50), resized.Bounds().Size())
+
+	// The subject is kept, so the right part of the result is red.
+	r, g, _, _ := resized.At(40, 25).RGBA()
+	require.Greater(t, r>>8, uint32(200))
+	require.Less(t, g>>8, uint32(60))
+}
@@ -0,0 +1,381 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"mime"
+	"path"
+	"sync"
+
+	"github.com/alisavch/image-service/internal/utils"
+)
+
+const (
+	// SniffSize is the length of the beginning of an upload its format and dimensions are read from.
+	SniffSize = 1 << 20
+	// sniffHeaderSize is the length of the beginning of the file that is searched for markup and scripts.
+	sniffHeaderSize = 1024
+)
+
+// scriptMarkers are the fragments of markup and scripts a browser or an interpreter could pick up from an image.
+var scriptMarkers = [][]byte{
+	[]byte("<script"),
+	[]byte("<html"),
+	[]byte("<body"),
+	[]byte("<iframe"),
+	[]byte("<svg"),
+	[]byte("<?php"),
+}
+
+// SniffFormat identifies the format of the uploaded file from its beginning and checks it against what the client declared.
+//
+// The format is found by the magic bytes and confirmed by image.DecodeConfig, the content type and the extension
+// sent by the client must name the same format when they are set. Files that carry markup or scripts in the header
+// are rejected, they can be read as another format too. The head holds up to SniffSize bytes of the file, a file whose
+// header is not within it, like a TIFF that stores the header after the pixels, is rejected, so the dimensions are
+// always checked before the file is read.
+func SniffFormat(head []byte, contentType, filename string) (Format, image.Config, error) {
+	detected, ok := matchMagic(head)
+	if !ok {
+		return Format{}, image.Config{}, utils.ErrAllowedFormat
+	}
+
+	cfg, name, err := image.DecodeConfig(bytes.NewReader(head))
+	if err != nil || name != detected.Name {
+		return Format{}, image.Config{}, utils.ErrDecode
+	}
+
+	if err := checkDeclaredType(detected, contentType); err != nil {
+		return Format{}, image.Config{}, err
+	}
+	if err := checkDeclaredExtension(detected, filename); err != nil {
+		return Format{}, image.Config{}, err
+	}
+	if err := checkMarkup(detected, head); err != nil {
+		return Format{}, image.Config{}, err
+	}
+	return detected, cfg, nil
+}
+
+// matchMagic finds the format whose signature the data starts with.
+func matchMagic(data []byte) (Format, bool) {
+	for key, f := range formats {
+		if key != f.Name {
+			continue
+		}
+		for _, magic := range f.Magic {
+			if hasMagic(data, magic) {
+				return f, true
+			}
+		}
+	}
+	return Format{}, false
+}
+
+func hasMagic(data []byte, magic string) bool {
+	if len(data) < len(magic) {
+		return false
+	}
+	for i := 0; i < len(magic); i++ {
+		if magic[i] != '?' && magic[i] != data[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// checkDeclaredType compares the content type sent by the client, a missing or generic type is not checked.
+func checkDeclaredType(detected Format, contentType string) error {
+	if contentType == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return utils.ErrAllowedFormat
+	}
+	if mediaType == "application/octet-stream" {
+		return nil
+	}
+
+	declared, err := LookupContentType(mediaType)
+	if err != nil {
+		return utils.ErrAllowedFormat
+	}
+	if declared.Name != detected.Name {
+		return &utils.ContentMismatchError{Declared: mediaType, Detected: detected.Name}
+	}
+	return nil
+}
+
+// checkDeclaredExtension compares the extension of the file name, a name without extension is not checked.
+func checkDeclaredExtension(detected Format, filename string) error {
+	ext := path.Ext(filename)
+	if ext == "" {
+		return nil
+	}
+
+	declared, err := LookupFormat(ext)
+	if err != nil || declared.Name != detected.Name {
+		return &utils.ContentMismatchError{Declared: ext, Detected: detected.Name}
+	}
+	return nil
+}
+
+// checkMarkup looks for markup and scripts in the header.
+func checkMarkup(detected Format, head []byte) error {
+	header := bytes.ToLower(head[:minInt(len(head), sniffHeaderSize)])
+	for _, marker := range scriptMarkers {
+		if bytes.Contains(header, marker) {
+			return &utils.PolyglotError{Format: detected.Name, Reason: fmt.Sprintf("markup %q in the header", marker)}
+		}
+	}
+	return nil
+}
+
+// trailerScanner follows the image structure while the file is written to it and counts the data after the end.
+// The structure is read by a goroutine from a pipe, so the file is never held in memory.
+type trailerScanner struct {
+	pw   *io.PipeWriter
+	done chan int64
+	once sync.Once
+	n    int64
+}
+
+func newTrailerScanner(end func(r *bufio.Reader) bool) *trailerScanner {
+	pr, pw := io.Pipe()
+	t := &trailerScanner{pw: pw, done: make(chan int64, 1)}
+	go func() {
+		r := bufio.NewReader(pr)
+		var n int64
+		if end(r) {
+			n = countTrailing(r)
+		}
+		_, _ = io.Copy(ioutil.Discard, r)
+		t.done <- n
+	}()
+	return t
+}
+
+// Write passes the next part of the file to the scanner.
+func (t *trailerScanner) Write(p []byte) (int, error) {
+	return t.pw.Write(p)
+}
+
+// Close ends the file and returns the number of bytes after the end of the image.
+// Zero bytes after the end are treated as padding.
+func (t *trailerScanner) Close() int64 {
+	return t.stop(nil)
+}
+
+// Abort stops the scanner before the end of the file.
+func (t *trailerScanner) Abort() {
+	t.stop(io.ErrClosedPipe)
+}
+
+func (t *trailerScanner) stop(err error) int64 {
+	t.once.Do(func() {
+		_ = t.pw.CloseWithError(err)
+		t.n = <-t.done
+	})
+	return t.n
+}
+
+// countTrailing reads the rest of the file up to its last non-zero byte.
+func countTrailing(r io.Reader) int64 {
+	var read, last int64
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		for i := 0; i < n; i++ {
+			if buf[i] != 0 {
+				last = read + int64(i) + 1
+			}
+		}
+		read += int64(n)
+		if err != nil {
+			return last
+		}
+	}
+}
+
+// jpegEnd walks the segments and the entropy coded data up to the end of image marker.
+func jpegEnd(r *bufio.Reader) bool {
+	if _, err := r.Discard(2); err != nil {
+		return false
+	}
+	for {
+		b, err := r.ReadByte()
+		if err != nil || b != 0xFF {
+			return false
+		}
+		marker, err := r.ReadByte()
+		for err == nil && marker == 0xFF {
+			marker, err = r.ReadByte()
+		}
+		if err != nil {
+			return false
+		}
+
+		switch {
+		case marker == 0xD9:
+			return true
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			continue
+		}
+
+		var length [2]byte
+		if _, err := io.ReadFull(r, length[:]); err != nil {
+			return false
+		}
+		n := int(binary.BigEndian.Uint16(length[:]))
+		if n < 2 {
+			return false
+		}
+		if _, err := r.Discard(n - 2); err != nil {
+			return false
+		}
+
+		if marker == 0xDA && !skipEntropyCoded(r) {
+			return false
+		}
+	}
+}
+
+// skipEntropyCoded reads the scan data up to the next marker, the stuffed zero bytes and the restart markers belong to the data.
+func skipEntropyCoded(r *bufio.Reader) bool {
+	for {
+		if _, err := r.Peek(2); err != nil {
+			return false
+		}
+		buf, _ := r.Peek(r.Buffered())
+		i := bytes.IndexByte(buf, 0xFF)
+		switch {
+		case i < 0:
+			_, _ = r.Discard(len(buf))
+		case i+1 == len(buf):
+			_, _ = r.Discard(i)
+		case buf[i+1] != 0x00 && (buf[i+1] < 0xD0 || buf[i+1] > 0xD7):
+			_, _ = r.Discard(i)
+			return true
+		default:
+			_, _ = r.Discard(i + 2)
+		}
+	}
+}
+
+// pngEnd walks the chunks up to the image end chunk.
+func pngEnd(r *bufio.Reader) bool {
+	if _, err := r.Discard(len(pngHeader)); err != nil {
+		return false
+	}
+	var chunk [8]byte
+	for {
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return false
+		}
+		length := int64(binary.BigEndian.Uint32(chunk[:4]))
+		if _, err := io.CopyN(ioutil.Discard, r, length+4); err != nil {
+			return false
+		}
+		if string(chunk[4:]) == "IEND" {
+			return true
+		}
+	}
+}
+
+// gifEnd walks the extensions and the image descriptors up to the trailer.
+func gifEnd(r *bufio.Reader) bool {
+	return walkGIF(r, func() {})
+}
+
+// countGIFFrames counts the image descriptors of the GIF file, the count stops where the structure is not recognized.
+func countGIFFrames(data []byte) int {
+	var frames int
+	walkGIF(bufio.NewReader(bytes.NewReader(data)), func() { frames++ })
+	return frames
+}
+
+// walkGIF calls frame for every image descriptor up to the trailer.
+func walkGIF(r *bufio.Reader, frame func()) bool {
+	var header [13]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return false
+	}
+	if header[10]&0x80 != 0 {
+		if _, err := r.Discard(3 << (uint(header[10]&0x07) + 1)); err != nil {
+			return false
+		}
+	}
+
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return false
+		}
+		switch b {
+		case 0x3B:
+			return true
+		case 0x21:
+			if _, err := r.Discard(1); err != nil {
+				return false
+			}
+		case 0x2C:
+			frame()
+			var descriptor [9]byte
+			if _, err := io.ReadFull(r, descriptor[:]); err != nil {
+				return false
+			}
+			skip := 1
+			if flags := descriptor[8]; flags&0x80 != 0 {
+				skip += 3 << (uint(flags&0x07) + 1)
+			}
+			if _, err := r.Discard(skip); err != nil {
+				return false
+			}
+		default:
+			return false
+		}
+
+		for {
+			size, err := r.ReadByte()
+			if err != nil {
+				return false
+			}
+			if size == 0 {
+				break
+			}
+			if _, err := r.Discard(int(size)); err != nil {
+				return false
+			}
+		}
+	}
+}
+
+// bmpEnd reads the size of the file from the header.
+func bmpEnd(r *bufio.Reader) bool {
+	var header [6]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return false
+	}
+	size := int64(binary.LittleEndian.Uint32(header[2:6]))
+	if size < 14 {
+		return false
+	}
+	_, err := io.CopyN(ioutil.Discard, r, size-6)
+	return err == nil
+}
+
+// riffEnd reads the size of the RIFF container from the header.
+func riffEnd(r *bufio.Reader) bool {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return false
+	}
+	_, err := io.CopyN(ioutil.Discard, r, int64(binary.LittleEndian.Uint32(header[4:8])))
+	return err == nil
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/alisavch/image-service/internal/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+// encodeFixture encodes a small gray image in the format.
+func encodeFixture(t *testing.T, format string) []byte {
+	t.Helper()
+
+	img := image.NewGray(image.Rect(0, 0, 24, 16))
+	var buf bytes.Buffer
+	switch format {
+	case "png":
+		require.NoError(t, png.Encode(&buf, img))
+	case "jpeg":
+		require.NoError(t, jpeg.Encode(&buf, img, nil))
+	case "gif":
+		require.NoError(t, gif.Encode(&buf, img, nil))
+	default:
+		t.Fatalf("unknown fixture format %s", format)
+	}
+	return buf.Bytes()
+}
+
+// withTextChunk inserts a tEXt chunk with the text after the header of the PNG file.
+func withTextChunk(data []byte, text string) []byte {
+	var chunk bytes.Buffer
+	writePNGChunk(&chunk, "tEXt", []byte("Comment\x00"+text))
+
+	headerEnd := len(pngHeader) + 12 + 13
+	return append(append(append([]byte{}, data[:headerEnd]...), chunk.Bytes()...), data[headerEnd:]...)
+}
+
+func TestSniffFormat(t *testing.T) {
+	pngData := encodeFixture(t, "png")
+
+	// The first directory of the TIFF is stored after the head.
+	tiffHead := make([]byte, SniffSize)
+	copy(tiffHead, "II*\x00")
+	binary.LittleEndian.PutUint32(tiffHead[4:], SniffSize+1024)
+
+	tests := []struct {
+		name        string
+		head        []byte
+		contentType string
+		filename    string
+		format      string
+		err         error
+		errMessage  string
+	}{
+		{
+			name:        "PNG declared as PNG",
+			head:        pngData,
+			contentType: "image/png",
+			filename:    "image.png",
+			format:      "png",
+		},
+		{
+			name:        "JPEG with a generic content type and no extension",
+			head:        encodeFixture(t, "jpeg"),
+			contentType: "application/octet-stream",
+			filename:    "image",
+			format:      "jpeg",
+		},
+		{
+			name:        "Content type of another format",
+			head:        pngData,
+			contentType: "image/jpeg",
+			filename:    "image.png",
+			err:         utils.ErrContentMismatch,
+			errMessage:  "content of the file does not match its type: declared image/jpeg, detected png",
+		},
+		{
+			name:        "Extension of another format",
+			head:        encodeFixture(t, "gif"),
+			contentType: "image/gif",
+			filename:    "image.jpg",
+			err:         utils.ErrContentMismatch,
+			errMessage:  "content of the file does not match its type: declared .jpg, detected gif",
+		},
+		{
+			name:        "Unknown signature",
+			head:        []byte("<html><body>not an image</body></html>"),
+			contentType: "image/png",
+			filename:    "image.png",
+			err:         utils.ErrAllowedFormat,
+		},
+		{
+			name:        "Signature without a decodable header",
+			head:        pngData[:20],
+			contentType: "image/png",
+			filename:    "image.png",
+			err:         utils.ErrDecode,
+		},
+		{
+			name:        "Header after the head",
+			head:        tiffHead,
+			contentType: "image/tiff",
+			filename:    "image.tiff",
+			err:         utils.ErrDecode,
+		},
+		{
+			name:        "Script in the header",
+			head:        withTextChunk(pngData, "<SCRIPT>alert(1)</SCRIPT>"),
+			contentType: "image/png",
+			filename:    "image.png",
+			err:         utils.ErrPolyglot,
+			errMessage:  "file contains data of another format: png image with markup \"<script\" in the header",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, cfg, err := SniffFormat(tt.head, tt.contentType, tt.filename)
+			if tt.err != nil {
+				require.True(t, errors.Is(err, tt.err), err)
+				if tt.errMessage != "" {
+					require.EqualError(t, err, tt.errMessage)
+				}
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.format, format.Name)
+			require.Equal(t, 24, cfg.Width)
+			require.Equal(t, 16, cfg.Height)
+		})
+	}
+}
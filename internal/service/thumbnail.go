@@ -0,0 +1,74 @@
+package service
+
+import (
+	"fmt"
+	"image"
+	"sort"
+
+	"github.com/alisavch/image-service/internal/models"
+	"github.com/alisavch/image-service/internal/utils"
+)
+
+// ThumbnailImage resizes the image to every width and writes each size in every format of the thumbnails.
+// The format of the original is kept when no formats are set.
+func (s *ImageService) ThumbnailImage(thumbnails models.Thumbnails, format, resultedName string, img image.Image, opts ...EncodeOption) ([]models.Rendition, error) {
+	formats := thumbnails.Formats
+	if len(formats) == 0 {
+		formats = []string{OutputFormat(format)}
+	}
+
+	var renditions []models.Rendition
+	for _, width := range ThumbnailWidths(img.Bounds().Dx(), thumbnails.Widths) {
+		m, err := ResizeImage(img, models.Resize{Width: width, Mode: models.Fit, Background: DefaultBackground})
+		if err != nil {
+			return nil, &utils.ProcessingError{Op: utils.ErrThumbnail, Err: err}
+		}
+
+		for _, target := range formats {
+			name, err := s.ChangeFormat(fmt.Sprintf("%dw-%s", width, resultedName), target)
+			if err != nil {
+				return nil, &utils.ProcessingError{Op: utils.ErrThumbnail, Err: err}
+			}
+
+			file, err := EncodeResult(m, target, opts...)
+			if err != nil {
+				return nil, &utils.ProcessingError{Op: utils.ErrThumbnail, Err: err}
+			}
+
+			result, err := s.FillInTheResultingImage(name, file)
+			if err != nil {
+				return nil, err
+			}
+
+			renditions = append(renditions, models.Rendition{
+				Width:            m.Bounds().Dx(),
+				Height:           m.Bounds().Dy(),
+				Format:           target,
+				ResultedName:     result.ResultedName,
+				ResultedLocation: result.ResultedLocation,
+			})
+		}
+	}
+
+	return renditions, nil
+}
+
+// ThumbnailWidths sorts the widths and drops the duplicates and the widths larger than the source, the image is never enlarged.
+// The source width is used when all the widths are larger than it.
+func ThumbnailWidths(source int, widths []int) []int {
+	sorted := append([]int{}, widths...)
+	sort.Ints(sorted)
+
+	var result []int
+	for _, width := range sorted {
+		if width > source || (len(result) > 0 && result[len(result)-1] == width) {
+			continue
+		}
+		result = append(result, width)
+	}
+	if len(result) == 0 {
+		result = append(result, source)
+	}
+
+	return result
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/alisavch/image-service/internal/models"
+	"github.com/alisavch/image-service/internal/utils"
+)
+
+// TransformImage flips the image and then rotates it clockwise.
+func TransformImage(imgSrc image.Image, opts models.Transform) (image.Image, error) {
+	m := imgSrc
+	switch opts.Flip {
+	case models.Horizontal:
+		m = FlipHorizontal(m)
+	case models.Vertical:
+		m = FlipVertical(m)
+	case models.Both:
+		m = Rotate180(m)
+	case "":
+	default:
+		return nil, utils.ErrFlip
+	}
+
+	if opts.Angle == 0 {
+		return m, nil
+	}
+
+	background, err := ParseHexColor(opts.Background)
+	if err != nil {
+		return nil, err
+	}
+	return RotateAngle(m, opts.Angle, background)
+}
+
+// RotateAngle rotates the image clockwise by the angle in degrees.
+// Multiples of 90 degrees keep every pixel, other angles enlarge the canvas and fill the corners with the background.
+func RotateAngle(imgSrc image.Image, angle float64, background color.Color) (image.Image, error) {
+	if math.IsNaN(angle) || math.IsInf(angle, 0) {
+		return nil, utils.ErrRotateAngle
+	}
+
+	angle = math.Mod(angle, 360)
+	if angle < 0 {
+		angle += 360
+	}
+
+	switch angle {
+	case 0:
+		return imgSrc, nil
+	case 90:
+		return Rotate90(imgSrc), nil
+	case 180:
+		return Rotate180(imgSrc), nil
+	case 270:
+		return Rotate270(imgSrc), nil
+	}
+
+	if _, ok := imgSrc.(*Animation); ok {
+		return nil, utils.ErrAnimationRotation
+	}
+
+	return rotateFree(imgSrc, angle*math.Pi/180, background), nil
+}
+
+// rotateFree rotates the image by the angle in radians sampling the source bilinearly.
+func rotateFree(imgSrc image.Image, rad float64, background color.Color) *image.RGBA {
+	bounds := imgSrc.Bounds()
+	w, h := float64(bounds.Dx()), float64(bounds.Dy())
+
+	from := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(from, from.Bounds(), imgSrc, bounds.Min, draw.Src)
+
+	sin, cos := math.Sincos(rad)
+	dw := int(math.Ceil(math.Abs(w*cos) + math.Abs(h*sin) - 1e-9))
+	dh := int(math.Ceil(math.Abs(w*sin) + math.Abs(h*cos) - 1e-9))
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	bg := color.RGBAModel.Convert(background).(color.RGBA)
+	cx, cy := w/2, h/2
+	dcx, dcy := float64(dw)/2, float64(dh)/2
+
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			dx, dy := float64(x)+0.5-dcx, float64(y)+0.5-dcy
+			sx := dx*cos + dy*sin + cx - 0.5
+			sy := -dx*sin + dy*cos + cy - 0.5
+			dst.SetRGBA(x, y, bilinear(from, sx, sy, bg))
+		}
+	}
+
+	return dst
+}
+
+// bilinear samples the image between pixels, the pixels outside of it have the background color.
+func bilinear(img *image.RGBA, x, y float64, bg color.RGBA) color.RGBA {
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := x-x0, y-y0
+	ix, iy := int(x0), int(y0)
+
+	at := func(px, py int) color.RGBA {
+		if !image.Pt(px, py).In(img.Rect) {
+			return bg
+		}
+		return img.RGBAAt(px, py)
+	}
+
+	c00, c10 := at(ix, iy), at(ix+1, iy)
+	c01, c11 := at(ix, iy+1), at(ix+1, iy+1)
+
+	mix := func(a, b, c, d uint8) uint8 {
+		top := float64(a)*(1-fx) + float64(b)*fx
+		bottom := float64(c)*(1-fx) + float64(d)*fx
+		return uint8(math.Round(top*(1-fy) + bottom*fy))
+	}
+
+	return color.RGBA{
+		R: mix(c00.R, c10.R, c01.R, c11.R),
+		G: mix(c00.G, c10.G, c01.G, c11.G),
+		B: mix(c00.B, c10.B, c01.B, c11.B),
+		A: mix(c00.A, c10.A, c01.A, c11.A),
+	}
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/alisavch/image-service/internal/utils"
+)
+
+// UploadReader passes an uploaded file on to the storage without holding it in memory.
+//
+// Only the beginning of the file is read ahead to detect the format and check the dimensions. The SHA-256 and the size
+// are computed while the file is read, the read fails as soon as the file exceeds the size limit, and at the end
+// when data follows the image.
+type UploadReader struct {
+	format  Format
+	src     io.Reader
+	hash    hash.Hash
+	size    int64
+	limits  utils.LimitsConfig
+	trailer *trailerScanner
+	err     error
+}
+
+// NewUploadReader reads the beginning of the uploaded file and checks it with SniffFormat and against the limits.
+func NewUploadReader(r io.Reader, contentType, filename string, limits utils.LimitsConfig) (*UploadReader, error) {
+	head := make([]byte, SniffSize)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	head = head[:n]
+
+	if err := CheckFileSize(int64(n), limits); err != nil {
+		return nil, err
+	}
+	format, cfg, err := SniffFormat(head, contentType, filename)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDimensions(cfg, limits); err != nil {
+		return nil, err
+	}
+
+	u := &UploadReader{
+		format: format,
+		src:    io.MultiReader(bytes.NewReader(head), r),
+		hash:   sha256.New(),
+		limits: limits,
+	}
+	if format.End != nil {
+		u.trailer = newTrailerScanner(format.End)
+	}
+	return u, nil
+}
+
+// Read reads the next part of the file.
+func (u *UploadReader) Read(p []byte) (int, error) {
+	if u.err != nil {
+		return 0, u.err
+	}
+
+	n, err := u.src.Read(p)
+	u.hash.Write(p[:n])
+	u.size += int64(n)
+	if u.trailer != nil {
+		_, _ = u.trailer.Write(p[:n])
+	}
+
+	if limitErr := CheckFileSize(u.size, u.limits); limitErr != nil {
+		u.fail(limitErr)
+		return 0, u.err
+	}
+	if err == io.EOF && u.trailer != nil {
+		if trailing := u.trailer.Close(); trailing > 0 {
+			u.fail(&utils.PolyglotError{Format: u.format.Name, Reason: fmt.Sprintf("%d bytes after the end of the image", trailing)})
+			return 0, u.err
+		}
+	}
+	return n, err
+}
+
+// Close releases the reader, it must be called when the file is not read to the end.
+func (u *UploadReader) Close() error {
+	if u.trailer != nil {
+		u.trailer.Abort()
+	}
+	return nil
+}
+
+// Err returns the error the reader failed the file with, the storage may wrap it in its own error.
+func (u *UploadReader) Err() error {
+	return u.err
+}
+
+// Format returns the format detected from the content of the file.
+func (u *UploadReader) Format() Format {
+	return u.format
+}
+
+// Size returns the number of bytes read so far.
+func (u *UploadReader) Size() int64 {
+	return u.size
+}
+
+// SHA256 returns the hex encoded SHA-256 of the bytes read so far.
+func (u *UploadReader) SHA256() string {
+	return hex.EncodeToString(u.hash.Sum(nil))
+}
+
+func (u *UploadReader) fail(err error) {
+	u.err = err
+	if u.trailer != nil {
+		u.trailer.Abort()
+	}
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"testing"
+
+	"github.com/alisavch/image-service/internal/utils"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+func TestUploadReader_Trailer(t *testing.T) {
+	var bmpData bytes.Buffer
+	require.NoError(t, bmp.Encode(&bmpData, image.NewGray(image.Rect(0, 0, 24, 16))))
+
+	fixtures := map[string][]byte{
+		"png":  encodeFixture(t, "png"),
+		"jpeg": encodeFixture(t, "jpeg"),
+		"gif":  encodeFixture(t, "gif"),
+		"bmp":  bmpData.Bytes(),
+	}
+
+	tests := []struct {
+		name       string
+		trailer    []byte
+		errMessage string
+	}{
+		{
+			name: "Image only",
+		},
+		{
+			name:    "Zero padding after the end",
+			trailer: make([]byte, 64),
+		},
+		{
+			name:       "Script after the end",
+			trailer:    []byte("alert(document.cookie)//"),
+			errMessage: "file contains data of another format: %s image with 24 bytes after the end of the image",
+		},
+		{
+			name:       "Archive after the end followed by padding",
+			trailer:    append([]byte("PK\x03\x04"), make([]byte, 16)...),
+			errMessage: "file contains data of another format: %s image with 4 bytes after the end of the image",
+		},
+	}
+
+	for format, fixture := range fixtures {
+		for _, tt := range tests {
+			t.Run(format+"/"+tt.name, func(t *testing.T) {
+				data := append(append([]byte{}, fixture...), tt.trailer...)
+
+				u, err := NewUploadReader(bytes.NewReader(data), "", "", utils.LimitsConfig{})
+				require.NoError(t, err)
+				defer u.Close()
+				require.Equal(t, format, u.Format().Name)
+
+				read, err := ioutil.ReadAll(u)
+				if tt.errMessage != "" {
+					require.True(t, errors.Is(err, utils.ErrPolyglot), err)
+					require.EqualError(t, err, fmt.Sprintf(tt.errMessage, format))
+					require.Equal(t, err, u.Err())
+					return
+				}
+
+				require.NoError(t, err)
+				require.Equal(t, data, read)
+				require.Equal(t, int64(len(data)), u.Size())
+				sum := sha256.Sum256(data)
+				require.Equal(t, hex.EncodeToString(sum[:]), u.SHA256())
+			})
+		}
+	}
+}
+
+func TestUploadReader_TIFFTrailer(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, tiff.Encode(&buf, image.NewGray(image.Rect(0, 0, 24, 16)), nil))
+	data := append(buf.Bytes(), "alert(document.cookie)//"...)
+
+	// TIFF has no end of the image, the data after it is kept.
+	u, err := NewUploadReader(bytes.NewReader(data), "image/tiff", "image.tiff", utils.LimitsConfig{})
+	require.NoError(t, err)
+	defer u.Close()
+
+	read, err := ioutil.ReadAll(u)
+	require.NoError(t, err)
+	require.Equal(t, data, read)
+}
+
+func TestUploadReader_FileSizeLimit(t *testing.T) {
+	data := encodeFixture(t, "png")
+	head := make([]byte, SniffSize)
+	copy(head, data)
+
+	// The head is accepted, the file fails once the rest of it is read.
+	u, err := NewUploadReader(bytes.NewReader(append(head, make([]byte, 4096)...)), "image/png", "image.png", utils.LimitsConfig{MaxFileSize: SniffSize + 1024})
+	require.NoError(t, err)
+	defer u.Close()
+
+	_, err = ioutil.ReadAll(u)
+	require.True(t, errors.Is(err, utils.ErrImageTooLarge), err)
+	require.LessOrEqual(t, u.Size(), int64(SniffSize+1024+32*1024))
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"unicode/utf8"
+
+	"github.com/alisavch/image-service/internal/models"
+	"github.com/alisavch/image-service/internal/utils"
+
+	"github.com/nfnt/resize"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	// MaxWatermarkText is the largest number of characters of the watermark text.
+	MaxWatermarkText = 200
+	// DefaultWatermarkColor is the color of the watermark text.
+	DefaultWatermarkColor = "ffffff"
+	// MaxWatermarkTiles is the largest number of times a tiled watermark is drawn on the image.
+	MaxWatermarkTiles = 10000
+	// watermarkFontSize is the size the text is measured at before it is fitted to the watermark width.
+	watermarkFontSize = 64
+)
+
+// ApplyWatermark overlays the logo or the text on the image, the watermark is as wide as the scaled width of the image.
+func ApplyWatermark(imgSrc image.Image, wm models.Watermark) (image.Image, error) {
+	bounds := imgSrc.Bounds()
+	size := bounds.Size()
+
+	width := int(math.Round(float64(size.X) * wm.Scale))
+	if width < 1 {
+		width = 1
+	}
+	mark, err := watermarkMark(wm, width)
+	if err != nil {
+		return nil, err
+	}
+
+	points, err := watermarkPoints(size, mark.Bounds().Size(), wm)
+	if err != nil {
+		return nil, err
+	}
+
+	layer := image.NewRGBA(image.Rect(0, 0, size.X, size.Y))
+	for _, pt := range points {
+		draw.Draw(layer, mark.Bounds().Sub(mark.Bounds().Min).Add(pt), mark, mark.Bounds().Min, draw.Over)
+	}
+	mask := image.NewUniform(color.Alpha{A: clamp(wm.Opacity * 255)})
+
+	if anim, ok := imgSrc.(*Animation); ok {
+		marked := *anim.GIF
+		marked.Image = make([]*image.Paletted, len(anim.Image))
+		for i, frame := range anim.Image {
+			dst := image.NewPaletted(frame.Bounds(), frame.Palette)
+			copy(dst.Pix, frame.Pix)
+			draw.DrawMask(dst, dst.Bounds(), layer, dst.Bounds().Min, mask, image.Point{}, draw.Over)
+			marked.Image[i] = dst
+		}
+		return &Animation{GIF: &marked}, nil
+	}
+
+	dst := toRGBA(imgSrc)
+	draw.DrawMask(dst, dst.Bounds(), layer, image.Point{}, mask, image.Point{}, draw.Over)
+	return dst, nil
+}
+
+// ValidateWatermark checks the source and the placement of the watermark, the logo is checked against the limits of the images.
+func ValidateWatermark(wm models.Watermark, limits utils.LimitsConfig) error {
+	if len(wm.Logo) > 0 == (wm.Text != "") {
+		return utils.ErrWatermarkSource
+	}
+	if len(wm.Logo) > 0 {
+		cfg, err := png.DecodeConfig(bytes.NewReader(wm.Logo))
+		if err != nil {
+			return utils.ErrWatermarkLogo
+		}
+		if err := checkDimensions(cfg, limits); err != nil {
+			return err
+		}
+	}
+	if utf8.RuneCountInString(wm.Text) > MaxWatermarkText {
+		return utils.ErrWatermarkText
+	}
+	if _, err := ParseHexColor(wm.Color); err != nil {
+		return utils.ErrWatermarkColor
+	}
+
+	switch wm.Position {
+	case models.TopLeft, models.TopRight, models.BottomLeft, models.BottomRight, models.Centered, models.Tiled:
+	default:
+		return utils.ErrWatermarkPosition
+	}
+
+	switch {
+	case math.IsNaN(wm.Opacity) || wm.Opacity <= 0 || wm.Opacity > 1:
+		return utils.ErrWatermarkOpacity
+	case wm.Margin < 0:
+		return utils.ErrWatermarkMargin
+	case math.IsNaN(wm.Scale) || wm.Scale <= 0 || wm.Scale > 1:
+		return utils.ErrWatermarkScale
+	}
+	return nil
+}
+
+// watermarkMark returns the logo scaled to the width or the text rendered to fill it.
+func watermarkMark(wm models.Watermark, width int) (image.Image, error) {
+	if len(wm.Logo) > 0 {
+		logo, err := png.Decode(bytes.NewReader(wm.Logo))
+		if err != nil {
+			return nil, utils.ErrWatermarkLogo
+		}
+		return resize.Resize(uint(width), 0, logo, resize.Lanczos3), nil
+	}
+
+	textColor, err := ParseHexColor(wm.Color)
+	if err != nil {
+		return nil, utils.ErrWatermarkColor
+	}
+	return renderText(wm.Text, textColor, width)
+}
+
+// renderText draws the text with the embedded Go font, the font size is chosen so that the text is as wide as the width.
+func renderText(text string, textColor color.Color, width int) (image.Image, error) {
+	f, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		return nil, err
+	}
+
+	measured, err := textWidth(f, text, watermarkFontSize)
+	if err != nil {
+		return nil, err
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{
+		Size:    watermarkFontSize * float64(width) / math.Max(measured, 1),
+		DPI:     72,
+		Hinting: font.HintingNone,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+
+	metrics := face.Metrics()
+	height := (metrics.Ascent + metrics.Descent).Ceil()
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	drawer := &font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(textColor),
+		Face: face,
+		Dot:  fixed.Point26_6{Y: metrics.Ascent},
+	}
+	drawer.DrawString(text)
+
+	return dst, nil
+}
+
+func textWidth(f *opentype.Font, text string, size float64) (float64, error) {
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingNone})
+	if err != nil {
+		return 0, err
+	}
+	defer face.Close()
+
+	advance := font.MeasureString(face, text)
+	return float64(advance) / 64, nil
+}
+
+// watermarkPoints returns the top left corners the watermark is drawn at.
+// A tiled watermark that is too small for the image is rejected before the corners are listed.
+func watermarkPoints(size, mark image.Point, wm models.Watermark) ([]image.Point, error) {
+	margin := wm.Margin
+	right, bottom := size.X-mark.X-margin, size.Y-mark.Y-margin
+
+	switch wm.Position {
+	case models.TopLeft:
+		return []image.Point{{X: margin, Y: margin}}, nil
+	case models.TopRight:
+		return []image.Point{{X: right, Y: margin}}, nil
+	case models.BottomLeft:
+		return []image.Point{{X: margin, Y: bottom}}, nil
+	case models.Centered:
+		return []image.Point{{X: (size.X - mark.X) / 2, Y: (size.Y - mark.Y) / 2}}, nil
+	case models.Tiled:
+		step := image.Pt(maxInt(mark.X+margin, 1), maxInt(mark.Y+margin, 1))
+		columns, rows := tileCount(size.X-margin, step.X), tileCount(size.Y-margin, step.Y)
+		if int64(columns)*int64(rows) > MaxWatermarkTiles {
+			return nil, utils.ErrWatermarkTiles
+		}
+
+		points := make([]image.Point, 0, columns*rows)
+		for y := margin; y < size.Y; y += step.Y {
+			for x := margin; x < size.X; x += step.X {
+				points = append(points, image.Pt(x, y))
+			}
+		}
+		return points, nil
+	}
+
+	return []image.Point{{X: right, Y: bottom}}, nil
+}
+
+// tileCount is the number of tiles that start within the length.
+func tileCount(length, step int) int {
+	if length <= 0 {
+		return 0
+	}
+	return (length + step - 1) / step
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/alisavch/image-service/internal/models"
+	"github.com/alisavch/image-service/internal/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatermarkPoints(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   image.Point
+		mark   image.Point
+		wm     models.Watermark
+		points int
+		err    error
+	}{
+		{
+			name:   "Bottom right corner",
+			size:   image.Pt(100, 50),
+			mark:   image.Pt(20, 10),
+			wm:     models.Watermark{Position: models.BottomRight, Margin: 5},
+			points: 1,
+		},
+		{
+			name:   "Tiles cover the image",
+			size:   image.Pt(100, 50),
+			mark:   image.Pt(20, 10),
+			wm:     models.Watermark{Position: models.Tiled, Margin: 5},
+			points: 4 * 3,
+		},
+		{
+			name: "Tiny tiles on a large image",
+			size: image.Pt(16384, 16384),
+			mark: image.Pt(1, 1),
+			wm:   models.Watermark{Position: models.Tiled},
+			err:  utils.ErrWatermarkTiles,
+		},
+		{
+			name:   "Margin larger than the image",
+			size:   image.Pt(100, 50),
+			mark:   image.Pt(1, 1),
+			wm:     models.Watermark{Position: models.Tiled, Margin: 200},
+			points: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			points, err := watermarkPoints(tt.size, tt.mark, tt.wm)
+			if tt.err != nil {
+				require.Equal(t, tt.err, err)
+				require.Nil(t, points)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, points, tt.points)
+		})
+	}
+}
+
+func TestApplyWatermark_TinyTiles(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4096, 4096))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	// The mark is a single pixel wide and the tiles have no margin.
+	_, err := ApplyWatermark(img, models.Watermark{Text: "x", Color: "ffffff", Position: models.Tiled, Opacity: 0.5, Scale: 1e-9})
+	require.Equal(t, utils.ErrWatermarkTiles, err)
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/alisavch/image-service/internal/models"
+	"github.com/alisavch/image-service/internal/utils"
+)
+
+// FileSystem keeps the files in a directory of the local file system, the keys are paths relative to it.
+type FileSystem struct {
+	root string
+}
+
+// NewFileSystem configures FileSystem.
+func NewFileSystem(root string) *FileSystem {
+	return &FileSystem{root: root}
+}
+
+// Put writes the file, a partially written file is removed. The directory of the file is returned as its location.
+func (fs *FileSystem) Put(key string, r io.Reader) (string, error) {
+	name := fs.path(key)
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return "", utils.ErrEnsureDir
+	}
+
+	out, err := os.Create(name)
+	if err != nil {
+		return "", utils.ErrCreateFile
+	}
+
+	_, err = io.Copy(out, r)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(name)
+		return "", fmt.Errorf("%s:%s", utils.ErrCopyFile, err)
+	}
+
+	return filepath.Dir(name) + "/", nil
+}
+
+// Get opens the file.
+func (fs *FileSystem) Get(key string) (io.ReadCloser, error) {
+	f, err := os.Open(fs.path(key))
+	if os.IsNotExist(err) {
+		return nil, utils.ErrObjectNotFound
+	}
+	if err != nil {
+		return nil, utils.ErrOpenFile
+	}
+	return f, nil
+}
+
+// Stat returns the size and the modification time of the file.
+func (fs *FileSystem) Stat(key string) (models.Object, error) {
+	info, err := os.Stat(fs.path(key))
+	if os.IsNotExist(err) {
+		return models.Object{}, utils.ErrObjectNotFound
+	}
+	if err != nil {
+		return models.Object{}, utils.ErrFileStat
+	}
+	return models.Object{Key: key, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// Delete removes the file, a missing file is not an error.
+func (fs *FileSystem) Delete(key string) error {
+	if err := os.Remove(fs.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// List walks the directory of the prefix.
+func (fs *FileSystem) List(prefix string) ([]models.Object, error) {
+	dir := fs.path(prefix)
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		dir = filepath.Dir(dir)
+	}
+
+	var objects []models.Object
+	err := filepath.Walk(dir, func(name string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(fs.root, name)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			objects = append(objects, models.Object{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+// path maps the key into the root, the keys cannot point outside of it.
+func (fs *FileSystem) path(key string) string {
+	return filepath.Join(fs.root, filepath.FromSlash(path.Clean("/"+key)))
+}
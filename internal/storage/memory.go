@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"bytes"
+	"container/list"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alisavch/image-service/internal/models"
+	"github.com/alisavch/image-service/internal/utils"
+)
+
+// Memory keeps the files in memory, it is safe for concurrent use. The files are lost when the process exits,
+// so it only suits the tests and the deployments that run the API and the consumer in one process.
+//
+// The least recently read files are evicted when the total size would exceed maxSize, the files larger than
+// maxObjectSize are rejected. Zero turns a limit off.
+type Memory struct {
+	mu            sync.Mutex
+	maxObjectSize int64
+	maxSize       int64
+	size          int64
+	lru           *list.List
+	objects       map[string]*list.Element
+}
+
+type memoryObject struct {
+	models.Object
+	data []byte
+}
+
+// NewMemory configures Memory.
+func NewMemory(maxObjectSize, maxSize int64) *Memory {
+	return &Memory{
+		maxObjectSize: maxObjectSize,
+		maxSize:       maxSize,
+		lru:           list.New(),
+		objects:       make(map[string]*list.Element),
+	}
+}
+
+// Put reads the file into memory and evicts the least recently read files to make room for it.
+func (m *Memory) Put(key string, r io.Reader) (string, error) {
+	limit := m.maxObjectSize
+	if m.maxSize > 0 && (limit == 0 || m.maxSize < limit) {
+		limit = m.maxSize
+	}
+
+	var buf bytes.Buffer
+	src := r
+	if limit > 0 {
+		src = io.LimitReader(r, limit+1)
+	}
+	if _, err := buf.ReadFrom(src); err != nil {
+		return "", err
+	}
+	if limit > 0 && int64(buf.Len()) > limit {
+		return "", utils.ErrObjectTooLarge
+	}
+	data := buf.Bytes()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.remove(key)
+	for m.maxSize > 0 && m.size+int64(len(data)) > m.maxSize {
+		m.remove(m.lru.Back().Value.(*memoryObject).Key)
+	}
+
+	m.objects[key] = m.lru.PushFront(&memoryObject{
+		Object: models.Object{Key: key, Size: int64(len(data)), ModTime: time.Now()},
+		data:   data,
+	})
+	m.size += int64(len(data))
+
+	return "memory://" + path.Dir(key) + "/", nil
+}
+
+// Get returns a reader of the file and marks it as recently read.
+func (m *Memory) Get(key string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.objects[key]
+	if !ok {
+		return nil, utils.ErrObjectNotFound
+	}
+	m.lru.MoveToFront(el)
+
+	// The data of a file is never modified, a new Put replaces the slice.
+	return ioutil.NopCloser(bytes.NewReader(el.Value.(*memoryObject).data)), nil
+}
+
+// Stat returns the size and the time the file was put.
+func (m *Memory) Stat(key string) (models.Object, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.objects[key]
+	if !ok {
+		return models.Object{}, utils.ErrObjectNotFound
+	}
+	return el.Value.(*memoryObject).Object, nil
+}
+
+// Delete removes the file, a missing file is not an error.
+func (m *Memory) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.remove(key)
+	return nil
+}
+
+// List returns the files whose keys start with the prefix.
+func (m *Memory) List(prefix string) ([]models.Object, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var objects []models.Object
+	for key, el := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, el.Value.(*memoryObject).Object)
+		}
+	}
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+// Size returns the total size of the files kept in memory.
+func (m *Memory) Size() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.size
+}
+
+// remove drops the file, the lock must be held.
+func (m *Memory) remove(key string) {
+	el, ok := m.objects[key]
+	if !ok {
+		return
+	}
+	m.lru.Remove(el)
+	delete(m.objects, key)
+	m.size -= el.Value.(*memoryObject).Size
+}
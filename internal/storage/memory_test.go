@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"testing"
+
+	"github.com/alisavch/image-service/internal/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func keys(t *testing.T, m *Memory, prefix string) []string {
+	objects, err := m.List(prefix)
+	require.NoError(t, err)
+
+	var result []string
+	for _, object := range objects {
+		result = append(result, object.Key)
+	}
+	return result
+}
+
+func TestMemory_PutGet(t *testing.T) {
+	m := NewMemory(0, 0)
+
+	location, err := m.Put("uploads/filename.jpeg", bytes.NewReader([]byte("image")))
+	require.NoError(t, err)
+	require.Equal(t, "memory://uploads/", location)
+
+	object, err := m.Stat("uploads/filename.jpeg")
+	require.NoError(t, err)
+	require.Equal(t, int64(5), object.Size)
+
+	file, err := m.Get("uploads/filename.jpeg")
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(file)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+	require.Equal(t, []byte("image"), data)
+
+	_, err = m.Put("uploads/filename.jpeg", bytes.NewReader([]byte("new")))
+	require.NoError(t, err)
+	require.Equal(t, int64(3), m.Size())
+
+	require.NoError(t, m.Delete("uploads/filename.jpeg"))
+	require.NoError(t, m.Delete("uploads/filename.jpeg"))
+	_, err = m.Get("uploads/filename.jpeg")
+	require.Equal(t, utils.ErrObjectNotFound, err)
+	_, err = m.Stat("uploads/filename.jpeg")
+	require.Equal(t, utils.ErrObjectNotFound, err)
+	require.Equal(t, int64(0), m.Size())
+}
+
+func TestMemory_Limits(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxObjectSize int64
+		maxSize       int64
+		puts          []string
+		get           string
+		size          int
+		wantErr       error
+		wantKeys      []string
+	}{
+		{
+			name:     "Files within the limits",
+			maxSize:  30,
+			puts:     []string{"results/a", "results/b", "results/c"},
+			size:     10,
+			wantKeys: []string{"results/a", "results/b", "results/c"},
+		},
+		{
+			name:     "Least recently put file is evicted",
+			maxSize:  30,
+			puts:     []string{"results/a", "results/b", "results/c", "results/d"},
+			size:     10,
+			wantKeys: []string{"results/b", "results/c", "results/d"},
+		},
+		{
+			name:     "Read file is kept",
+			maxSize:  30,
+			puts:     []string{"results/a", "results/b", "results/c", "results/d"},
+			get:      "results/a",
+			size:     10,
+			wantKeys: []string{"results/a", "results/c", "results/d"},
+		},
+		{
+			name:          "File over the object limit",
+			maxObjectSize: 9,
+			puts:          []string{"results/a"},
+			size:          10,
+			wantErr:       utils.ErrObjectTooLarge,
+		},
+		{
+			name:    "File over the total limit",
+			maxSize: 9,
+			puts:    []string{"results/a"},
+			size:    10,
+			wantErr: utils.ErrObjectTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMemory(tt.maxObjectSize, tt.maxSize)
+
+			var err error
+			for i, key := range tt.puts {
+				_, err = m.Put(key, bytes.NewReader(make([]byte, tt.size)))
+				if tt.get != "" && i == len(tt.puts)-2 {
+					_, getErr := m.Get(tt.get)
+					require.NoError(t, getErr)
+				}
+			}
+
+			require.Equal(t, tt.wantErr, err)
+			require.Equal(t, tt.wantKeys, keys(t, m, "results/"))
+			require.Equal(t, int64(len(tt.wantKeys)*tt.size), m.Size())
+		})
+	}
+}
+
+func TestMemory_Concurrent(t *testing.T) {
+	m := NewMemory(0, 100)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				key := fmt.Sprintf("results/%d-%d", i, j%10)
+				_, err := m.Put(key, bytes.NewReader(make([]byte, 10)))
+				assert.NoError(t, err)
+				if file, err := m.Get(key); err == nil {
+					assert.NoError(t, file.Close())
+				}
+				_, err = m.List("results/")
+				assert.NoError(t, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	require.LessOrEqual(t, m.Size(), int64(100))
+	require.Len(t, keys(t, m, ""), int(m.Size()/10))
+}
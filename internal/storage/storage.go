@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/alisavch/image-service/internal/bucket"
+	"github.com/alisavch/image-service/internal/models"
+	"github.com/alisavch/image-service/internal/utils"
+)
+
+const (
+	// AWS, Local and InMemory are the values of REMOTE_STORAGE.
+	AWS      = "AWS"
+	Local    = "local"
+	InMemory = "memory"
+
+	uploadsPrefix = "uploads/"
+	resultsPrefix = "results/"
+)
+
+// Storage keeps the files of the service under keys, the uploads and the results are kept under their own prefixes.
+type Storage interface {
+	// Put writes the file under the key and returns its location reported to the clients.
+	Put(key string, r io.Reader) (string, error)
+	// Get opens the file kept under the key, the caller closes it.
+	Get(key string) (io.ReadCloser, error)
+	Stat(key string) (models.Object, error)
+	Delete(key string) error
+	// List returns the files whose keys start with the prefix, sorted by key.
+	List(prefix string) ([]models.Object, error)
+}
+
+// New creates the storage chosen in the configuration, it is done once at startup.
+func New(conf *utils.Config) (Storage, error) {
+	switch conf.Storage {
+	case AWS:
+		return bucket.NewS3Session(conf.Bucket)
+
+	case Local:
+		currentDir, err := os.Getwd()
+		if err != nil {
+			return nil, utils.ErrGetDir
+		}
+		return NewFileSystem(currentDir), nil
+
+	case InMemory:
+		return NewMemory(conf.Memory.MaxObjectSize, conf.Memory.MaxSize), nil
+	}
+
+	return nil, fmt.Errorf("%s:%s", utils.ErrUnknownStorage, conf.Storage)
+}
+
+// UploadKey returns the key the uploaded image is kept under.
+func UploadKey(name string) string {
+	return uploadsPrefix + name
+}
+
+// ResultKey returns the key the resulting image is kept under.
+func ResultKey(name string) string {
+	return resultsPrefix + name
+}
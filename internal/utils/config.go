@@ -1,6 +1,9 @@
 package utils
 
-import "os"
+import (
+	"os"
+	"strconv"
+)
 
 // DBConfig includes database variables.
 type DBConfig struct {
@@ -18,11 +21,17 @@ type RabbitmqConfig struct {
 }
 
 // BucketConfig includes bucket variables.
+//
+// Endpoint points the service to an S3-compatible storage such as MinIO or LocalStack, which usually needs
+// ForcePathStyle. The default AWS credential chain is used when the static keys are not set.
 type BucketConfig struct {
 	AWSRegion          string
 	AWSAccessKeyID     string
 	AWSSecretAccessKey string
 	BucketName         string
+	Endpoint           string
+	ForcePathStyle     bool
+	InsecureSkipVerify bool
 }
 
 // Authentication includes variables for generating token.
@@ -31,12 +40,28 @@ type Authentication struct {
 	SigningKey string
 }
 
+// LimitsConfig includes the largest images the service accepts, zero turns the limit off.
+type LimitsConfig struct {
+	MaxWidth      int
+	MaxHeight     int
+	MaxMegapixels float64
+	MaxFileSize   int64
+}
+
+// MemoryConfig includes the limits of the in-memory storage in bytes, zero turns the limit off.
+type MemoryConfig struct {
+	MaxObjectSize int64
+	MaxSize       int64
+}
+
 // Config includes config variables.
 type Config struct {
 	DBConfig DBConfig
 	Auth     Authentication
 	Rabbitmq RabbitmqConfig
 	Bucket   BucketConfig
+	Limits   LimitsConfig
+	Memory   MemoryConfig
 	Storage  string
 }
 
@@ -58,6 +83,19 @@ func NewConfig() *Config {
 			AWSAccessKeyID:     getEnv("AWS_ACCESS_KEY_ID", ""),
 			AWSSecretAccessKey: getEnv("AWS_SECRET_ACCESS_KEY", ""),
 			BucketName:         getEnv("BUCKET_NAME", ""),
+			Endpoint:           getEnv("S3_ENDPOINT", ""),
+			ForcePathStyle:     getEnvAsBool("S3_FORCE_PATH_STYLE", false),
+			InsecureSkipVerify: getEnvAsBool("S3_INSECURE_SKIP_VERIFY", false),
+		},
+		Limits: LimitsConfig{
+			MaxWidth:      getEnvAsInt("MAX_IMAGE_WIDTH", 16384),
+			MaxHeight:     getEnvAsInt("MAX_IMAGE_HEIGHT", 16384),
+			MaxMegapixels: getEnvAsFloat("MAX_IMAGE_MEGAPIXELS", 100),
+			MaxFileSize:   int64(getEnvAsInt("MAX_FILE_SIZE", 32<<20)),
+		},
+		Memory: MemoryConfig{
+			MaxObjectSize: int64(getEnvAsInt("MEMORY_STORAGE_MAX_OBJECT_SIZE", 0)),
+			MaxSize:       int64(getEnvAsInt("MEMORY_STORAGE_MAX_SIZE", 256<<20)),
 		},
 		Storage: getEnv("REMOTE_STORAGE", "local"),
 	}
@@ -71,3 +109,33 @@ func getEnv(key, defaultVal string) string {
 
 	return defaultVal
 }
+
+// getEnvAsInt reads an integer environment variable, the default value is used when it is missing or malformed.
+func getEnvAsInt(key string, defaultVal int) int {
+	value, err := strconv.Atoi(getEnv(key, ""))
+	if err != nil {
+		return defaultVal
+	}
+
+	return value
+}
+
+// getEnvAsFloat reads a float environment variable, the default value is used when it is missing or malformed.
+func getEnvAsFloat(key string, defaultVal float64) float64 {
+	value, err := strconv.ParseFloat(getEnv(key, ""), 64)
+	if err != nil {
+		return defaultVal
+	}
+
+	return value
+}
+
+// getEnvAsBool reads a boolean environment variable, the default value is used when it is missing or malformed.
+func getEnvAsBool(key string, defaultVal bool) bool {
+	value, err := strconv.ParseBool(getEnv(key, ""))
+	if err != nil {
+		return defaultVal
+	}
+
+	return value
+}
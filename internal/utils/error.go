@@ -1,6 +1,10 @@
 package utils
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
 
 var (
 	// ErrGetUserID checks the correctness of the conversion.
@@ -20,13 +24,17 @@ var (
 	// ErrCopyFile checks copy file.
 	ErrCopyFile = errors.New("cannot copy file")
 	// ErrAllowedFormat checks allowed format of the file.
-	ErrAllowedFormat = errors.New("file format is not allowed. Please upload a JPEG or PNG")
+	ErrAllowedFormat = errors.New("file format is not allowed. Please upload a JPEG, PNG, GIF, BMP, TIFF or WebP")
 	// ErrSigningMethod checks signing method.
 	ErrSigningMethod = errors.New("invalid signing method")
 	// ErrInvalidToken checks token.
 	ErrInvalidToken = errors.New("token claims is invalid")
 	// ErrUnsupportedFormat checks supported formats.
 	ErrUnsupportedFormat = errors.New("unsupported file format")
+	// ErrUnsupportedTargetFormat checks the target format of the conversion.
+	ErrUnsupportedTargetFormat = errors.New("unsupported target format")
+	// ErrMissingFormat checks that the target format is set.
+	ErrMissingFormat = errors.New("target format must not be empty")
 	// ErrFindImage the correctness of finding the image is checked.
 	ErrFindImage = errors.New("cannot find image")
 	// ErrSaveImage the correctness of saving the image is checked.
@@ -39,10 +47,82 @@ var (
 	ErrEmptyPassword = errors.New("password must not be empty")
 	// ErrIncorrectRatio checks input ratio.
 	ErrIncorrectRatio = errors.New("input ratio is incorrect. it should not exceed the image size")
+	// ErrResizeMode checks the resize mode.
+	ErrResizeMode = errors.New("resize mode is not supported. Please use fit, fill, pad or exact")
+	// ErrMissingSize checks that both sides of the box are set.
+	ErrMissingSize = errors.New("fill, pad and exact modes require both width and height")
+	// ErrNegativeSize checks input width and height.
+	ErrNegativeSize = errors.New("width and height must not be negative")
+	// ErrBackgroundColor checks the background color.
+	ErrBackgroundColor = errors.New("background color is incorrect. Please use RGB, RRGGBB or RRGGBBAA hex notation")
+	// ErrQuality checks the JPEG quality.
+	ErrQuality = errors.New("quality must be between 1 and 100")
+	// ErrPNGLevel checks the PNG compression level.
+	ErrPNGLevel = errors.New("png_level must be between 0 and 9")
+	// ErrPNGColors checks the number of colors of the quantized PNG.
+	ErrPNGColors = errors.New("colors must be between 2 and 256")
+	// ErrCropParams checks that either a rectangle or an aspect ratio is set.
+	ErrCropParams = errors.New("crop requires either width and height or aspect ratio")
+	// ErrCropBounds checks that the crop rectangle lies within the image.
+	ErrCropBounds = errors.New("crop rectangle is out of the image bounds")
+	// ErrAspectRatio checks the aspect ratio.
+	ErrAspectRatio = errors.New("aspect ratio is incorrect. Please use W:H notation, e.g. 16:9")
+	// ErrGravity checks the gravity.
+	ErrGravity = errors.New("gravity is not supported. Please use center, north, south, east, west, north-east, north-west, south-east, south-west or smart")
+	// ErrFlip checks the flip direction.
+	ErrFlip = errors.New("flip is not supported. Please use horizontal, vertical or both")
+	// ErrRotateAngle checks the rotation angle.
+	ErrRotateAngle = errors.New("rotation angle must be a finite number of degrees")
+	// ErrAnimationRotation checks the rotation of animations.
+	ErrAnimationRotation = errors.New("animations can only be rotated by multiples of 90 degrees")
+	// ErrTransformParams checks that the transformation changes the image.
+	ErrTransformParams = errors.New("transform requires rotate or flip")
+	// ErrPipelineSteps checks the steps of the pipeline.
+	ErrPipelineSteps = errors.New("steps must be a JSON array of 1 to 20 operations")
+	// ErrPipelineStep checks the operation of the pipeline step.
+	ErrPipelineStep = errors.New("operation is not supported. Please use resize, crop, transform, grayscale, filter, watermark or convert")
+	// ErrPipelineConvert checks the position of the convert step.
+	ErrPipelineConvert = errors.New("convert can only be the last step")
+	// ErrResizeSize checks that the resize step has a size.
+	ErrResizeSize = errors.New("resize requires width or height")
+	// ErrFilters checks the list of adjustments.
+	ErrFilters = errors.New("filters must be a JSON array of 1 to 20 adjustments")
+	// ErrFilter checks the name of the adjustment.
+	ErrFilter = errors.New("filter is not supported. Please use grayscale, sepia, blur, sharpen, brightness, contrast, gamma or saturation")
+	// ErrFilterValue checks the value of the adjustment.
+	ErrFilterValue = errors.New("filter value is out of range")
+	// ErrThumbnailWidths checks the widths of the renditions.
+	ErrThumbnailWidths = errors.New("widths must be a comma separated list of 1 to 10 widths between 1 and 10000")
+	// ErrThumbnailFormats checks the formats of the renditions.
+	ErrThumbnailFormats = errors.New("formats must be a comma separated list of up to 5 formats")
+	// ErrWatermarkSource checks that the watermark has either a logo or a text.
+	ErrWatermarkSource = errors.New("watermark requires either a logo or a text, or a saved default watermark")
+	// ErrWatermarkLogo checks the watermark logo.
+	ErrWatermarkLogo = errors.New("logo must be a PNG image of up to 1 MB")
+	// ErrWatermarkText checks the length of the watermark text.
+	ErrWatermarkText = errors.New("text must be up to 200 characters")
+	// ErrWatermarkColor checks the color of the watermark text.
+	ErrWatermarkColor = errors.New("text color is incorrect. Please use RGB, RRGGBB or RRGGBBAA hex notation")
+	// ErrWatermarkPosition checks the position of the watermark.
+	ErrWatermarkPosition = errors.New("position is not supported. Please use top-left, top-right, bottom-left, bottom-right, center or tiled")
+	// ErrWatermarkOpacity checks the opacity of the watermark.
+	ErrWatermarkOpacity = errors.New("opacity must be greater than 0 and at most 1")
+	// ErrWatermarkMargin checks the margin of the watermark.
+	ErrWatermarkMargin = errors.New("margin must not be negative")
+	// ErrWatermarkScale checks the width of the watermark relative to the image.
+	ErrWatermarkScale = errors.New("scale must be greater than 0 and at most 1")
+	// ErrWatermarkTiles checks the number of tiles of the watermark.
+	ErrWatermarkTiles = errors.New("tiled watermark is too small for the image. Please increase the scale or the margin")
+	// ErrMetadataPolicy checks the metadata policy.
+	ErrMetadataPolicy = errors.New("metadata policy is not supported. Please use strip, keep or keep-safe")
 	// ErrMissingParams checks id in params.
 	ErrMissingParams = errors.New("id is missing in parameters")
 	// ErrAtoi checks to convert to type int.
 	ErrAtoi = errors.New("cannot convert string to int")
+	// ErrParseBool checks to convert to type bool.
+	ErrParseBool = errors.New("cannot convert string to bool")
+	// ErrParseFloat checks to convert to type float.
+	ErrParseFloat = errors.New("cannot convert string to float")
 	// ErrGetDir finds current location.
 	ErrGetDir = errors.New("cannot get current directory")
 	// ErrOpen opens the image.
@@ -53,12 +133,34 @@ var (
 	ErrEnsureDir = errors.New("cannot ensure base directory")
 	// ErrCompress checks to compress the image.
 	ErrCompress = errors.New("cannot compress")
+	// ErrPipeline checks to run the pipeline.
+	ErrPipeline = errors.New("cannot run pipeline")
+	// ErrTransform checks to transform the image.
+	ErrTransform = errors.New("cannot transform")
+	// ErrCrop checks to crop the image.
+	ErrCrop = errors.New("cannot crop")
+	// ErrThumbnail checks to produce the renditions of the image.
+	ErrThumbnail = errors.New("cannot create thumbnails")
+	// ErrWatermark checks to watermark the image.
+	ErrWatermark = errors.New("cannot watermark")
 	// ErrFileStat checks to get information about the file.
 	ErrFileStat = errors.New("cannot get file info")
 	// ErrCreateRequest verifies the execution of the request.
 	ErrCreateRequest = errors.New("cannot create request")
 	// ErrS3Uploading checks if the file can be uploaded to aws s3 bucket.
 	ErrS3Uploading = errors.New("failed to upload file to S3 bucket")
+	// ErrS3Deleting checks if the file can be deleted from aws s3 bucket.
+	ErrS3Deleting = errors.New("failed to delete file from S3 bucket")
+	// ErrS3Stat checks to get information about the file in aws s3 bucket.
+	ErrS3Stat = errors.New("failed to get file info from S3 bucket")
+	// ErrS3Listing checks to list the files in aws s3 bucket.
+	ErrS3Listing = errors.New("failed to list files in S3 bucket")
+	// ErrObjectNotFound checks if the file is kept in the storage.
+	ErrObjectNotFound = errors.New("file not found in the storage")
+	// ErrObjectTooLarge checks the size of the file put into the storage.
+	ErrObjectTooLarge = errors.New("file is larger than the storage accepts")
+	// ErrUnknownStorage checks the storage chosen in the configuration.
+	ErrUnknownStorage = errors.New("unknown storage")
 	// ErrUserAlreadyExists checks the ability to create a user.
 	ErrUserAlreadyExists = errors.New("user already exists")
 	// ErrFindUser checks the ability to find the user.
@@ -99,4 +201,116 @@ var (
 	ErrReceivedEmpty = errors.New("received an empty string")
 	// ErrUserAuthentication checks if there are such identifiers in the database.
 	ErrUserAuthentication = errors.New("access denied")
+	// ErrCreateRendition checks the ability to save the rendition.
+	ErrCreateRendition = errors.New("cannot save rendition")
+	// ErrFindRendition checks if the rendition can be found.
+	ErrFindRendition = errors.New("no such rendition")
+	// ErrSaveWatermark checks the ability to save the default watermark.
+	ErrSaveWatermark = errors.New("cannot save watermark")
+	// ErrFindWatermark checks the ability to find the default watermark.
+	ErrFindWatermark = errors.New("cannot find watermark")
+	// ErrWatermarkNotFound checks that the user has saved a default watermark.
+	ErrWatermarkNotFound = errors.New("no default watermark is saved")
+	// ErrDeleteWatermark checks the ability to delete the default watermark.
+	ErrDeleteWatermark = errors.New("cannot delete watermark")
+	// ErrSaveImageHash checks the ability to save the perceptual hash of the image.
+	ErrSaveImageHash = errors.New("cannot save image hash")
+	// ErrImageNotFound checks that the image belongs to the user.
+	ErrImageNotFound = errors.New("no such image")
+	// ErrImageHashPending checks that the perceptual hash of the image has been computed.
+	ErrImageHashPending = errors.New("the image has not been analysed yet")
+	// ErrInspectImage checks the image can be inspected.
+	ErrInspectImage = errors.New("cannot inspect image")
+	// ErrFindSimilarImages checks the ability to find similar images.
+	ErrFindSimilarImages = errors.New("cannot find similar images")
+	// ErrHashDistance checks the Hamming distance of similar images.
+	ErrHashDistance = errors.New("distance must be between 0 and 64")
+	// ErrPlaceholder checks the ability to create the placeholders of the image.
+	ErrPlaceholder = errors.New("cannot create placeholder")
+	// ErrPaletteColors checks the number of palette colors.
+	ErrPaletteColors = errors.New("colors must be between 1 and 16")
+	// ErrPalette checks the ability to extract the palette.
+	ErrPalette = errors.New("cannot extract palette")
+	// ErrCreatePalette checks the ability to save the palette.
+	ErrCreatePalette = errors.New("cannot save palette")
+	// ErrFindPalette checks if the palette can be found.
+	ErrFindPalette = errors.New("cannot find palette")
+	// ErrImageTooLarge checks the image against the configured limits.
+	ErrImageTooLarge = errors.New("image is too large")
+	// ErrContentMismatch checks that the content of the file is what the client declared.
+	ErrContentMismatch = errors.New("content of the file does not match its type")
+	// ErrPolyglot checks that the file cannot be read as another format.
+	ErrPolyglot = errors.New("file contains data of another format")
+	// ErrUnprocessable checks that the image can be processed with the parameters of the request.
+	ErrUnprocessable = errors.New("image cannot be processed with the requested parameters")
 )
+
+// LimitError reports the limit the image exceeds, it matches ErrImageTooLarge with errors.Is.
+type LimitError struct {
+	// Limit is the name of the exceeded limit: width, height, megapixels, megapixels of all frames or file size.
+	Limit string
+	Value float64
+	Max   float64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %s %s exceeds the limit of %s", ErrImageTooLarge, e.Limit,
+		strconv.FormatFloat(e.Value, 'f', -1, 64), strconv.FormatFloat(e.Max, 'f', -1, 64))
+}
+
+// Unwrap returns ErrImageTooLarge.
+func (e *LimitError) Unwrap() error {
+	return ErrImageTooLarge
+}
+
+// ContentMismatchError reports the content type or the extension that does not match the content of the file.
+// It matches ErrContentMismatch with errors.Is.
+type ContentMismatchError struct {
+	Declared string
+	Detected string
+}
+
+func (e *ContentMismatchError) Error() string {
+	return fmt.Sprintf("%s: declared %s, detected %s", ErrContentMismatch, e.Declared, e.Detected)
+}
+
+// Unwrap returns ErrContentMismatch.
+func (e *ContentMismatchError) Unwrap() error {
+	return ErrContentMismatch
+}
+
+// PolyglotError reports what makes the image readable as another format, it matches ErrPolyglot with errors.Is.
+type PolyglotError struct {
+	Format string
+	Reason string
+}
+
+func (e *PolyglotError) Error() string {
+	return fmt.Sprintf("%s: %s image with %s", ErrPolyglot, e.Format, e.Reason)
+}
+
+// Unwrap returns ErrPolyglot.
+func (e *PolyglotError) Unwrap() error {
+	return ErrPolyglot
+}
+
+// ProcessingError reports the operation that failed on the image. The same image and parameters fail the same way
+// every time, so it matches ErrUnprocessable with errors.Is, as well as the operation and the error of the operation.
+type ProcessingError struct {
+	Op  error
+	Err error
+}
+
+func (e *ProcessingError) Error() string {
+	return fmt.Sprintf("%s:%s", e.Op, e.Err)
+}
+
+// Is reports whether the target is ErrUnprocessable or the operation.
+func (e *ProcessingError) Is(target error) bool {
+	return target == ErrUnprocessable || target == e.Op
+}
+
+// Unwrap returns the error of the operation.
+func (e *ProcessingError) Unwrap() error {
+	return e.Err
+}
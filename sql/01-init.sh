@@ -3,7 +3,7 @@ set -e
 export PGPASSWORD=$POSTGRES_PASSWORD;
 psql -v ON_ERROR_STOP=1 --username "$POSTGRES_USER" --dbname "$DB_NAME" <<-EOSQL
   CREATE SCHEMA IF NOT EXISTS image_service;
-  CREATE TYPE enum_service AS ENUM('conversion', 'compression');
+  CREATE TYPE enum_service AS ENUM('conversion', 'compression', 'cropping', 'transformation', 'pipeline', 'filtering', 'watermarking', 'thumbnailing', 'palette');
   ALTER TYPE enum_service SET SCHEMA image_service;
   CREATE TYPE enum_status AS ENUM ('queued', 'processing', 'done', 'processing failed');
   ALTER TYPE enum_status SET SCHEMA image_service;
@@ -20,6 +20,13 @@ psql -v ON_ERROR_STOP=1 --username "$POSTGRES_USER" --dbname "$DB_NAME" <<-EOSQL
       uploaded_location character varying(150) NOT NULL,
       resulted_name character varying(150),
       resulted_location character varying(150),
+      uploaded_size bigint,
+      uploaded_format character varying(10),
+      uploaded_sha256 character(64),
+      resulted_size bigint,
+      phash bigint,
+      blurhash character varying(60),
+      placeholder text,
       CONSTRAINT user_image_id PRIMARY KEY (id)
     );
   CREATE TABLE IF NOT EXISTS image_service.request (
@@ -30,10 +37,42 @@ psql -v ON_ERROR_STOP=1 --username "$POSTGRES_USER" --dbname "$DB_NAME" <<-EOSQL
       status image_service.enum_status,
       time_started TIMESTAMP,
       time_completed TIMESTAMP,
+      pipeline text,
       CONSTRAINT fk_user_image_user_account_id FOREIGN KEY (user_account_id) REFERENCES image_service.user_account(id),
       CONSTRAINT fk_request_image_id FOREIGN KEY (image_id) REFERENCES image_service.image(id),
       CONSTRAINT request_id PRIMARY KEY (id)
     );
+  CREATE TABLE IF NOT EXISTS image_service.rendition (
+      id uuid DEFAULT gen_random_uuid(),
+      request_id uuid NOT NULL,
+      width integer NOT NULL,
+      height integer NOT NULL,
+      format character varying(10) NOT NULL,
+      resulted_name character varying(150) NOT NULL,
+      resulted_location character varying(150) NOT NULL,
+      CONSTRAINT fk_rendition_request_id FOREIGN KEY (request_id) REFERENCES image_service.request(id),
+      CONSTRAINT rendition_id PRIMARY KEY (id)
+    );
+  CREATE TABLE IF NOT EXISTS image_service.palette_color (
+      request_id uuid NOT NULL,
+      position integer NOT NULL,
+      color character varying(7) NOT NULL,
+      coverage double precision NOT NULL,
+      CONSTRAINT fk_palette_color_request_id FOREIGN KEY (request_id) REFERENCES image_service.request(id),
+      CONSTRAINT palette_color_id PRIMARY KEY (request_id, position)
+    );
+  CREATE TABLE IF NOT EXISTS image_service.watermark (
+      user_account_id uuid NOT NULL,
+      logo bytea,
+      text character varying(200),
+      color character varying(9),
+      position character varying(20) NOT NULL,
+      opacity double precision NOT NULL,
+      margin integer NOT NULL,
+      scale double precision NOT NULL,
+      CONSTRAINT fk_watermark_user_account_id FOREIGN KEY (user_account_id) REFERENCES image_service.user_account(id),
+      CONSTRAINT watermark_user_account_id PRIMARY KEY (user_account_id)
+    );
   CREATE ROLE $DB_USER WITH LOGIN ENCRYPTED PASSWORD '$DB_PASSWORD';
   GRANT USAGE ON SCHEMA image_service TO $DB_USER;
   GRANT ALL PRIVILEGES ON ALL TABLES IN SCHEMA image_service TO $DB_USER;